@@ -1,3 +1,3 @@
 module github.com/SophonMesh/go-libs
 
-go 1.17
+go 1.18
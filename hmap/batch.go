@@ -0,0 +1,35 @@
+package hmap
+
+import "fmt"
+
+// 检查批量操作中与key一一对应的输入长度，n为key的个数
+// 批量操作（AddBatch、GetBatch、RemoveBatch等）的slice长度约定如下，不满足时panic：
+//   - 与key一一对应的输入（如values）长度必须与key的个数相同
+//   - 输出（如found、added）为nil时表示不需要该输出，否则长度必须与key的个数相同
+func CheckBatchInput(n, length int) {
+	if length != n {
+		panic(fmt.Sprintf("批量操作的输入长度%d与key的个数%d不一致", length, n))
+	}
+}
+
+// 检查输出长度，n为key的个数，output为nil时不检查
+func CheckBatchOutput[T any](n int, output []T) {
+	if output != nil && len(output) != n {
+		panic(fmt.Sprintf("批量操作的输出长度%d与key的个数%d不一致", len(output), n))
+	}
+}
+
+// 批量操作中预先计算的哈希桶或哈希值，在多次批量操作之间复用
+//
+// 批量操作只在遍历冲突链之前计算整批key的哈希，不预取哈希桶：曾经在遍历前依次读取整批key的哈希桶头，
+// 希望提前触发cache miss，但Go没有prefetch指令，读取本身就会等待内存，实测更慢
+// （BenchmarkU64LRUGetBatch预取时约100 ns/op，不预取约72 ns/op，单个Get约140 ns/op），因此没有保留
+type BatchSlots []int32
+
+// 返回长度为n的哈希桶数组，容量不足时重新申请
+func (s *BatchSlots) Resize(n int) []int32 {
+	if cap(*s) < n {
+		*s = make([]int32, n)
+	}
+	return (*s)[:n]
+}
@@ -1,102 +1,270 @@
 package heap
 
 import (
+	"errors"
 	"fmt"
 )
 
 const (
-	MAX_BUCKET_COUNT    = 100000 // 最大的桶数量限制，防止过量扩展桶
-	MAX_FREE_NODE_COUNT = 16     // 最大缓存的pop个数，也是支持同时读的线程数
+	MAX_BUCKET_COUNT    = 100000 // 默认的最大桶数量限制，防止过量扩展桶，可通过OptionMaxBucketCount修改
+	MAX_FREE_NODE_COUNT = 16     // 已废弃，空闲节点链表不再限制长度
+
+	_MIN_GROW_NODE_COUNT = 16 // 自动扩容时nodes的最小长度
+)
+
+var (
+	ErrInvalidHandle = errors.New("handle已失效")
+	ErrNodesOverflow = errors.New("nodes缓冲区溢出")
 )
 
-type bucketHeapNode struct {
-	value interface{}
-	next  int32 // 值为 nodes[i] 所在链表的下一个节点的 nodes 下标
+type Option = interface{}
+
+// 桶的数量上限，Push的bucketIndex不能超过该值
+type OptionMaxBucketCount int
+
+// nodes已满时自动扩容，而不是返回ErrNodesOverflow
+type OptionAutoGrow bool
+
+type bucketHeapNode[T any] struct {
+	value      T
+	next       int32  // 值为 nodes[i] 所在链表（或空闲链表）的下一个节点的 nodes 下标
+	prev       int32  // 值为 nodes[i] 所在链表的上一个节点的 nodes 下标
+	bucket     int32  // 节点所在的桶，-1 表示节点不在堆中
+	generation uint32 // 节点每次被释放时加一，用于判断Handle是否失效
+}
+
+// Push返回的句柄，用于Remove和UpdateBucket，对应的元素被Pop或Remove后失效
+type Handle struct {
+	index      int32
+	generation uint32
 }
 
 // 注意：不是线程安全的
 // 一个高效的特殊小顶堆实现，结合桶排序的思路，针对节点的SortKey值域小且重复多的场景。
-// 堆中的节点是一个 <bucketIndex int, x interface{}> 的二元组，
+// 堆中的节点是一个 <bucketIndex int, x T> 的二元组，
 // bucketIndex用于决定 x 在 Heap 中的位置（SortKey）。
-type BucketHeap struct {
-	nodes      []bucketHeapNode // 长度为待排序的最大节点数量
-	bucketHead []int32          // 长度为桶的个数，定长
+type TypedBucketHeap[T any] struct {
+	nodes      []bucketHeapNode[T] // 长度为待排序的最大节点数量
+	bucketHead []int32             // 长度为桶的个数，定长
+
+	minBucket    int   // bucketHead中最小非空的桶
+	nodeCount    int32 // nodes中使用过的节点数量
+	size         int   // 堆中元素的数量
+	freeListHead int32 // Pop、Remove之后可重用的节点链表，通过next连接，-1 表示为空
 
-	minBucket         int     // bucketHead中最小非空的桶
-	nodeCount         int32   // nodes中节点的数量
-	freeNodeIndexList []int32 // Pop之后可重用的Node, 最大不超过MAX_FREE_NODE_COUNT
+	maxBucketCount int
+	autoGrow       bool
 }
 
-// 向bucketIndex所在的桶插入一个元素x
-func (s *BucketHeap) Push(bucketIndex int, x interface{}) error {
-	if bucketIndex < 0 || bucketIndex >= MAX_BUCKET_COUNT {
-		return fmt.Errorf("bucketIndex %d 溢出，上限为 %d", bucketIndex, MAX_BUCKET_COUNT)
-	}
-	// 自动扩展bucket数量
-	for bucketIndex >= len(s.bucketHead) {
-		s.bucketHead = append(s.bucketHead, -1)
+// 将节点插入bucketIndex所在桶的链表头部
+func (s *TypedBucketHeap[T]) pushNodeToBucket(index int32, bucketIndex int) {
+	node := &s.nodes[index]
+	node.bucket = int32(bucketIndex)
+	node.prev = -1
+	node.next = s.bucketHead[bucketIndex]
+	if node.next != -1 {
+		s.nodes[node.next].prev = index
 	}
+	s.bucketHead[bucketIndex] = index
 
 	if bucketIndex < s.minBucket {
 		s.minBucket = bucketIndex
 	}
+}
 
-	// 使用freeNodeIndex存储x
-	if len(s.freeNodeIndexList) > 0 {
-		freeNodeIndex := s.freeNodeIndexList[len(s.freeNodeIndexList)-1]
-		node := &s.nodes[freeNodeIndex]
-		node.value = x
-		node.next = s.bucketHead[bucketIndex]
-		s.bucketHead[bucketIndex] = freeNodeIndex
-		s.freeNodeIndexList = s.freeNodeIndexList[:len(s.freeNodeIndexList)-1]
-		return nil
+// 将节点从所在桶的链表中删除
+func (s *TypedBucketHeap[T]) removeNodeFromBucket(index int32) {
+	node := &s.nodes[index]
+	if node.prev != -1 {
+		s.nodes[node.prev].next = node.next
+	} else {
+		s.bucketHead[node.bucket] = node.next
+	}
+	if node.next != -1 {
+		s.nodes[node.next].prev = node.prev
+	}
+}
+
+// 释放节点并加入空闲链表，返回节点中的元素
+func (s *TypedBucketHeap[T]) freeNode(index int32) T {
+	// 将node清空
+	var zero T
+	node := &s.nodes[index]
+	x := node.value
+	node.value = zero
+	node.next = s.freeListHead
+	node.prev = -1
+	node.bucket = -1
+	node.generation++
+	s.freeListHead = index
+	s.size--
+
+	return x
+}
+
+// 从空闲链表或未使用的nodes中分配一个节点
+func (s *TypedBucketHeap[T]) allocNode() (int32, error) {
+	if s.freeListHead != -1 {
+		index := s.freeListHead
+		s.freeListHead = s.nodes[index].next
+		return index, nil
 	}
 
-	// 使用新的Node存储x
 	if s.nodeCount >= int32(len(s.nodes)) {
-		panic(fmt.Sprintf("nodes缓冲区溢出，当前长度已达到上限 %d", s.nodeCount))
+		if !s.autoGrow {
+			return -1, ErrNodesOverflow
+		}
+		newSize := len(s.nodes) * 2
+		if newSize < _MIN_GROW_NODE_COUNT {
+			newSize = _MIN_GROW_NODE_COUNT
+		}
+		nodes := make([]bucketHeapNode[T], newSize)
+		copy(nodes, s.nodes)
+		s.nodes = nodes
 	}
-	node := &s.nodes[s.nodeCount]
-	node.value = x
-	node.next = s.bucketHead[bucketIndex]
-	s.bucketHead[bucketIndex] = s.nodeCount
+	index := s.nodeCount
 	s.nodeCount++
+	// generation从1开始，使零值Handle总是无效的
+	s.nodes[index].generation = 1
+	return index, nil
+}
+
+func (s *TypedBucketHeap[T]) checkBucketIndex(bucketIndex int) error {
+	if bucketIndex < 0 || bucketIndex >= s.maxBucketCount {
+		return fmt.Errorf("bucketIndex %d 溢出，上限为 %d", bucketIndex, s.maxBucketCount)
+	}
+	// 自动扩展bucket数量
+	for bucketIndex >= len(s.bucketHead) {
+		s.bucketHead = append(s.bucketHead, -1)
+	}
 	return nil
 }
 
-// 返回最小bucket中的一个元素，若没有返回nil
-// 注意：Pop最多会产生MAX_FREE_NODE_COUNT个未被删除的Node，须及时Push，否则连续的MAX_FREE_NODE_COUNT+1次Pop会导致这个Node被泄漏
-func (s *BucketHeap) Pop() interface{} {
-	for i := s.minBucket; i < len(s.bucketHead); i++ {
-		if s.bucketHead[i] == -1 {
-			s.minBucket++
-			continue
-		}
+func (s *TypedBucketHeap[T]) getNode(h Handle) (*bucketHeapNode[T], error) {
+	if h.index < 0 || h.index >= s.nodeCount {
+		return nil, ErrInvalidHandle
+	}
+	node := &s.nodes[h.index]
+	if node.generation != h.generation || node.bucket == -1 {
+		return nil, ErrInvalidHandle
+	}
+	return node, nil
+}
+
+// 向bucketIndex所在的桶插入一个元素x，返回的Handle可用于Remove和UpdateBucket
+// nodes已满且未指定OptionAutoGrow时返回ErrNodesOverflow
+func (s *TypedBucketHeap[T]) Push(bucketIndex int, x T) (Handle, error) {
+	if err := s.checkBucketIndex(bucketIndex); err != nil {
+		return Handle{}, err
+	}
+
+	index, err := s.allocNode()
+	if err != nil {
+		return Handle{}, err
+	}
 
-		// 获取到链表头部并从bucketHead链表中删除
-		if len(s.freeNodeIndexList) < MAX_FREE_NODE_COUNT {
-			s.freeNodeIndexList = append(s.freeNodeIndexList, s.bucketHead[i])
+	node := &s.nodes[index]
+	node.value = x
+	s.pushNodeToBucket(index, bucketIndex)
+	s.size++
+
+	return Handle{index: index, generation: node.generation}, nil
+}
+
+// 跳过空桶，返回最小的非空桶，堆为空时返回-1
+func (s *TypedBucketHeap[T]) findMinBucket() int {
+	for ; s.minBucket < len(s.bucketHead); s.minBucket++ {
+		if s.bucketHead[s.minBucket] != -1 {
+			return s.minBucket
 		}
-		node := &s.nodes[s.bucketHead[i]]
-		s.bucketHead[i] = node.next
+	}
+	return -1
+}
+
+// 返回最小bucket中的一个元素，若没有返回零值和false
+func (s *TypedBucketHeap[T]) Pop() (T, bool) {
+	i := s.findMinBucket()
+	if i == -1 {
+		var zero T
+		return zero, false
+	}
+
+	// 获取到链表头部并从bucketHead链表中删除
+	index := s.bucketHead[i]
+	s.removeNodeFromBucket(index)
+	return s.freeNode(index), true
+}
+
+// 返回下一次Pop将返回的元素及其所在的桶，不从堆中删除，若没有返回零值和-1
+func (s *TypedBucketHeap[T]) Peek() (T, int) {
+	i := s.findMinBucket()
+	if i == -1 {
+		var zero T
+		return zero, -1
+	}
+	return s.nodes[s.bucketHead[i]].value, i
+}
+
+// 堆中元素的数量
+func (s *TypedBucketHeap[T]) Len() int {
+	return s.size
+}
 
-		// 将node清空
-		x := node.value
-		node.value = nil
-		node.next = -1
+// 删除bucketIndex所在桶中的所有元素，按Pop的顺序追加到dst并返回
+func (s *TypedBucketHeap[T]) PopBucket(bucketIndex int, dst []T) []T {
+	if bucketIndex < 0 || bucketIndex >= len(s.bucketHead) {
+		return dst
+	}
+	for index := s.bucketHead[bucketIndex]; index != -1; index = s.bucketHead[bucketIndex] {
+		s.removeNodeFromBucket(index)
+		dst = append(dst, s.freeNode(index))
+	}
+	return dst
+}
+
+// 删除Handle对应的元素，Handle已失效时返回ErrInvalidHandle
+func (s *TypedBucketHeap[T]) Remove(h Handle) (T, error) {
+	if _, err := s.getNode(h); err != nil {
+		var zero T
+		return zero, err
+	}
+	s.removeNodeFromBucket(h.index)
+	return s.freeNode(h.index), nil
+}
 
-		return x
+// 将Handle对应的元素移动至bucketIndex所在的桶，Handle保持有效
+func (s *TypedBucketHeap[T]) UpdateBucket(h Handle, bucketIndex int) error {
+	node, err := s.getNode(h)
+	if err != nil {
+		return err
+	}
+	if err := s.checkBucketIndex(bucketIndex); err != nil {
+		return err
 	}
+	if int(node.bucket) == bucketIndex {
+		return nil
+	}
+	s.removeNodeFromBucket(h.index)
+	s.pushNodeToBucket(h.index, bucketIndex)
 	return nil
 }
 
 // buckets：桶的数量，桶是编号从0开始的连续自然数
-// capacity：在sorter中驻留的最大节点数量，若已满Push会报错
-func NewBucketHeap(buckets, capacity int) *BucketHeap {
-	s := &BucketHeap{
-		nodes:             make([]bucketHeapNode, capacity),
-		bucketHead:        make([]int32, buckets),
-		freeNodeIndexList: make([]int32, 0, MAX_FREE_NODE_COUNT),
+// capacity：在sorter中驻留的最大节点数量，若已满Push会报错，指定OptionAutoGrow(true)时自动扩容
+// options：OptionMaxBucketCount、OptionAutoGrow
+func NewTypedBucketHeap[T any](buckets, capacity int, options ...Option) *TypedBucketHeap[T] {
+	s := &TypedBucketHeap[T]{
+		nodes:          make([]bucketHeapNode[T], capacity),
+		bucketHead:     make([]int32, buckets),
+		freeListHead:   -1,
+		maxBucketCount: MAX_BUCKET_COUNT,
+	}
+	for _, opt := range options {
+		if count, ok := opt.(OptionMaxBucketCount); ok && count > 0 {
+			s.maxBucketCount = int(count)
+		} else if autoGrow, ok := opt.(OptionAutoGrow); ok {
+			s.autoGrow = bool(autoGrow)
+		}
 	}
 
 	for i := 0; i < buckets; i++ {
@@ -105,3 +273,19 @@ func NewBucketHeap(buckets, capacity int) *BucketHeap {
 
 	return s
 }
+
+// 元素类型为interface{}的TypedBucketHeap，Pop在堆为空时返回nil
+type BucketHeap struct {
+	TypedBucketHeap[interface{}]
+}
+
+// 返回最小bucket中的一个元素，若没有返回nil
+func (s *BucketHeap) Pop() interface{} {
+	x, _ := s.TypedBucketHeap.Pop()
+	return x
+}
+
+// 参数同NewTypedBucketHeap
+func NewBucketHeap(buckets, capacity int, options ...Option) *BucketHeap {
+	return &BucketHeap{*NewTypedBucketHeap[interface{}](buckets, capacity, options...)}
+}
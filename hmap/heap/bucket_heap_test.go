@@ -65,6 +65,208 @@ func TestBucketHeap(t *testing.T) {
 	}
 }
 
+func TestBucketHeapPeekAndLen(t *testing.T) {
+	s := NewBucketHeap(4, 8)
+
+	if x, bucket := s.Peek(); x != nil || bucket != -1 || s.Len() != 0 {
+		t.Errorf("空堆Peek应返回nil和-1，实际返回 %v %d", x, bucket)
+	}
+	s.Push(2, "a")
+	s.Push(1, "b")
+	s.Push(3, "c")
+	if x, bucket := s.Peek(); x != "b" || bucket != 1 {
+		t.Errorf("Peek应返回b和1，实际返回 %v %d", x, bucket)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len应为3，实际为 %d", s.Len())
+	}
+	// Peek不删除元素
+	if x := s.Pop(); x != "b" || s.Len() != 2 {
+		t.Errorf("Pop应返回b，实际返回 %v", x)
+	}
+}
+
+func TestBucketHeapPopBucket(t *testing.T) {
+	s := NewBucketHeap(4, 8)
+	for i := 0; i < 3; i++ {
+		s.Push(1, i)
+	}
+	s.Push(2, 3)
+
+	values := s.PopBucket(1, nil)
+	if len(values) != 3 || values[0] != 2 || values[1] != 1 || values[2] != 0 {
+		t.Errorf("PopBucket应按Pop的顺序返回2 1 0，实际返回 %v", values)
+	}
+	if values = s.PopBucket(1, values[:0]); len(values) != 0 {
+		t.Errorf("空桶PopBucket不应返回元素，实际返回 %v", values)
+	}
+	if values = s.PopBucket(100, values); len(values) != 0 {
+		t.Errorf("不存在的桶PopBucket不应返回元素，实际返回 %v", values)
+	}
+	if x := s.Pop(); x != 3 || s.Len() != 0 {
+		t.Errorf("Pop应返回3，实际返回 %v", x)
+	}
+}
+
+func TestBucketHeapHandle(t *testing.T) {
+	s := NewBucketHeap(8, 8)
+	handles := make([]Handle, 4)
+	for i := range handles {
+		handles[i], _ = s.Push(4, i)
+	}
+
+	// 链表中间的元素
+	if x, err := s.Remove(handles[2]); err != nil || x != 2 {
+		t.Errorf("Remove应返回2，实际返回 %v %v", x, err)
+	}
+	if _, err := s.Remove(handles[2]); err != ErrInvalidHandle {
+		t.Errorf("重复Remove应返回ErrInvalidHandle，实际返回 %v", err)
+	}
+	if _, err := s.Remove(Handle{}); err != ErrInvalidHandle {
+		t.Errorf("零值Handle应无效，实际返回 %v", err)
+	}
+
+	// 降低优先级后最先Pop
+	if err := s.UpdateBucket(handles[0], 1); err != nil {
+		t.Errorf("UpdateBucket返回错误 %v", err)
+	}
+	if err := s.UpdateBucket(handles[3], 6); err != nil {
+		t.Errorf("UpdateBucket返回错误 %v", err)
+	}
+	if err := s.UpdateBucket(handles[1], MAX_BUCKET_COUNT); err == nil {
+		t.Error("UpdateBucket超过MAX_BUCKET_COUNT应返回错误")
+	}
+	for _, expected := range []int{0, 1, 3} {
+		if x := s.Pop(); x != expected {
+			t.Errorf("Pop应返回 %d，实际返回 %v", expected, x)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len应为0，实际为 %d", s.Len())
+	}
+	if err := s.UpdateBucket(handles[0], 1); err != ErrInvalidHandle {
+		t.Errorf("Pop后UpdateBucket应返回ErrInvalidHandle，实际返回 %v", err)
+	}
+}
+
+func TestBucketHeapNodeReuse(t *testing.T) {
+	// capacity为2，Pop释放的节点必须被重用
+	s := NewBucketHeap(4, 2)
+	h0, _ := s.Push(0, 0)
+	s.Push(1, 1)
+	if x := s.Pop(); x != 0 {
+		t.Errorf("Pop应返回0，实际返回 %v", x)
+	}
+
+	h2, _ := s.Push(2, 2)
+	if h2.index != h0.index {
+		t.Errorf("Push应重用Pop释放的节点 %d，实际使用 %d", h0.index, h2.index)
+	}
+	// 节点被重用后，旧的Handle仍然无效
+	if _, err := s.Remove(h0); err != ErrInvalidHandle {
+		t.Errorf("节点重用后旧Handle应无效，实际返回 %v", err)
+	}
+	if x, err := s.Remove(h2); err != nil || x != 2 {
+		t.Errorf("Remove应返回2，实际返回 %v %v", x, err)
+	}
+
+	// Remove释放的节点同样可以重用
+	h3, _ := s.Push(3, 3)
+	if h3.index != h0.index {
+		t.Errorf("Push应重用Remove释放的节点 %d，实际使用 %d", h0.index, h3.index)
+	}
+	for _, expected := range []int{1, 3} {
+		if x := s.Pop(); x != expected {
+			t.Errorf("Pop应返回 %d，实际返回 %v", expected, x)
+		}
+	}
+}
+
+func TestBucketHeapNoLeak(t *testing.T) {
+	// 连续Pop超过16个节点后，所有节点都应能被重用
+	capacity := 64
+	s := NewBucketHeap(4, capacity)
+	for round := 0; round < 4; round++ {
+		for i := 0; i < capacity; i++ {
+			if _, err := s.Push(i%4, i); err != nil {
+				t.Fatalf("第 %d 轮第 %d 次Push返回错误 %v", round, i, err)
+			}
+		}
+		for i := 0; i < capacity; i++ {
+			if x := s.Pop(); x == nil {
+				t.Fatalf("第 %d 轮第 %d 次Pop返回nil", round, i)
+			}
+		}
+	}
+}
+
+func TestBucketHeapOverflow(t *testing.T) {
+	s := NewBucketHeap(4, 2)
+	s.Push(0, 0)
+	s.Push(0, 1)
+	if _, err := s.Push(0, 2); err != ErrNodesOverflow {
+		t.Errorf("nodes已满时Push应返回ErrNodesOverflow，实际返回 %v", err)
+	}
+	if s.Len() != 2 {
+		t.Errorf("Len应为2，实际为 %d", s.Len())
+	}
+
+	s = NewBucketHeap(4, 0, OptionAutoGrow(true))
+	for i := 0; i < 100; i++ {
+		if _, err := s.Push(i%4, i); err != nil {
+			t.Fatalf("自动扩容时Push返回错误 %v", err)
+		}
+	}
+	if s.Len() != 100 {
+		t.Errorf("Len应为100，实际为 %d", s.Len())
+	}
+	for i := 0; i < 100; i++ {
+		if x, bucket := s.Peek(); x == nil || x.(int)%4 != bucket {
+			t.Fatalf("Peek返回 %v %d，元素与所在桶不符", x, bucket)
+		}
+		s.Pop()
+	}
+}
+
+func TestBucketHeapMaxBucketCount(t *testing.T) {
+	s := NewBucketHeap(4, 8, OptionMaxBucketCount(8))
+	if _, err := s.Push(7, 0); err != nil {
+		t.Errorf("Push返回错误 %v", err)
+	}
+	if _, err := s.Push(8, 0); err == nil {
+		t.Error("bucketIndex超过OptionMaxBucketCount时Push应返回错误")
+	}
+
+	s = NewBucketHeap(4, 8)
+	if _, err := s.Push(MAX_BUCKET_COUNT, 0); err == nil {
+		t.Error("bucketIndex超过MAX_BUCKET_COUNT时Push应返回错误")
+	}
+}
+
+func TestTypedBucketHeap(t *testing.T) {
+	s := NewTypedBucketHeap[string](4, 0, OptionAutoGrow(true))
+	if x, ok := s.Pop(); ok || x != "" {
+		t.Errorf("空堆Pop应返回零值和false，实际返回 %q %v", x, ok)
+	}
+	s.Push(2, "a")
+	h, _ := s.Push(3, "b")
+	s.Push(1, "c")
+	if x, err := s.Remove(h); err != nil || x != "b" {
+		t.Errorf("Remove应返回b，实际返回 %q %v", x, err)
+	}
+	if x, err := s.Remove(h); err != ErrInvalidHandle || x != "" {
+		t.Errorf("重复Remove应返回零值和ErrInvalidHandle，实际返回 %q %v", x, err)
+	}
+	for _, expected := range []string{"c", "a"} {
+		if x, ok := s.Pop(); !ok || x != expected {
+			t.Errorf("Pop应返回 %s，实际返回 %q", expected, x)
+		}
+	}
+	if x, bucket := s.Peek(); x != "" || bucket != -1 {
+		t.Errorf("空堆Peek应返回零值和-1，实际返回 %q %d", x, bucket)
+	}
+}
+
 func BenchmarkBucketHeap(b *testing.B) {
 	capacity := b.N
 	s := NewBucketHeap(24, capacity)
@@ -0,0 +1,131 @@
+package heap
+
+import (
+	"errors"
+	"math/bits"
+)
+
+var ErrKeyDecreased = errors.New("key小于最近一次Pop的key")
+
+type radixHeapNode[T any] struct {
+	key   uint64
+	value T
+}
+
+// 注意：不是线程安全的
+// 单调的小顶堆，适用于key随Pop单调不减的场景（例如以纳秒为单位的到期时间），无需预先将key量化为桶。
+// 第i个桶存放与最近一次Pop的key（last）最高不同bit为第i-1位的元素，第0个桶存放key等于last的元素，
+// Pop时将最小的非空桶重新分配到更低的桶中，每个元素最多被移动64次，Push和Pop的均摊复杂度为O(1)。
+// 要求Push的key不小于最近一次Pop的key。
+type RadixHeap[T any] struct {
+	buckets [65][]radixHeapNode[T]
+	last    uint64 // 最近一次Pop的key
+	size    int
+}
+
+func (h *RadixHeap[T]) bucketIndex(key uint64) int {
+	return bits.Len64(key ^ h.last)
+}
+
+// 插入一个元素x，key小于最近一次Pop的key时返回ErrKeyDecreased
+func (h *RadixHeap[T]) Push(key uint64, x T) error {
+	if key < h.last {
+		return ErrKeyDecreased
+	}
+	i := h.bucketIndex(key)
+	h.buckets[i] = append(h.buckets[i], radixHeapNode[T]{key: key, value: x})
+	h.size++
+	return nil
+}
+
+// 返回最小的非空桶，堆为空时返回-1
+func (h *RadixHeap[T]) findMinBucket() int {
+	for i := range h.buckets {
+		if len(h.buckets[i]) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// 将最小的非空桶重新分配，使第0个桶非空
+func (h *RadixHeap[T]) redistribute() {
+	i := h.findMinBucket()
+	if i <= 0 {
+		return
+	}
+	bucket := h.buckets[i]
+	h.last = bucket[0].key
+	for _, node := range bucket[1:] {
+		if node.key < h.last {
+			h.last = node.key
+		}
+	}
+	// 桶中元素与新的last的最高不同bit均低于第i-1位，只会移动到更低的桶
+	var zero radixHeapNode[T]
+	for j, node := range bucket {
+		k := h.bucketIndex(node.key)
+		h.buckets[k] = append(h.buckets[k], node)
+		bucket[j] = zero
+	}
+	h.buckets[i] = bucket[:0]
+}
+
+// 返回key最小的一个元素，若没有返回false
+func (h *RadixHeap[T]) Pop() (uint64, T, bool) {
+	if h.size == 0 {
+		var zero T
+		return 0, zero, false
+	}
+	h.redistribute()
+	bucket := h.buckets[0]
+	node := bucket[len(bucket)-1]
+	bucket[len(bucket)-1] = radixHeapNode[T]{}
+	h.buckets[0] = bucket[:len(bucket)-1]
+	h.size--
+	return node.key, node.value, true
+}
+
+// 返回下一次Pop将返回的元素及其key，不从堆中删除，若没有返回false
+func (h *RadixHeap[T]) Peek() (uint64, T, bool) {
+	if h.size == 0 {
+		var zero T
+		return 0, zero, false
+	}
+	// 不做重新分配，避免改变last，取最小桶中最后一个key最小的元素，与Pop的结果一致
+	bucket := h.buckets[h.findMinBucket()]
+	node := &bucket[len(bucket)-1]
+	for i := range bucket {
+		if bucket[i].key <= node.key {
+			node = &bucket[i]
+		}
+	}
+	return node.key, node.value, true
+}
+
+// 堆中元素的数量
+func (h *RadixHeap[T]) Len() int {
+	return h.size
+}
+
+// 最近一次Pop的key，Push的key不能小于该值
+func (h *RadixHeap[T]) Last() uint64 {
+	return h.last
+}
+
+// 清空堆，保留已申请的内存，之后Push的key不再受之前Pop的key限制
+func (h *RadixHeap[T]) Clear() {
+	for i := range h.buckets {
+		var zero radixHeapNode[T]
+		for j := range h.buckets[i] {
+			h.buckets[i][j] = zero
+		}
+		h.buckets[i] = h.buckets[i][:0]
+	}
+	h.last = 0
+	h.size = 0
+}
+
+func NewRadixHeap[T any]() *RadixHeap[T] {
+	return &RadixHeap[T]{}
+}
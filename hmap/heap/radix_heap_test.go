@@ -0,0 +1,125 @@
+package heap
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRadixHeap(t *testing.T) {
+	h := NewRadixHeap[string]()
+	if _, _, ok := h.Pop(); ok {
+		t.Error("空堆Pop应返回false")
+	}
+	h.Push(100, "a")
+	h.Push(5, "b")
+	h.Push(1<<40, "c")
+	h.Push(5, "d")
+
+	if key, x, ok := h.Peek(); !ok || key != 5 || x != "d" || h.Len() != 4 {
+		t.Errorf("Peek应返回5 d，实际返回 %d %q", key, x)
+	}
+	// Peek不改变last
+	if err := h.Push(3, "e"); err != nil {
+		t.Errorf("Peek后Push返回错误 %v", err)
+	}
+	for _, expected := range []struct {
+		key uint64
+		x   string
+	}{{3, "e"}, {5, "d"}, {5, "b"}, {100, "a"}} {
+		if key, x, ok := h.Pop(); !ok || key != expected.key || x != expected.x {
+			t.Errorf("Pop应返回 %d %s，实际返回 %d %q", expected.key, expected.x, key, x)
+		}
+	}
+	if err := h.Push(99, "f"); err != ErrKeyDecreased {
+		t.Errorf("key小于last时Push应返回ErrKeyDecreased，实际返回 %v", err)
+	}
+	if err := h.Push(100, "f"); err != nil || h.Last() != 100 {
+		t.Errorf("key等于last时Push返回错误 %v", err)
+	}
+
+	h.Clear()
+	if h.Len() != 0 || h.Last() != 0 {
+		t.Errorf("Clear后Len和Last应为0，实际为 %d %d", h.Len(), h.Last())
+	}
+	if err := h.Push(0, "g"); err != nil {
+		t.Errorf("Clear后Push返回错误 %v", err)
+	}
+}
+
+func TestRadixHeapRandom(t *testing.T) {
+	for _, seed := range []int64{42, 233, 1024} {
+		rand.Seed(seed)
+		h := NewRadixHeap[uint64]()
+		var expected []uint64
+
+		for i := 0; i < 10000; i++ {
+			if rand.Intn(3) > 0 {
+				// 不小于last的随机key，跨度覆盖不同的桶
+				key := h.Last() + uint64(rand.Int63n(1<<uint(rand.Intn(41))))
+				h.Push(key, key)
+				expected = append(expected, key)
+				sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+				continue
+			}
+			key, x, ok := h.Pop()
+			if len(expected) == 0 {
+				if ok {
+					t.Fatalf("测试%d: 空堆Pop应返回false", seed)
+				}
+				continue
+			}
+			if !ok || key != expected[0] || x != key {
+				t.Fatalf("测试%d: Pop结果预期为%v，实际为%v", seed, expected[0], key)
+			}
+			expected = expected[1:]
+		}
+		if h.Len() != len(expected) {
+			t.Fatalf("测试%d: Len预期为%d，实际为%d", seed, len(expected), h.Len())
+		}
+	}
+}
+
+type u64Heap []uint64
+
+func (h u64Heap) Len() int            { return len(h) }
+func (h u64Heap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h u64Heap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *u64Heap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+func (h *u64Heap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+const _BENCH_HEAP_SIZE = 10000
+
+// 模拟以纳秒为单位的定时器：保持堆中有固定数量的元素，每次Pop后Push一个1s内到期的key
+func BenchmarkRadixHeap(b *testing.B) {
+	h := NewRadixHeap[int]()
+	for i := 0; i < _BENCH_HEAP_SIZE; i++ {
+		h.Push(uint64(rand.Int63n(1e9)), i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key, x, _ := h.Pop()
+		h.Push(key+uint64(rand.Int63n(1e9)), x)
+	}
+}
+
+func BenchmarkNativeU64Heap(b *testing.B) {
+	h := make(u64Heap, 0, _BENCH_HEAP_SIZE)
+	for i := 0; i < _BENCH_HEAP_SIZE; i++ {
+		heap.Push(&h, uint64(rand.Int63n(1e9)))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := heap.Pop(&h).(uint64)
+		heap.Push(&h, key+uint64(rand.Int63n(1e9)))
+	}
+}
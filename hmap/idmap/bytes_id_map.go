@@ -0,0 +1,248 @@
+package idmap
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/SophonMesh/go-libs/hmap"
+	"github.com/SophonMesh/go-libs/hmap/keyhash"
+)
+
+const _ARENA_MAX_POOLED_SIZE = 1 << 20 // 容量超过该值的arena不放回sync.Pool，避免长key长期占用内存
+
+type bytesIDMapNode struct {
+	hash      uint32 // key的hash值
+	keyOffset int32  // key在所在块的arena中的偏移
+	keyLen    int32
+	value     uint32
+
+	next int32 // 表示节点所在冲突链的下一个节点的 buffer 数组下标
+	slot int32 // 记录 node 对应的哈希 slot ，为了避免 Clear 函数遍历整个 slotHead 数组
+}
+
+var blankBytesMapNodeForInit bytesIDMapNode
+
+// 节点块，块内节点的key依次追加在arena中
+type bytesIDMapNodeBlock struct {
+	nodes []bytesIDMapNode
+	arena []byte
+}
+
+var bytesIDMapNodeBlockPool = sync.Pool{New: func() interface{} {
+	return &bytesIDMapNodeBlock{nodes: make([]bytesIDMapNode, _BLOCK_SIZE)}
+}}
+
+// 注意：不是线程安全的
+// 以任意长度的[]byte或string为key的ID map，节点只增不删，key依次追加在节点所在块的arena中，
+// Clear时arena随块一起重用。key的哈希值由keyhash.HashBytes计算，不支持冲突链调试，KeySize()为0
+type BytesIDMap struct {
+	id string
+
+	buffer []*bytesIDMapNodeBlock // 存储Map节点，以矩阵的方式组织，提升内存申请释放效率
+
+	slotHead []int32 // 哈希桶，slotHead[i] 表示哈希值为 i 的冲突链的第一个节点为 buffer[[ slotHead[i]] ]]
+	size     int     // buffer中存储的有效节点总数
+	width    int     // 哈希桶中最大冲突链长度
+
+	counter *Counter
+}
+
+func NewBytesIDMap(module string, hashSlots uint32) *BytesIDMap {
+	if hashSlots >= 1<<30 {
+		panic("hashSlots is too large")
+	}
+
+	i := uint32(1)
+	for ; 1<<i < hashSlots; i++ {
+	}
+	hashSlots = 1 << i
+
+	m := &BytesIDMap{
+		buffer:   make([]*bytesIDMapNodeBlock, 0),
+		slotHead: make([]int32, hashSlots),
+		counter:  &Counter{},
+		id:       "idmap-bytes-" + module,
+	}
+
+	for i := uint32(0); i < hashSlots; i++ {
+		m.slotHead[i] = -1
+	}
+
+	return m
+}
+
+func (m *BytesIDMap) ID() string {
+	return m.id
+}
+
+// key的长度不固定，返回0
+func (m *BytesIDMap) KeySize() int {
+	return 0
+}
+
+func (m *BytesIDMap) Close() error {
+	return nil
+}
+
+func (m *BytesIDMap) Size() int {
+	return m.size
+}
+
+func (m *BytesIDMap) Width() int {
+	return m.width
+}
+
+func (m *BytesIDMap) compressHash(hash uint32) int32 {
+	return keyhash.Jenkins32(hash) & int32(len(m.slotHead)-1)
+}
+
+func (m *BytesIDMap) getNode(index int32) *bytesIDMapNode {
+	return &m.buffer[index>>_BLOCK_SIZE_BITS].nodes[index&_BLOCK_SIZE_MASK]
+}
+
+func (m *BytesIDMap) getKey(index int32) []byte {
+	node := m.getNode(index)
+	return m.buffer[index>>_BLOCK_SIZE_BITS].arena[node.keyOffset : node.keyOffset+node.keyLen]
+}
+
+func bytesIDMapFind[T string | []byte](m *BytesIDMap, key T, hash uint32, slot int32, isAdd bool) *bytesIDMapNode {
+	m.counter.scanTimes++
+	width := 0
+	next := m.slotHead[slot]
+	for next != -1 {
+		width++
+		node := m.getNode(next)
+		if node.hash == hash && string(m.getKey(next)) == string(key) {
+			m.counter.totalScan += width
+			if m.counter.Max < width {
+				m.counter.Max = width
+			}
+			return node
+		}
+		next = node.next
+	}
+	m.counter.totalScan += width
+	if isAdd {
+		width++
+	}
+	if m.width < width {
+		m.width = width
+	}
+	if m.counter.Max < width {
+		m.counter.Max = width
+	}
+	return nil
+}
+
+func bytesIDMapAddOrGet[T string | []byte](m *BytesIDMap, key T, hash uint32, value uint32, overwrite bool) (uint32, bool) {
+	slot := m.compressHash(hash)
+	node := bytesIDMapFind(m, key, hash, slot, true)
+	if node != nil {
+		if overwrite {
+			node.value = value
+		}
+		return node.value, false
+	}
+
+	if m.size >= len(m.buffer)<<_BLOCK_SIZE_BITS { // expand
+		m.buffer = append(m.buffer, bytesIDMapNodeBlockPool.Get().(*bytesIDMapNodeBlock))
+	}
+	block := m.buffer[m.size>>_BLOCK_SIZE_BITS]
+	node = &block.nodes[m.size&_BLOCK_SIZE_MASK]
+	node.hash = hash
+	node.keyOffset = int32(len(block.arena))
+	node.keyLen = int32(len(key))
+	block.arena = append(block.arena, key...)
+	node.value = value
+	node.next = m.slotHead[slot]
+	node.slot = slot
+
+	m.slotHead[slot] = int32(m.size)
+	m.size++
+
+	if m.counter.Size < m.size {
+		m.counter.Size = m.size
+	}
+
+	return value, true
+}
+
+func bytesIDMapGet[T string | []byte](m *BytesIDMap, key T, hash uint32) (uint32, bool) {
+	if node := bytesIDMapFind(m, key, hash, m.compressHash(hash), false); node != nil {
+		return node.value, true
+	}
+	return 0, false
+}
+
+// 第一个返回值表示value，第二个返回值表示是否进行了Add。若key已存在，指定overwrite=true可覆写value。
+// ID map中保存key的副本，调用后可以修改key
+func (m *BytesIDMap) AddOrGet(key []byte, value uint32, overwrite bool) (uint32, bool) {
+	return bytesIDMapAddOrGet(m, key, keyhash.HashBytes(key), value, overwrite)
+}
+
+func (m *BytesIDMap) AddOrGetString(key string, value uint32, overwrite bool) (uint32, bool) {
+	return bytesIDMapAddOrGet(m, key, keyhash.HashString(key), value, overwrite)
+}
+
+// 忽略传入的hash，使用keyhash.HashBytes计算，保证与AddOrGet、Get的结果一致
+func (m *BytesIDMap) AddOrGetWithSlice(key []byte, _ uint32, value uint32, overwrite bool) (uint32, bool) {
+	return m.AddOrGet(key, value, overwrite)
+}
+
+func (m *BytesIDMap) Get(key []byte) (uint32, bool) {
+	return bytesIDMapGet(m, key, keyhash.HashBytes(key))
+}
+
+func (m *BytesIDMap) GetString(key string) (uint32, bool) {
+	return bytesIDMapGet(m, key, keyhash.HashString(key))
+}
+
+func (m *BytesIDMap) GetWithSlice(key []byte, _ uint32) (uint32, bool) {
+	return m.Get(key)
+}
+
+func (m *BytesIDMap) GetCounter() interface{} {
+	var counter *Counter
+	counter, m.counter = m.counter, &Counter{Size: m.size}
+	if counter.scanTimes != 0 {
+		counter.AvgScan = counter.totalScan / counter.scanTimes
+	}
+	counter.SetMemoryUsage(m.MemoryUsage())
+	return counter
+}
+
+// 返回占用的内存，节点块包括其中的arena，arena的空闲容量计入BlocksReserved
+func (m *BytesIDMap) MemoryUsage() hmap.MemoryUsage {
+	usage := hmap.MemoryUsage{
+		SlotHeads: len(m.slotHead) * 4,
+		Others:    cap(m.buffer) * hmap.POINTER_SIZE,
+	}
+	for _, block := range m.buffer {
+		usage.BlocksInUse += len(block.arena)
+		usage.BlocksReserved += cap(block.arena) - len(block.arena)
+	}
+	usage.AddNodes(len(m.buffer)*_BLOCK_SIZE, m.size, int(unsafe.Sizeof(bytesIDMapNode{})))
+	return usage
+}
+
+func (m *BytesIDMap) Clear() {
+	for i := 0; i < m.size; i += _BLOCK_SIZE {
+		block := m.buffer[i>>_BLOCK_SIZE_BITS]
+		for j := 0; j < _BLOCK_SIZE && i+j < m.size; j++ {
+			node := &block.nodes[j]
+			m.slotHead[node.slot] = -1
+			*node = blankBytesMapNodeForInit
+		}
+		if cap(block.arena) > _ARENA_MAX_POOLED_SIZE {
+			block.arena = nil
+		}
+		block.arena = block.arena[:0]
+		bytesIDMapNodeBlockPool.Put(block)
+		m.buffer[i>>_BLOCK_SIZE_BITS] = nil
+	}
+
+	m.buffer = m.buffer[:0]
+
+	m.size = 0
+	m.width = 0
+}
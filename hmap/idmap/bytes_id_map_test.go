@@ -0,0 +1,98 @@
+package idmap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBytesIDMapAddOrGet(t *testing.T) {
+	m := NewBytesIDMap("test", 1024)
+
+	exp := true
+	if _, ret := m.AddOrGetString("a", 1, false); ret != exp {
+		t.Errorf("第一次插入，Expected %v found %v", exp, ret)
+	}
+	exp = false
+	if _, ret := m.AddOrGet([]byte("a"), 2, false); ret != exp {
+		t.Errorf("插入同样的值，Expected %v found %v", exp, ret)
+	}
+	if ret, _ := m.Get([]byte("a")); ret != 1 {
+		t.Errorf("查找失败，Expected %v found %v", 1, ret)
+	}
+	if _, ret := m.AddOrGetString("a", 2, true); ret != exp {
+		t.Errorf("插入同样的值，Expected %v found %v", exp, ret)
+	}
+	if ret, _ := m.GetString("a"); ret != 2 {
+		t.Errorf("查找失败，Expected %v found %v", 2, ret)
+	}
+	// 前缀、空key与已有的key不同
+	exp = true
+	for i, key := range []string{"", "a\x00", "ab"} {
+		if _, ret := m.AddOrGetString(key, uint32(i+3), false); ret != exp {
+			t.Errorf("插入不同的值%q，Expected %v found %v", key, exp, ret)
+		}
+	}
+	if ret, in := m.Get(nil); !in || ret != 3 {
+		t.Errorf("查找失败，Expected %v found %v", 3, ret)
+	}
+	if _, in := m.GetString("abc"); in {
+		t.Error("abc不应存在")
+	}
+
+	// 调用后修改key不影响ID map
+	key := []byte("/api/v1/users?id=1")
+	m.AddOrGet(key, 6, false)
+	key[0] = 'x'
+	if ret, in := m.GetString("/api/v1/users?id=1"); !in || ret != 6 {
+		t.Errorf("查找失败，Expected %v found %v", 6, ret)
+	}
+
+	if m.Size() != 5 {
+		t.Errorf("当前长度，Expected %v found %v", 5, m.Size())
+	}
+
+	m.Close()
+}
+
+func TestBytesIDMapClear(t *testing.T) {
+	m := NewBytesIDMap("test", 64)
+	keyOf := func(i int) string {
+		return fmt.Sprintf("select * from t%d where id = ?%s", i, strings.Repeat("#", i%64))
+	}
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 1000; i++ {
+			if _, added := m.AddOrGetWithSlice([]byte(keyOf(i)), 0, uint32(i), false); !added {
+				t.Fatalf("第一次插入，Expected %v found %v", true, added)
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			if ret, in := m.GetString(keyOf(i)); !in || ret != uint32(i) {
+				t.Fatalf("查找失败，Expected %v found %v", i, ret)
+			}
+		}
+		if m.Size() != 1000 {
+			t.Errorf("当前长度，Expected %v found %v", 1000, m.Size())
+		}
+		m.Clear()
+		if m.Size() != 0 || m.Width() != 0 {
+			t.Errorf("Clear后长度，Expected %v found %v", 0, m.Size())
+		}
+		if _, in := m.GetString(keyOf(0)); in {
+			t.Error("Clear后不应存在")
+		}
+	}
+}
+
+func BenchmarkBytesIDMapAddOrGet(b *testing.B) {
+	m := NewBytesIDMap("test", 1<<16)
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("/api/v1/users/%d/orders", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.AddOrGetString(keys[i&0xFFFF], uint32(i), false)
+	}
+}
@@ -1,9 +1,13 @@
 package idmap
 
+import "github.com/SophonMesh/go-libs/hmap"
+
 type Counter struct {
 	Max     int `statsd:"max-bucket"`
 	Size    int `statsd:"size"`
 	AvgScan int `statsd:"avg-scan"` // 平均扫描次数
 
+	hmap.MemoryCounter // GetCounter时的MemoryUsage
+
 	totalScan, scanTimes int
 }
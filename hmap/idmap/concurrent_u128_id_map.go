@@ -0,0 +1,305 @@
+package idmap
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/SophonMesh/go-libs/hmap"
+	"github.com/SophonMesh/go-libs/hmap/keyhash"
+)
+
+type concurrentU128IDMapNode struct {
+	key0  uint64
+	key1  uint64
+	value uint32 // 可能被覆写，需要原子读写
+
+	next int32 // 节点发布后不再修改
+}
+
+type concurrentU128IDMapNodeBlock [_BLOCK_SIZE]concurrentU128IDMapNode
+
+// 哈希桶和节点，Clear时整体替换
+type concurrentU128IDMapTable struct {
+	slotHead []int32      // 原子读写，slotHead[i] 表示哈希值为 i 的冲突链的第一个节点
+	blocks   atomic.Value // 类型为[]*concurrentU128IDMapNodeBlock，只在持有锁时替换
+}
+
+func newConcurrentU128IDMapTable(hashSlots int) *concurrentU128IDMapTable {
+	t := &concurrentU128IDMapTable{slotHead: make([]int32, hashSlots)}
+	for i := range t.slotHead {
+		t.slotHead[i] = -1
+	}
+	t.blocks.Store([]*concurrentU128IDMapNodeBlock{})
+	return t
+}
+
+func (t *concurrentU128IDMapTable) loadBlocks() []*concurrentU128IDMapNodeBlock {
+	return t.blocks.Load().([]*concurrentU128IDMapNodeBlock)
+}
+
+// 注意：Get、GetWithSlice、Size、Width可以与任意方法并发调用，
+// 其余方法（AddOrGet、Clear等）之间通过互斥锁串行执行。
+// 适用于多个goroutine读、偶尔写的场景：
+//   - 节点只追加不修改（value除外），写入节点后才通过原子操作更新slotHead，读者看到的节点总是完整的
+//   - 节点以块的方式存储，块的目录通过atomic.Value发布，扩容时不移动已有的块
+//   - Clear替换整个哈希桶和块的目录，不重用旧的块，正在读的读者不受影响
+type ConcurrentU128IDMap struct {
+	id string
+
+	m     sync.Mutex
+	table atomic.Value // 类型为*concurrentU128IDMapTable，只在持有锁时替换
+	size  int32        // 原子读写
+	width int32        // 原子读写
+
+	hashSlots int
+
+	counter *Counter // 只统计写操作，只在持有锁时修改
+
+	collisionChainDebugThreshold uint32       // scan宽度超过该值时保留冲突链信息，为0时不保存
+	debugChain                   atomic.Value // 冲突链，类型为[]byte
+	debugChainRead               uint32       // 冲突链是否已读，如果已读替换为新的 (atomic.Value无法清空)
+}
+
+func NewConcurrentU128IDMap(module string, hashSlots uint32) *ConcurrentU128IDMap {
+	if hashSlots >= 1<<30 {
+		panic("hashSlots is too large")
+	}
+
+	i := uint32(1)
+	for ; 1<<i < hashSlots; i++ {
+	}
+	hashSlots = 1 << i
+
+	m := &ConcurrentU128IDMap{
+		hashSlots: int(hashSlots),
+		counter:   &Counter{},
+		id:        "idmap128-concurrent-" + module,
+	}
+	m.table.Store(newConcurrentU128IDMapTable(int(hashSlots)))
+
+	return m
+}
+
+func (m *ConcurrentU128IDMap) ID() string {
+	return m.id
+}
+
+func (m *ConcurrentU128IDMap) KeySize() int {
+	return 128 / 8
+}
+
+func (m *ConcurrentU128IDMap) Close() error {
+	hmap.DeregisterForDebug(m)
+	return nil
+}
+
+func (m *ConcurrentU128IDMap) Size() int {
+	return int(atomic.LoadInt32(&m.size))
+}
+
+func (m *ConcurrentU128IDMap) Width() int {
+	return int(atomic.LoadInt32(&m.width))
+}
+
+func (m *ConcurrentU128IDMap) compressHash(key0, key1 uint64) int32 {
+	return keyhash.Jenkins128(key0, key1) & int32(m.hashSlots-1)
+}
+
+func (m *ConcurrentU128IDMap) loadTable() *concurrentU128IDMapTable {
+	return m.table.Load().(*concurrentU128IDMapTable)
+}
+
+// 无锁查询，必须先读slotHead再读blocks，保证看到的blocks包含链上的所有节点
+func (m *ConcurrentU128IDMap) Get(key0, key1 uint64) (uint32, bool) {
+	table := m.loadTable()
+	next := atomic.LoadInt32(&table.slotHead[m.compressHash(key0, key1)])
+	if next == -1 {
+		return 0, false
+	}
+	blocks := table.loadBlocks()
+	for next != -1 {
+		node := &blocks[next>>_BLOCK_SIZE_BITS][next&_BLOCK_SIZE_MASK]
+		if node.key0 == key0 && node.key1 == key1 {
+			return atomic.LoadUint32(&node.value), true
+		}
+		next = node.next
+	}
+	return 0, false
+}
+
+func (m *ConcurrentU128IDMap) GetWithSlice(key []byte, _ uint32) (uint32, bool) {
+	if len(key) != 16 {
+		panic("传入key的长度不等于 16 字节")
+	}
+	return m.Get(binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:]))
+}
+
+// 持有锁时调用
+func (m *ConcurrentU128IDMap) find(table *concurrentU128IDMapTable, blocks []*concurrentU128IDMapNodeBlock, key0, key1 uint64, slot int32) *concurrentU128IDMapNode {
+	m.counter.scanTimes++
+	width := 0
+	next := table.slotHead[slot]
+	for next != -1 {
+		width++
+		node := &blocks[next>>_BLOCK_SIZE_BITS][next&_BLOCK_SIZE_MASK]
+		if node.key0 == key0 && node.key1 == key1 {
+			m.counter.totalScan += width
+			if m.counter.Max < width {
+				m.counter.Max = width
+			}
+			return node
+		}
+		next = node.next
+	}
+	m.counter.totalScan += width
+
+	// 未找到时将添加新节点
+	width++
+	if int(m.width) < width {
+		atomic.StoreInt32(&m.width, int32(width))
+	}
+	if m.counter.Max < width {
+		m.counter.Max = width
+	}
+	if atomic.LoadUint32(&m.debugChainRead) == 1 {
+		// 已读，构造新的chain
+		if threshold := int(atomic.LoadUint32(&m.collisionChainDebugThreshold)); threshold > 0 && width >= threshold {
+			chain := make([]byte, m.KeySize()*width)
+			binary.BigEndian.PutUint64(chain, key0)
+			binary.BigEndian.PutUint64(chain[8:], key1)
+			m.generateCollisionChainIn(table, blocks, chain[m.KeySize():], slot)
+			m.debugChain.Store(chain)
+			atomic.StoreUint32(&m.debugChainRead, 0)
+		}
+	}
+	return nil
+}
+
+func (m *ConcurrentU128IDMap) generateCollisionChainIn(table *concurrentU128IDMapTable, blocks []*concurrentU128IDMapNodeBlock, bs []byte, index int32) {
+	nodeID := table.slotHead[index]
+	offset := 0
+	bsLen := len(bs)
+
+	for nodeID != -1 && offset < bsLen {
+		node := &blocks[nodeID>>_BLOCK_SIZE_BITS][nodeID&_BLOCK_SIZE_MASK]
+		binary.BigEndian.PutUint64(bs[offset:], node.key0)
+		binary.BigEndian.PutUint64(bs[offset+8:], node.key1)
+		offset += m.KeySize()
+		nodeID = node.next
+	}
+}
+
+func (m *ConcurrentU128IDMap) GetCollisionChain() []byte {
+	if atomic.LoadUint32(&m.debugChainRead) == 1 {
+		return nil
+	}
+	chain := m.debugChain.Load()
+	atomic.StoreUint32(&m.debugChainRead, 1)
+	if chain == nil {
+		return nil
+	}
+	return chain.([]byte)
+}
+
+func (m *ConcurrentU128IDMap) SetCollisionChainDebugThreshold(t int) {
+	atomic.StoreUint32(&m.collisionChainDebugThreshold, uint32(t))
+	// 标记为已读，刷新链
+	if t > 0 {
+		atomic.StoreUint32(&m.debugChainRead, 1)
+	}
+}
+
+// 第一个返回值表示value，第二个返回值表示是否进行了Add。若key已存在，指定overwrite=true可覆写value。
+func (m *ConcurrentU128IDMap) AddOrGet(key0, key1 uint64, value uint32, overwrite bool) (uint32, bool) {
+	slot := m.compressHash(key0, key1)
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	table := m.loadTable()
+	blocks := table.loadBlocks()
+	if node := m.find(table, blocks, key0, key1, slot); node != nil {
+		if overwrite {
+			atomic.StoreUint32(&node.value, value)
+		}
+		return node.value, false
+	}
+
+	size := m.size
+	if int(size) >= len(blocks)<<_BLOCK_SIZE_BITS { // expand
+		// 读者持有的目录长度不变，写入其长度之外的位置不会与读者冲突
+		if len(blocks) < cap(blocks) {
+			blocks = blocks[:len(blocks)+1]
+		} else {
+			newBlocks := make([]*concurrentU128IDMapNodeBlock, len(blocks)+1, len(blocks)*2+1)
+			copy(newBlocks, blocks)
+			blocks = newBlocks
+		}
+		blocks[len(blocks)-1] = &concurrentU128IDMapNodeBlock{}
+		table.blocks.Store(blocks)
+	}
+	node := &blocks[size>>_BLOCK_SIZE_BITS][size&_BLOCK_SIZE_MASK]
+	node.key0 = key0
+	node.key1 = key1
+	node.value = value
+	node.next = table.slotHead[slot]
+
+	// 节点写入完成后再发布
+	atomic.StoreInt32(&table.slotHead[slot], size)
+	atomic.StoreInt32(&m.size, size+1)
+
+	if m.counter.Size < int(size+1) {
+		m.counter.Size = int(size + 1)
+	}
+
+	return value, true
+}
+
+func (m *ConcurrentU128IDMap) AddOrGetWithSlice(key []byte, _ uint32, value uint32, overwrite bool) (uint32, bool) {
+	if len(key) != 16 {
+		panic("传入key的长度不等于 16 字节")
+	}
+	return m.AddOrGet(binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:]), value, overwrite)
+}
+
+func (m *ConcurrentU128IDMap) GetCounter() interface{} {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	var counter *Counter
+	counter, m.counter = m.counter, &Counter{Size: int(m.size)}
+	if counter.scanTimes != 0 {
+		counter.AvgScan = counter.totalScan / counter.scanTimes
+	}
+	counter.SetMemoryUsage(m.MemoryUsage())
+	return counter
+}
+
+// 返回当前哈希桶和节点块占用的内存，Clear前的块被读者释放前仍然占用内存，不计入
+func (m *ConcurrentU128IDMap) MemoryUsage() hmap.MemoryUsage {
+	t := m.table.Load().(*concurrentU128IDMapTable)
+	blocks := t.loadBlocks()
+	usage := hmap.MemoryUsage{
+		SlotHeads: len(t.slotHead) * 4,
+		Others:    cap(blocks) * hmap.POINTER_SIZE,
+	}
+	usage.AddNodes(len(blocks)*_BLOCK_SIZE, int(atomic.LoadInt32(&m.size)), int(unsafe.Sizeof(concurrentU128IDMapNode{})))
+	return usage
+}
+
+// 申请新的哈希桶，旧的哈希桶和块不会被重用，由GC回收
+func (m *ConcurrentU128IDMap) Clear() {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	m.table.Store(newConcurrentU128IDMapTable(m.hashSlots))
+
+	atomic.StoreInt32(&m.size, 0)
+	atomic.StoreInt32(&m.width, 0)
+
+	atomic.StoreUint32(&m.debugChainRead, 1)
+}
+
+var _ UBigIDMap = &ConcurrentU128IDMap{}
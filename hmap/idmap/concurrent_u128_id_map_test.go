@@ -0,0 +1,182 @@
+package idmap
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConcurrentU128IDMapAddOrGet(t *testing.T) {
+	m := NewConcurrentU128IDMap("test", 4)
+
+	if _, ret := m.AddOrGet(0, 1, 1, false); !ret {
+		t.Errorf("第一次插入，Expected %v found %v", true, ret)
+	}
+	if value, ret := m.AddOrGet(0, 1, 2, false); ret || value != 1 {
+		t.Errorf("插入同样的值，Expected %v found %v", 1, value)
+	}
+	if value, ret := m.AddOrGet(0, 1, 2, true); ret || value != 2 {
+		t.Errorf("覆写，Expected %v found %v", 2, value)
+	}
+	// 超过一个块，且冲突链较长
+	for i := uint64(0); i < 1000; i++ {
+		m.AddOrGet(1, i, uint32(i), false)
+	}
+	if ret, _ := m.Get(0, 1); ret != 2 {
+		t.Errorf("查找失败，Expected %v found %v", 2, ret)
+	}
+	for i := uint64(0); i < 1000; i++ {
+		if ret, in := m.Get(1, i); !in || ret != uint32(i) {
+			t.Fatalf("查找失败，Expected %v found %v", i, ret)
+		}
+	}
+	if _, in := m.Get(2, 0); in {
+		t.Error("不应查到不存在的key")
+	}
+	if m.Size() != 1001 || m.Width() < 1001/4 {
+		t.Errorf("当前长度，Expected %v found %v", 1001, m.Size())
+	}
+
+	m.Clear()
+	if _, in := m.Get(0, 1); in || m.Size() != 0 || m.Width() != 0 {
+		t.Errorf("Clear后不应有节点，当前长度为%d", m.Size())
+	}
+	m.AddOrGet(0, 1, 1, false)
+	if ret, in := m.Get(0, 1); !in || ret != 1 {
+		t.Errorf("查找失败，Expected %v found %v", 1, ret)
+	}
+
+	m.Close()
+}
+
+func TestConcurrentU128IDMapWithSlice(t *testing.T) {
+	m := NewConcurrentU128IDMap("test", 1024)
+	key := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2}
+	m.AddOrGetWithSlice(key, 0, 3, false)
+	if ret, in := m.Get(1, 2); !in || ret != 3 {
+		t.Errorf("查找失败，Expected %v found %v", 3, ret)
+	}
+	if ret, in := m.GetWithSlice(key, 0); !in || ret != 3 {
+		t.Errorf("查找失败，Expected %v found %v", 3, ret)
+	}
+}
+
+// 多个读者与一个写者并发，读到的value必须是写者写入过的值
+func TestConcurrentU128IDMapConcurrent(t *testing.T) {
+	const keys = 4096
+	m := NewConcurrentU128IDMap("test", 256)
+
+	var stop uint32
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(g)))
+			for atomic.LoadUint32(&stop) == 0 {
+				key := uint64(r.Intn(keys))
+				// value为key或key+keys（覆写）
+				if value, in := m.Get(key>>8, key); in && uint64(value) != key && uint64(value) != key+keys {
+					t.Errorf("key %d 的value不正确，为%d", key, value)
+					return
+				}
+				m.Size()
+				m.Width()
+			}
+		}(g)
+	}
+
+	for round := 0; round < 4; round++ {
+		for i := uint64(0); i < keys; i++ {
+			m.AddOrGet(i>>8, i, uint32(i), false)
+		}
+		for i := uint64(0); i < keys; i += 2 {
+			m.AddOrGet(i>>8, i, uint32(i+keys), true)
+		}
+		if m.Size() != keys {
+			t.Errorf("当前长度，Expected %v found %v", keys, m.Size())
+		}
+		m.GetCounter()
+		m.Clear()
+	}
+	atomic.StoreUint32(&stop, 1)
+	wg.Wait()
+}
+
+func benchmarkConcurrentU128IDMap(b *testing.B) (*ConcurrentU128IDMap, []uint64) {
+	size := 1 << 20
+	m := NewConcurrentU128IDMap("test", uint32(size))
+	for i := 0; i < size; i++ {
+		m.AddOrGet(0, uint64(i), uint32(i), false)
+	}
+	keys := make([]uint64, 1<<16)
+	for i := range keys {
+		keys[i] = uint64(rand.Intn(size))
+	}
+	b.ResetTimer()
+	return m, keys
+}
+
+func benchmarkSyncMap(b *testing.B) (*sync.Map, []uint64) {
+	size := 1 << 20
+	m := &sync.Map{}
+	for i := 0; i < size; i++ {
+		m.Store(testU128MapKey{0, uint64(i)}, uint32(i))
+	}
+	keys := make([]uint64, 1<<16)
+	for i := range keys {
+		keys[i] = uint64(rand.Intn(size))
+	}
+	b.ResetTimer()
+	return m, keys
+}
+
+func BenchmarkConcurrentU128IDMapGet(b *testing.B) {
+	m, keys := benchmarkConcurrentU128IDMap(b)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := rand.Int(); pb.Next(); i++ {
+			m.Get(0, keys[i&(len(keys)-1)])
+		}
+	})
+}
+
+func BenchmarkSyncMapGet(b *testing.B) {
+	m, keys := benchmarkSyncMap(b)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := rand.Int(); pb.Next(); i++ {
+			m.Load(testU128MapKey{0, keys[i&(len(keys)-1)]})
+		}
+	})
+}
+
+// 每64次操作中有一次写入新的key
+func BenchmarkConcurrentU128IDMapReadMostly(b *testing.B) {
+	m, keys := benchmarkConcurrentU128IDMap(b)
+	var next uint64 = 1 << 20
+	b.RunParallel(func(pb *testing.PB) {
+		for i := rand.Int(); pb.Next(); i++ {
+			if i&63 == 0 {
+				key := atomic.AddUint64(&next, 1)
+				m.AddOrGet(0, key, uint32(key), false)
+			} else {
+				m.Get(0, keys[i&(len(keys)-1)])
+			}
+		}
+	})
+}
+
+func BenchmarkSyncMapReadMostly(b *testing.B) {
+	m, keys := benchmarkSyncMap(b)
+	var next uint64 = 1 << 20
+	b.RunParallel(func(pb *testing.PB) {
+		for i := rand.Int(); pb.Next(); i++ {
+			if i&63 == 0 {
+				key := atomic.AddUint64(&next, 1)
+				m.LoadOrStore(testU128MapKey{0, key}, uint32(key))
+			} else {
+				m.Load(testU128MapKey{0, keys[i&(len(keys)-1)]})
+			}
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package idmap
 
+import "fmt"
+
 type UBigIDMap interface {
 	AddOrGetWithSlice(key []byte, hash uint32, value uint32, overwrite bool) (uint32, bool)
 	GetWithSlice(key []byte, hash uint32) (uint32, bool)
@@ -8,3 +10,38 @@ type UBigIDMap interface {
 	Width() int
 	Clear()
 }
+
+// 所有宽度的ID map都实现的接口，可以在配置时通过NewIDMap选择key的宽度，
+// KeySize()为0时key的长度任意
+type IDMap interface {
+	UBigIDMap
+
+	ID() string
+	KeySize() int
+	Close() error
+	GetCounter() interface{}
+}
+
+// key长度（字节）到UBig ID map构造函数的映射，由生成的代码注册
+var ubigIDMapConstructors = map[int]func(module string, hashSlots uint32) IDMap{}
+
+// 返回key长度为keySize字节的ID map，支持16字节以及ubig_id_map.tmpldata中的长度，
+// keySize为0时返回key长度任意的BytesIDMap
+func NewIDMap(module string, keySize int, hashSlots uint32) (IDMap, error) {
+	switch keySize {
+	case 0:
+		return NewBytesIDMap(module, hashSlots), nil
+	case 128 / 8:
+		return NewU128IDMap(module, hashSlots), nil
+	}
+	if newIDMap, ok := ubigIDMapConstructors[keySize]; ok {
+		return newIDMap(module, hashSlots), nil
+	}
+	return nil, fmt.Errorf("unsupported idmap key size %d", keySize)
+}
+
+var (
+	_ IDMap = &U128IDMap{}
+	_ IDMap = &ConcurrentU128IDMap{}
+	_ IDMap = &BytesIDMap{}
+)
@@ -0,0 +1,47 @@
+package idmap
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestNewIDMap(t *testing.T) {
+	keySizes := []int{0, 16}
+	for keySize := range ubigIDMapConstructors {
+		keySizes = append(keySizes, keySize)
+	}
+	for _, keySize := range keySizes {
+		m, err := NewIDMap("test", keySize, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.KeySize() != keySize {
+			t.Errorf("KeySize预期为%d，实际为%d", keySize, m.KeySize())
+		}
+		// keySize为0时使用4字节的key
+		key := make([]byte, keySize)
+		if keySize == 0 {
+			key = make([]byte, 4)
+		}
+		for i := uint32(0); i < 100; i++ {
+			binary.BigEndian.PutUint32(key[len(key)-4:], i)
+			if _, added := m.AddOrGetWithSlice(key, i, i, false); !added {
+				t.Errorf("%s: 第一次插入，Expected %v found %v", m.ID(), true, added)
+			}
+		}
+		for i := uint32(0); i < 100; i++ {
+			binary.BigEndian.PutUint32(key[len(key)-4:], i)
+			if value, in := m.GetWithSlice(key, i); !in || value != i {
+				t.Errorf("%s: 查找失败，Expected %v found %v", m.ID(), i, value)
+			}
+		}
+		if m.Size() != 100 {
+			t.Errorf("%s: 当前长度，Expected %v found %v", m.ID(), 100, m.Size())
+		}
+		m.Clear()
+		m.Close()
+	}
+	if _, err := NewIDMap("test", 8, 64); err == nil {
+		t.Error("不支持的key长度应返回错误")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/SophonMesh/go-libs/hmap"
 	"github.com/SophonMesh/go-libs/hmap/keyhash"
+	"github.com/SophonMesh/go-libs/hmap/offheap"
 )
 
 type u128IDMapNode struct {
@@ -44,12 +45,22 @@ type U128IDMap struct {
 
 	counter *Counter
 
+	batchSlots hmap.BatchSlots // 批量操作时预先计算的哈希桶
+
 	collisionChainDebugThreshold uint32       // scan宽度超过该值时保留冲突链信息，为0时不保存
 	debugChain                   atomic.Value // 冲突链，类型为[]byte
 	debugChainRead               uint32       // 冲突链是否已读，如果已读替换为新的 (atomic.Value无法清空)
+
+	storage *offheap.Storage  // 不为nil时哈希桶和节点块位于mmap映射的内存中
+	meta    *u128IDMapOffHeap // 位于storage中
 }
 
-func NewU128IDMap(module string, hashSlots uint32) *U128IDMap {
+// 保存在offheap.Storage中的状态
+type u128IDMapOffHeap struct {
+	size uint64
+}
+
+func u128IDMapHashSlots(hashSlots uint32) (uint32, uint32) {
 	if hashSlots >= 1<<30 {
 		panic("hashSlots is too large")
 	}
@@ -57,7 +68,11 @@ func NewU128IDMap(module string, hashSlots uint32) *U128IDMap {
 	i := uint32(1)
 	for ; 1<<i < hashSlots; i++ {
 	}
-	hashSlots = 1 << i
+	return 1 << i, i
+}
+
+func NewU128IDMap(module string, hashSlots uint32) *U128IDMap {
+	hashSlots, i := u128IDMapHashSlots(hashSlots)
 
 	m := &U128IDMap{
 		buffer:       make([]u128IDMapNodeBlock, 0),
@@ -74,6 +89,70 @@ func NewU128IDMap(module string, hashSlots uint32) *U128IDMap {
 	return m
 }
 
+// 哈希桶和节点块位于mmap映射的内存中，不由GC管理，适用于节点数很多的场景：
+//   - path为空时使用匿名映射
+//   - path不为空时映射该文件，以相同的hashSlots重新打开时恢复其中的数据，
+//     进程异常退出后重新打开也能得到一致的数据，机器掉电前需要调用Sync
+//   - 同一文件同时只能被一个U128IDMap打开，见offheap.Storage
+//   - Close后不能再使用
+func NewOffHeapU128IDMap(module string, hashSlots uint32, path string) (*U128IDMap, error) {
+	hashSlots, i := u128IDMapHashSlots(hashSlots)
+	storage, err := offheap.Open(path, "idmap128", int(hashSlots)*4, _BLOCK_SIZE*offheap.SizeOf[u128IDMapNode](), offheap.Layout[u128IDMapNode]())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &U128IDMap{
+		buffer:       make([]u128IDMapNodeBlock, 0),
+		slotHead:     offheap.Slice[int32](storage.Slots()),
+		hashSlotBits: i,
+		counter:      &Counter{},
+		id:           "idmap128-" + module,
+		storage:      storage,
+		meta:         offheap.Pointer[u128IDMapOffHeap](storage.Meta()),
+	}
+
+	if storage.Created() {
+		for i := range m.slotHead {
+			m.slotHead[i] = -1
+		}
+	} else {
+		// width只统计打开之后的插入
+		m.size = int(m.meta.size)
+		for i, head := range m.slotHead {
+			if head >= int32(m.size) {
+				m.slotHead[i] = -1
+			}
+		}
+		for len(m.buffer)<<_BLOCK_SIZE_BITS < m.size {
+			m.buffer = append(m.buffer, m.newBlock())
+		}
+		m.counter.Size = m.size
+	}
+
+	return m, nil
+}
+
+func (m *U128IDMap) newBlock() u128IDMapNodeBlock {
+	if m.storage == nil {
+		return u128IDMapNodeBlockPool.Get().(u128IDMapNodeBlock)
+	}
+	// 与内存不足时的行为一致，映射失败时panic
+	block, err := m.storage.Block(len(m.buffer))
+	if err != nil {
+		panic(err)
+	}
+	return offheap.Slice[u128IDMapNode](block)
+}
+
+// 将mmap映射的内容写回文件，不是NewOffHeapU128IDMap创建的时不做任何操作
+func (m *U128IDMap) Sync() error {
+	if m.storage == nil {
+		return nil
+	}
+	return m.storage.Sync()
+}
+
 func (m *U128IDMap) ID() string {
 	return m.id
 }
@@ -84,7 +163,13 @@ func (m *U128IDMap) KeySize() int {
 
 func (m *U128IDMap) Close() error {
 	hmap.DeregisterForDebug(m)
-	return nil
+	if m.storage == nil {
+		return nil
+	}
+	err := m.storage.Close()
+	m.storage, m.meta = nil, nil
+	m.buffer, m.slotHead = nil, nil
+	return err
 }
 
 func (m *U128IDMap) Size() int {
@@ -99,8 +184,17 @@ func (m *U128IDMap) compressHash(key0, key1 uint64) int32 {
 	return keyhash.Jenkins128(key0, key1) & int32(len(m.slotHead)-1)
 }
 
-func (m *U128IDMap) find(key0, key1 uint64, isAdd bool) *u128IDMapNode {
-	slot := m.compressHash(key0, key1)
+// 在遍历冲突链之前计算整批key的哈希桶，key0s与key1s的长度需相同
+func (m *U128IDMap) batchHashSlots(key0s, key1s []uint64) []int32 {
+	hmap.CheckBatchInput(len(key0s), len(key1s))
+	slots := m.batchSlots.Resize(len(key0s))
+	for i := range slots {
+		slots[i] = m.compressHash(key0s[i], key1s[i])
+	}
+	return slots
+}
+
+func (m *U128IDMap) find(key0, key1 uint64, slot int32, isAdd bool) *u128IDMapNode {
 	head := m.slotHead[slot]
 
 	m.counter.scanTimes++
@@ -189,9 +283,8 @@ func (m *U128IDMap) SetCollisionChainDebugThreshold(t int) {
 	}
 }
 
-// 第一个返回值表示value，第二个返回值表示是否进行了Add。若key已存在，指定overwrite=true可覆写value。
-func (m *U128IDMap) AddOrGet(key0, key1 uint64, value uint32, overwrite bool) (uint32, bool) {
-	node := m.find(key0, key1, true)
+func (m *U128IDMap) addOrGet(key0, key1 uint64, slot int32, value uint32, overwrite bool) (uint32, bool) {
+	node := m.find(key0, key1, slot, true)
 	if node != nil {
 		if overwrite {
 			node.value = value
@@ -199,11 +292,10 @@ func (m *U128IDMap) AddOrGet(key0, key1 uint64, value uint32, overwrite bool) (u
 		return node.value, false
 	}
 
-	slot := m.compressHash(key0, key1)
 	head := m.slotHead[slot]
 
 	if m.size >= len(m.buffer)<<_BLOCK_SIZE_BITS { // expand
-		m.buffer = append(m.buffer, u128IDMapNodeBlockPool.Get().(u128IDMapNodeBlock))
+		m.buffer = append(m.buffer, m.newBlock())
 	}
 	node = &m.buffer[m.size>>_BLOCK_SIZE_BITS][m.size&_BLOCK_SIZE_MASK]
 	node.key0 = key0
@@ -212,6 +304,10 @@ func (m *U128IDMap) AddOrGet(key0, key1 uint64, value uint32, overwrite bool) (u
 	node.next = head
 	node.slot = int32(slot)
 
+	if m.meta != nil {
+		// 先记录节点数再加入冲突链，进程在两者之间退出时冲突链中不会出现无效的节点
+		m.meta.size = uint64(m.size + 1)
+	}
 	m.slotHead[slot] = int32(m.size)
 	m.size++
 
@@ -222,6 +318,29 @@ func (m *U128IDMap) AddOrGet(key0, key1 uint64, value uint32, overwrite bool) (u
 	return value, true
 }
 
+// 第一个返回值表示value，第二个返回值表示是否进行了Add。若key已存在，指定overwrite=true可覆写value。
+func (m *U128IDMap) AddOrGet(key0, key1 uint64, value uint32, overwrite bool) (uint32, bool) {
+	return m.addOrGet(key0, key1, m.compressHash(key0, key1), value, overwrite)
+}
+
+// 批量AddOrGet，第i个key的结果写入results[i]和added[i]，含义与AddOrGet的返回值相同
+// slice长度的约定见hmap.CheckBatchInput，不满足时panic
+func (m *U128IDMap) AddOrGetBatch(key0s, key1s []uint64, values []uint32, overwrite bool, results []uint32, added []bool) {
+	hmap.CheckBatchInput(len(key0s), len(values))
+	hmap.CheckBatchOutput(len(key0s), results)
+	hmap.CheckBatchOutput(len(key0s), added)
+	slots := m.batchHashSlots(key0s, key1s)
+	for i := range slots {
+		value, ok := m.addOrGet(key0s[i], key1s[i], slots[i], values[i], overwrite)
+		if results != nil {
+			results[i] = value
+		}
+		if added != nil {
+			added[i] = ok
+		}
+	}
+}
+
 func (m *U128IDMap) AddOrGetWithSlice(key []byte, _ uint32, value uint32, overwrite bool) (uint32, bool) {
 	if len(key) != 16 {
 		panic("传入key的长度不等于 16 字节")
@@ -230,12 +349,32 @@ func (m *U128IDMap) AddOrGetWithSlice(key []byte, _ uint32, value uint32, overwr
 }
 
 func (m *U128IDMap) Get(key0, key1 uint64) (uint32, bool) {
-	if node := m.find(key0, key1, false); node != nil {
+	if node := m.find(key0, key1, m.compressHash(key0, key1), false); node != nil {
 		return node.value, true
 	}
 	return 0, false
 }
 
+// 批量查询，第i个key对应的value和是否存在写入values[i]和found[i]
+// slice长度的约定见hmap.CheckBatchInput，不满足时panic
+func (m *U128IDMap) GetBatch(key0s, key1s []uint64, values []uint32, found []bool) {
+	hmap.CheckBatchOutput(len(key0s), values)
+	hmap.CheckBatchOutput(len(key0s), found)
+	slots := m.batchHashSlots(key0s, key1s)
+	for i := range slots {
+		value, ok := uint32(0), false
+		if node := m.find(key0s[i], key1s[i], slots[i], false); node != nil {
+			value, ok = node.value, true
+		}
+		if values != nil {
+			values[i] = value
+		}
+		if found != nil {
+			found[i] = ok
+		}
+	}
+}
+
 func (m *U128IDMap) GetWithSlice(key []byte, _ uint32) (uint32, bool) {
 	if len(key) != 16 {
 		panic("传入key的长度不等于 16 字节")
@@ -249,17 +388,32 @@ func (m *U128IDMap) GetCounter() interface{} {
 	if counter.scanTimes != 0 {
 		counter.AvgScan = counter.totalScan / counter.scanTimes
 	}
+	counter.SetMemoryUsage(m.MemoryUsage())
 	return counter
 }
 
+// 返回占用的内存，NewOffHeapU128IDMap创建时为mmap映射中的哈希桶和节点块
+func (m *U128IDMap) MemoryUsage() hmap.MemoryUsage {
+	usage := hmap.NodeBlocksUsage(m.buffer, m.size)
+	usage.SlotHeads = len(m.slotHead) * 4
+	usage.Others += cap(m.batchSlots) * 4
+	return usage
+}
+
 func (m *U128IDMap) Clear() {
+	if m.meta != nil {
+		// 先清空节点数，进程在Clear过程中退出时，重新打开后所有的哈希桶都会被清空
+		m.meta.size = 0
+	}
 	for i := 0; i < m.size; i += _BLOCK_SIZE {
 		for j := 0; j < _BLOCK_SIZE && i+j < m.size; j++ {
 			node := &m.buffer[i>>_BLOCK_SIZE_BITS][j]
 			m.slotHead[node.slot] = -1
 			*node = blankU128MapNodeForInit
 		}
-		u128IDMapNodeBlockPool.Put(m.buffer[i>>_BLOCK_SIZE_BITS])
+		if m.storage == nil {
+			u128IDMapNodeBlockPool.Put(m.buffer[i>>_BLOCK_SIZE_BITS])
+		}
 		m.buffer[i>>_BLOCK_SIZE_BITS] = nil
 	}
 
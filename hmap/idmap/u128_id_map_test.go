@@ -3,7 +3,10 @@ package idmap
 import (
 	"bytes"
 	"encoding/binary"
+	"math/rand"
+	"path/filepath"
 	"testing"
+	"unsafe"
 
 	"github.com/SophonMesh/go-libs/hmap"
 )
@@ -111,6 +114,120 @@ func TestU128IDMapClear(t *testing.T) {
 	m.Close()
 }
 
+func TestU128IDMapBatch(t *testing.T) {
+	m := NewU128IDMap("test", 1024)
+	defer m.Close()
+
+	key0s := []uint64{0, 0, 1, 0}
+	key1s := []uint64{1, 2, 0, 1}
+	results := make([]uint32, len(key0s))
+	added := make([]bool, len(key0s))
+	m.AddOrGetBatch(key0s, key1s, []uint32{1, 2, 3, 4}, false, results, added)
+	expectedResults := []uint32{1, 2, 3, 1}
+	expectedAdded := []bool{true, true, true, false}
+	for i := range key0s {
+		if results[i] != expectedResults[i] || added[i] != expectedAdded[i] {
+			t.Errorf("AddOrGetBatch第%d个结果，Expected %v %v found %v %v", i, expectedResults[i], expectedAdded[i], results[i], added[i])
+		}
+	}
+	m.AddOrGetBatch([]uint64{0}, []uint64{2}, []uint32{5}, true, nil, nil)
+
+	key0s = []uint64{0, 0, 2}
+	key1s = []uint64{1, 2, 2}
+	values := make([]uint32, len(key0s))
+	found := make([]bool, len(key0s))
+	m.GetBatch(key0s, key1s, values, found)
+	expectedValues := []uint32{1, 5, 0}
+	expectedFound := []bool{true, true, false}
+	for i := range key0s {
+		if values[i] != expectedValues[i] || found[i] != expectedFound[i] {
+			t.Errorf("GetBatch第%d个结果，Expected %v %v found %v %v", i, expectedValues[i], expectedFound[i], values[i], found[i])
+		}
+	}
+	if m.Size() != 3 {
+		t.Errorf("当前长度，Expected %v found %v", 3, m.Size())
+	}
+
+	for _, f := range []func(){
+		func() { m.AddOrGetBatch(key0s, key1s, []uint32{1}, false, nil, nil) },
+		func() { m.AddOrGetBatch(key0s, key1s[:1], []uint32{1, 2, 3}, false, nil, nil) },
+		func() { m.GetBatch(key0s, key1s, values[:1], nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("长度不一致时应panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestOffHeapU128IDMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idmap")
+	for _, p := range []string{"", path} {
+		m, err := NewOffHeapU128IDMap("test", 1024, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 超过一个chunk的节点数
+		n := uint64(100000)
+		for i := uint64(0); i < n; i++ {
+			if _, added := m.AddOrGet(i, i*2, uint32(i), false); !added {
+				t.Fatalf("第一次插入，Expected %v found %v", true, added)
+			}
+		}
+		for i := uint64(0); i < n; i++ {
+			if value, in := m.Get(i, i*2); !in || value != uint32(i) {
+				t.Fatalf("查找失败，Expected %v found %v", i, value)
+			}
+		}
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重新打开后数据不变，可以继续插入
+	if _, err := NewOffHeapU128IDMap("test", 2048, path); err == nil {
+		t.Error("hashSlots不同时应返回错误")
+	}
+	m, err := NewOffHeapU128IDMap("test", 1024, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Size() != 100000 {
+		t.Errorf("当前长度，Expected %v found %v", 100000, m.Size())
+	}
+	if value, in := m.Get(99999, 99999*2); !in || value != 99999 {
+		t.Errorf("查找失败，Expected %v found %v", 99999, value)
+	}
+	if _, added := m.AddOrGet(1, 2, 3, false); added {
+		t.Errorf("插入同样的值，Expected %v found %v", false, added)
+	}
+	if _, added := m.AddOrGet(1, 3, 3, false); !added {
+		t.Errorf("插入不同的值，Expected %v found %v", true, added)
+	}
+	m.Clear()
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	m, err = NewOffHeapU128IDMap("test", 1024, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, in := m.Get(1, 2); in || m.Size() != 0 {
+		t.Errorf("Clear后当前长度，Expected %v found %v", 0, m.Size())
+	}
+	m.AddOrGet(1, 2, 3, false)
+	if value, in := m.Get(1, 2); !in || value != 3 {
+		t.Errorf("查找失败，Expected %v found %v", 3, value)
+	}
+}
+
 func BenchmarkU128IDMap(b *testing.B) {
 	m := NewU128IDMap("test", 1<<26)
 
@@ -160,6 +277,63 @@ type testU128MapKey struct {
 	key1 uint64
 }
 
+const _BATCH_SIZE = 256
+
+// 随机访问超过cache大小的IDMap，对比单个查询与批量查询
+func benchmarkU128IDMapRandom(b *testing.B) (*U128IDMap, []uint64) {
+	size := 1 << 22
+	m := NewU128IDMap("test", uint32(size))
+	for i := 0; i < size; i++ {
+		m.AddOrGet(0, uint64(i), uint32(i), false)
+	}
+	keys := make([]uint64, 1<<16)
+	for i := range keys {
+		keys[i] = uint64(rand.Intn(size))
+	}
+	b.ResetTimer()
+	return m, keys
+}
+
+func BenchmarkU128IDMapGetRandom(b *testing.B) {
+	m, keys := benchmarkU128IDMapRandom(b)
+	for i := 0; i < b.N; i++ {
+		m.Get(0, keys[i&(len(keys)-1)])
+	}
+	m.Close()
+}
+
+func BenchmarkU128IDMapGetBatch(b *testing.B) {
+	m, keys := benchmarkU128IDMapRandom(b)
+	key0s := make([]uint64, _BATCH_SIZE)
+	values := make([]uint32, _BATCH_SIZE)
+	found := make([]bool, _BATCH_SIZE)
+	for i := 0; i < b.N; i += _BATCH_SIZE {
+		start := i & (len(keys) - 1)
+		m.GetBatch(key0s, keys[start:start+_BATCH_SIZE], values, found)
+	}
+	m.Close()
+}
+
+func BenchmarkU128IDMapAddOrGetRandom(b *testing.B) {
+	m, keys := benchmarkU128IDMapRandom(b)
+	for i := 0; i < b.N; i++ {
+		m.AddOrGet(0, keys[i&(len(keys)-1)], 0, false)
+	}
+	m.Close()
+}
+
+func BenchmarkU128IDMapAddOrGetBatch(b *testing.B) {
+	m, keys := benchmarkU128IDMapRandom(b)
+	key0s := make([]uint64, _BATCH_SIZE)
+	values := make([]uint32, _BATCH_SIZE)
+	results := make([]uint32, _BATCH_SIZE)
+	for i := 0; i < b.N; i += _BATCH_SIZE {
+		start := i & (len(keys) - 1)
+		m.AddOrGetBatch(key0s, keys[start:start+_BATCH_SIZE], values, false, results, nil)
+	}
+	m.Close()
+}
+
 func BenchmarkNativeStructMap(b *testing.B) {
 	m := make(map[testU128MapKey]uint32)
 	key := testU128MapKey{}
@@ -228,3 +402,32 @@ func TestU128IDMapCollisionChain(t *testing.T) {
 
 	m.Close()
 }
+
+func TestU128IDMapMemoryUsage(t *testing.T) {
+	m := NewU128IDMap("test", 1024)
+	defer m.Close()
+	nodeSize := int(unsafe.Sizeof(u128IDMapNode{}))
+
+	for i := 0; i < 300; i++ {
+		m.AddOrGet(0, uint64(i), uint32(i), false)
+	}
+	usage := m.MemoryUsage()
+	if usage.SlotHeads != 1024*4 {
+		t.Errorf("哈希桶结果预期为%d，实际为%d", 1024*4, usage.SlotHeads)
+	}
+	if usage.BlocksInUse != 300*nodeSize || usage.BlocksReserved != (2*_BLOCK_SIZE-300)*nodeSize {
+		t.Errorf("节点块结果预期为%d/%d，实际为%d/%d",
+			300*nodeSize, (2*_BLOCK_SIZE-300)*nodeSize, usage.BlocksInUse, usage.BlocksReserved)
+	}
+	counter := m.GetCounter().(*Counter)
+	if counter.MemorySlotHeads != usage.SlotHeads || counter.MemoryBlocksInUse != usage.BlocksInUse ||
+		counter.MemoryBlocksReserved != usage.BlocksReserved || counter.MemoryOthers != usage.Others {
+		t.Errorf("Counter结果预期为%v，实际为%v", usage, counter)
+	}
+
+	m.Clear()
+	usage = m.MemoryUsage()
+	if usage.BlocksInUse != 0 || usage.BlocksReserved != 0 {
+		t.Errorf("Clear后节点块预期为0，实际为%v", usage)
+	}
+}
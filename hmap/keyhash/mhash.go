@@ -36,3 +36,25 @@ func HashAdd(hash, data uint32) uint32 {
 func HashFinish(hash uint32) uint32 {
 	return mhashFinish(hash)
 }
+
+// 变长key的哈希，按小端序每4字节调用一次mhashAdd，[]byte和string的结果相同
+func mhashBytes[T string | []byte](key T) uint32 {
+	hash := uint32(0)
+	i := 0
+	for ; i+4 <= len(key); i += 4 {
+		hash = mhashAdd(hash, uint32(key[i])|uint32(key[i+1])<<8|uint32(key[i+2])<<16|uint32(key[i+3])<<24)
+	}
+	tail := uint32(0)
+	for shift := 0; i < len(key); i, shift = i+1, shift+8 {
+		tail |= uint32(key[i]) << shift
+	}
+	return mhashFinish(mhashAddInner(hash, tail) ^ uint32(len(key)))
+}
+
+func HashBytes(key []byte) uint32 {
+	return mhashBytes(key)
+}
+
+func HashString(key string) uint32 {
+	return mhashBytes(key)
+}
@@ -0,0 +1,31 @@
+package keyhash
+
+import (
+	"testing"
+)
+
+func TestHashBytes(t *testing.T) {
+	keys := []string{"", "a", "ab", "abc", "abcd", "abcde", "/api/v1/users", "/api/v1/user", "SELECT * FROM t WHERE id = ?"}
+	seen := make(map[uint32]string)
+	for _, key := range keys {
+		hash := HashBytes([]byte(key))
+		if hash != HashString(key) {
+			t.Errorf("%q: []byte和string的哈希值不同", key)
+		}
+		if other, in := seen[hash]; in {
+			t.Errorf("%q与%q的哈希值相同", key, other)
+		}
+		seen[hash] = key
+	}
+	// 末尾的0也会改变哈希值
+	if HashBytes([]byte{1}) == HashBytes([]byte{1, 0}) || HashBytes([]byte{0, 0, 0, 0}) == HashBytes([]byte{0, 0, 0, 0, 0}) {
+		t.Error("长度不同的key哈希值相同")
+	}
+}
+
+func BenchmarkHashString(b *testing.B) {
+	key := "/api/v1/users/profile?id=12345"
+	for i := 0; i < b.N; i++ {
+		HashString(key)
+	}
+}
@@ -0,0 +1,480 @@
+package lru
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/SophonMesh/go-libs/hmap"
+)
+
+type lruNode[K comparable] struct {
+	key   K
+	value interface{}
+
+	hash         uint32 // key的哈希值，用于计算哈希桶和查询访问频率
+	hashListNext int32  // 表示节点所在冲突链的下一个节点的 buffer 数组下标，-1 表示不存在
+	hashListPrev int32  // 表示节点所在冲突链的上一个节点的 buffer 数组下标，-1 表示不存在
+	listNext     int32  // 淘汰链表，含义与冲突链类似
+	listPrev     int32  // 淘汰链表，含义与冲突链类似
+	list         uint8  // 节点所在的淘汰链表
+}
+
+type lruList struct {
+	head int32 // 最近访问的节点
+	tail int32 // 最先被淘汰的节点
+	size int
+}
+
+// U64LRU、U128LRU共用的节点存储、哈希链和淘汰链表，淘汰策略见Policy
+// key的哈希值由外层计算后传入，节点中保存哈希值，删除时不需要重新计算
+type baseLRU[K comparable] struct {
+	id     string
+	policy Policy
+
+	ringBuffer       [][]lruNode[K] // 存储Map节点，以矩阵环的方式组织，提升内存申请释放效率
+	bufferStartIndex int32          // ringBuffer中的开始下标（二维矩阵下标），闭区间
+	bufferEndIndex   int32          // ringBuffer中的结束下标（二维矩阵下标），开区间
+
+	hashSlots    int32   // 上取整至2^N，哈希桶个数
+	hashSlotHead []int32 // 哈希桶，hashSlotHead[i] 表示哈希值为 i 的冲突链的第一个节点为 buffer[[ hashSlotHead[i] ]]
+
+	lists             []lruList
+	protectedCapacity int             // 保护区的容量，用于POLICY_SLRU和POLICY_W_TINY_LFU
+	windowCapacity    int             // 窗口的容量，用于POLICY_W_TINY_LFU
+	sketch            *countMinSketch // 用于POLICY_W_TINY_LFU
+	accesses          int             // 上次访问次数减半后的访问次数，用于POLICY_LFU
+
+	capacity int // 最大容纳的Flow个数
+	size     int // 当前容纳的Flow个数
+
+	keySize int
+	putKey  func(bs []byte, key K) // 将key写入冲突链
+
+	nodeBlockPool *sync.Pool // 同一key类型的LRU共用
+
+	counter *Counter
+
+	batchHashes hmap.BatchSlots // 批量操作时预先计算的哈希值
+
+	collisionChainDebugThreshold uint32       // scan宽度超过该值时保留冲突链信息，为0时不保存
+	debugChain                   atomic.Value // 冲突链，类型为[]byte
+	debugChainRead               uint32       // 冲突链是否已读，如果已读替换为新的 (atomic.Value无法清空)
+}
+
+// policy不合法时使用POLICY_LRU，POLICY_LRU的id为idPrefix+module，其它策略在module前加上策略名
+// capacity不大于0时为1，避免Add时分配节点越界
+func (m *baseLRU[K]) init(idPrefix, module string, policy Policy, hashSlots, capacity, keySize int, putKey func(bs []byte, key K), nodeBlockPool *sync.Pool) {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	hashSlots, _ = minPowerOfTwo(hashSlots)
+
+	m.ringBuffer = make([][]lruNode[K], (capacity+_BLOCK_SIZE)/_BLOCK_SIZE+1)
+	m.hashSlots = int32(hashSlots)
+	m.hashSlotHead = make([]int32, hashSlots)
+	for i := range m.hashSlotHead {
+		m.hashSlotHead[i] = -1
+	}
+	m.capacity = capacity
+	m.keySize = keySize
+	m.putKey = putKey
+	m.nodeBlockPool = nodeBlockPool
+	m.counter = &Counter{}
+
+	m.policy = policy
+	m.initPolicy()
+	m.id = idPrefix + module
+	if m.policy != POLICY_LRU {
+		m.id = idPrefix + m.policy.String() + "-" + module
+	}
+}
+
+func (m *baseLRU[K]) ID() string {
+	return m.id
+}
+
+func (m *baseLRU[K]) KeySize() int {
+	return m.keySize
+}
+
+func (m *baseLRU[K]) Close() error {
+	hmap.DeregisterForDebug(m)
+	return nil
+}
+
+func (m *baseLRU[K]) Size() int {
+	return m.size
+}
+
+func (m *baseLRU[K]) Policy() Policy {
+	return m.policy
+}
+
+func (m *baseLRU[K]) incIndex(index int32) int32 {
+	index++
+	if index>>_BLOCK_SIZE_BITS >= int32(len(m.ringBuffer)) {
+		return 0
+	}
+	return index
+}
+
+func (m *baseLRU[K]) getNode(index int32) *lruNode[K] {
+	return &m.ringBuffer[index>>_BLOCK_SIZE_BITS][index&_BLOCK_SIZE_MASK]
+}
+
+func (m *baseLRU[K]) compressHash(hash uint32) int32 {
+	return int32(hash) & (m.hashSlots - 1)
+}
+
+func (m *baseLRU[K]) pushNodeToHashList(node *lruNode[K], nodeIndex int32) {
+	slot := m.compressHash(node.hash)
+	node.hashListNext = m.hashSlotHead[slot]
+	node.hashListPrev = -1
+	if node.hashListNext != -1 {
+		m.getNode(node.hashListNext).hashListPrev = nodeIndex
+	}
+	m.hashSlotHead[slot] = nodeIndex
+}
+
+// 与removeNodeFromList相同，不修改链表的size
+func (m *baseLRU[K]) pushNodeToList(node *lruNode[K], nodeIndex int32, list uint8) {
+	l := &m.lists[list]
+	node.list = list
+	node.listNext = l.head
+	node.listPrev = -1
+	if node.listNext != -1 {
+		m.getNode(node.listNext).listPrev = nodeIndex
+	}
+	l.head = nodeIndex
+	if l.tail == -1 {
+		l.tail = nodeIndex
+	}
+}
+
+func (m *baseLRU[K]) removeNodeFromHashList(node *lruNode[K], newNext, newPrev int32) {
+	if node.hashListPrev != -1 {
+		prevNode := m.getNode(node.hashListPrev)
+		prevNode.hashListNext = newNext
+	} else {
+		m.hashSlotHead[m.compressHash(node.hash)] = newNext
+	}
+
+	if node.hashListNext != -1 {
+		nextNode := m.getNode(node.hashListNext)
+		nextNode.hashListPrev = newPrev
+	}
+}
+
+// 不修改链表的size，从链表中删除节点时需要调用方修改
+func (m *baseLRU[K]) removeNodeFromList(node *lruNode[K], newNext, newPrev int32) {
+	l := &m.lists[node.list]
+	if node.listPrev != -1 {
+		prevNode := m.getNode(node.listPrev)
+		prevNode.listNext = newNext
+	} else {
+		l.head = newNext
+	}
+
+	if node.listNext != -1 {
+		nextNode := m.getNode(node.listNext)
+		nextNode.listPrev = newPrev
+	} else {
+		l.tail = newPrev
+	}
+}
+
+// 将节点移动至链表list的头部
+func (m *baseLRU[K]) moveNodeToList(node *lruNode[K], nodeIndex int32, list uint8) {
+	if node.list == list {
+		m.moveNodeToHead(node, nodeIndex)
+		return
+	}
+	m.removeNodeFromList(node, node.listNext, node.listPrev)
+	m.lists[node.list].size--
+	m.pushNodeToList(node, nodeIndex, list)
+	m.lists[list].size++
+}
+
+// 将节点移动至所在链表的头部，是访问节点时最常见的情况，节点不在表头时前一个节点一定存在
+func (m *baseLRU[K]) moveNodeToHead(node *lruNode[K], nodeIndex int32) {
+	l := &m.lists[node.list]
+	if l.head == nodeIndex {
+		return
+	}
+	m.getNode(node.listPrev).listNext = node.listNext
+	if node.listNext != -1 {
+		m.getNode(node.listNext).listPrev = node.listPrev
+	} else {
+		l.tail = node.listPrev
+	}
+	m.getNode(l.head).listPrev = nodeIndex
+	node.listNext = l.head
+	node.listPrev = -1
+	l.head = nodeIndex
+}
+
+func (m *baseLRU[K]) removeNode(node *lruNode[K], nodeIndex int32) {
+	// 从哈希链表、淘汰链表中删除
+	m.removeNodeFromHashList(node, node.hashListNext, node.hashListPrev)
+	m.removeNodeFromList(node, node.listNext, node.listPrev)
+	m.lists[node.list].size--
+
+	// 将节点交换至buffer头部
+	if nodeIndex != m.bufferStartIndex {
+		firstNode := m.getNode(m.bufferStartIndex)
+		// 将firstNode内容拷贝至node
+		*node = *firstNode
+		// 修改firstNode在哈希链、淘汰链的上下游指向node
+		m.removeNodeFromHashList(firstNode, nodeIndex, nodeIndex)
+		m.removeNodeFromList(firstNode, nodeIndex, nodeIndex)
+		// 将firstNode初始化
+		*firstNode = lruNode[K]{}
+	} else {
+		*node = lruNode[K]{}
+	}
+
+	// 释放头部节点
+	if m.bufferStartIndex&_BLOCK_SIZE_MASK == _BLOCK_SIZE_MASK {
+		row := m.bufferStartIndex >> _BLOCK_SIZE_BITS
+		m.nodeBlockPool.Put(m.ringBuffer[row])
+		m.ringBuffer[row] = nil
+	}
+	m.bufferStartIndex = m.incIndex(m.bufferStartIndex)
+
+	m.size--
+}
+
+func (m *baseLRU[K]) newNode(key K, hash uint32, value interface{}) {
+	if m.policy != POLICY_W_TINY_LFU && m.size >= m.capacity {
+		victim := m.victim()
+		m.removeNode(m.getNode(victim), victim)
+	}
+
+	row := m.bufferEndIndex >> _BLOCK_SIZE_BITS
+	col := m.bufferEndIndex & _BLOCK_SIZE_MASK
+	if m.ringBuffer[row] == nil {
+		m.ringBuffer[row] = m.nodeBlockPool.Get().([]lruNode[K])
+	}
+	node := &m.ringBuffer[row][col]
+	m.size++
+
+	// 更新key、value
+	node.key = key
+	node.hash = hash
+	node.value = value
+
+	// 新节点加入哈希链、淘汰链
+	m.pushNodeToHashList(node, m.bufferEndIndex)
+	list := m.newNodeList()
+	m.pushNodeToList(node, m.bufferEndIndex, list)
+	m.lists[list].size++
+
+	// 更新buffer信息
+	m.bufferEndIndex = m.incIndex(m.bufferEndIndex)
+
+	if m.policy == POLICY_W_TINY_LFU {
+		m.admit()
+	}
+}
+
+func (m *baseLRU[K]) GetCounter() interface{} {
+	var counter *Counter
+	counter, m.counter = m.counter, &Counter{}
+	if counter.scanTimes != 0 {
+		counter.AvgScan = counter.totalScan / counter.scanTimes
+	}
+	counter.Size = m.size
+	counter.SetMemoryUsage(m.MemoryUsage())
+	return counter
+}
+
+// 返回占用的内存，不包括value指向的内存，淘汰链表表头和Count-Min Sketch计入Others
+func (m *baseLRU[K]) MemoryUsage() hmap.MemoryUsage {
+	usage := hmap.NodeBlocksUsage(m.ringBuffer, m.size)
+	usage.SlotHeads = len(m.hashSlotHead) * 4
+	usage.Others += cap(m.lists) * int(unsafe.Sizeof(lruList{}))
+	usage.Others += cap(m.batchHashes) * 4
+	if m.sketch != nil {
+		usage.Others += len(m.sketch.table) * 8
+	}
+	return usage
+}
+
+// 返回是否添加了新节点，key已存在时更新value并返回false
+func (m *baseLRU[K]) add(key K, hash uint32, value interface{}) bool {
+	if m.sketch != nil {
+		m.sketch.increment(hash)
+	}
+	node, nodeIndex := m.find(key, hash, true)
+	if node != nil {
+		node.value = value
+		m.touchNode(node, nodeIndex)
+		return false
+	}
+	m.newNode(key, hash, value)
+	return true
+}
+
+func (m *baseLRU[K]) remove(key K, hash uint32) bool {
+	for hashListNext := m.hashSlotHead[m.compressHash(hash)]; hashListNext != -1; {
+		node := m.getNode(hashListNext)
+		if node.key == key {
+			m.removeNode(node, hashListNext)
+			return true
+		}
+		hashListNext = node.hashListNext
+	}
+	return false
+}
+
+func (m *baseLRU[K]) get(key K, hash uint32, peek bool) (interface{}, bool) {
+	if !peek && m.sketch != nil {
+		m.sketch.increment(hash)
+	}
+	node, nodeIndex := m.find(key, hash, false)
+	if node == nil {
+		return nil, false
+	}
+	if !peek {
+		m.touchNode(node, nodeIndex)
+	}
+	return node.value, true
+}
+
+// POLICY_LRU是最常用的策略，直接移至表头，减少一层调用
+func (m *baseLRU[K]) touchNode(node *lruNode[K], nodeIndex int32) {
+	if m.policy == POLICY_LRU {
+		m.moveNodeToHead(node, nodeIndex)
+		return
+	}
+	m.accessNode(node, nodeIndex)
+}
+
+func (m *baseLRU[K]) find(key K, hash uint32, isAdd bool) (*lruNode[K], int32) {
+	slot := m.compressHash(hash)
+	m.counter.scanTimes++
+	width := 0
+	for hashListNext := m.hashSlotHead[slot]; hashListNext != -1; {
+		width++
+		node := m.getNode(hashListNext)
+		if node.key == key {
+			m.counter.totalScan += width
+			if width > m.counter.Max {
+				m.counter.Max = width
+			}
+
+			if atomic.LoadUint32(&m.debugChainRead) == 1 {
+				// 已读，构造新的chain
+				if threshold := int(atomic.LoadUint32(&m.collisionChainDebugThreshold)); threshold > 0 && width >= threshold {
+					chain := make([]byte, m.KeySize()*width)
+					m.generateCollisionChainIn(chain, slot)
+					m.debugChain.Store(chain)
+					atomic.StoreUint32(&m.debugChainRead, 0)
+				}
+			}
+			return node, hashListNext
+		}
+		hashListNext = node.hashListNext
+	}
+	m.counter.totalScan += width
+	if isAdd {
+		width++
+	}
+	if width > m.counter.Max {
+		m.counter.Max = width
+	}
+
+	if atomic.LoadUint32(&m.debugChainRead) == 1 {
+		// 已读，构造新的chain
+		if threshold := int(atomic.LoadUint32(&m.collisionChainDebugThreshold)); threshold > 0 && width >= threshold {
+			chain := make([]byte, m.KeySize()*width)
+			offset := 0
+			if isAdd {
+				m.putKey(chain, key)
+				offset += m.KeySize()
+			}
+			m.generateCollisionChainIn(chain[offset:], slot)
+			m.debugChain.Store(chain)
+			atomic.StoreUint32(&m.debugChainRead, 0)
+		}
+	}
+	return nil, -1
+}
+
+func (m *baseLRU[K]) generateCollisionChainIn(bs []byte, index int32) {
+	offset := 0
+	bsLen := len(bs)
+
+	for hashListNext := m.hashSlotHead[index]; hashListNext != -1 && offset < bsLen; {
+		node := m.getNode(hashListNext)
+		m.putKey(bs[offset:], node.key)
+		offset += m.KeySize()
+		hashListNext = node.hashListNext
+	}
+}
+
+func (m *baseLRU[K]) GetCollisionChain() []byte {
+	if atomic.LoadUint32(&m.debugChainRead) == 1 {
+		return nil
+	}
+	chain := m.debugChain.Load()
+	atomic.StoreUint32(&m.debugChainRead, 1)
+	if chain == nil {
+		return nil
+	}
+	return chain.([]byte)
+}
+
+func (m *baseLRU[K]) SetCollisionChainDebugThreshold(t int) {
+	atomic.StoreUint32(&m.collisionChainDebugThreshold, uint32(t))
+	// 标记为已读，刷新链
+	if t > 0 {
+		atomic.StoreUint32(&m.debugChainRead, 1)
+	}
+}
+
+// 依次遍历窗口、保护区、试用区（POLICY_LFU中按访问次数从多到少），每个链表中从最近访问的节点开始，
+// callback返回true时停止遍历
+func (m *baseLRU[K]) walk(callback func(node *lruNode[K]) bool) {
+	for l := len(m.lists) - 1; l >= 0; l-- {
+		for i := m.lists[l].head; i != -1; {
+			node := m.getNode(i)
+			if exit := callback(node); exit {
+				return
+			}
+			i = node.listNext
+		}
+	}
+}
+
+func (m *baseLRU[K]) WalkWithSlice(callback func(key []byte, value interface{}) bool) {
+	key := make([]byte, m.keySize)
+	m.walk(func(node *lruNode[K]) bool {
+		m.putKey(key, node.key)
+		return callback(key, node.value)
+	})
+}
+
+func (m *baseLRU[K]) Clear() {
+	for i := range m.ringBuffer {
+		if m.ringBuffer[i] != nil {
+			for j := 0; j < len(m.ringBuffer[i]); j++ {
+				m.ringBuffer[i][j].value = nil
+			}
+			m.nodeBlockPool.Put(m.ringBuffer[i])
+			m.ringBuffer[i] = nil
+		}
+	}
+	m.bufferStartIndex = 0
+	m.bufferEndIndex = 0
+
+	for i := range m.hashSlotHead {
+		m.hashSlotHead[i] = -1
+	}
+	m.clearPolicy()
+
+	m.size = 0
+
+	atomic.StoreUint32(&m.debugChainRead, 1)
+}
@@ -0,0 +1,444 @@
+package lru
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/SophonMesh/go-libs/hmap"
+	"github.com/SophonMesh/go-libs/hmap/keyhash"
+)
+
+const (
+	_ARENA_COMPACT_MIN_GARBAGE = 4096    // arena中已删除的key超过该字节数且超过一半时整理arena
+	_ARENA_MAX_POOLED_SIZE     = 1 << 20 // 容量超过该值的arena不放回sync.Pool，避免长key长期占用内存
+)
+
+type bytesLRUNode struct {
+	value interface{}
+
+	hash      uint32 // key的哈希值
+	keyOffset int32  // key在所在块的arena中的偏移
+	keyLen    int32
+
+	hashListNext int32 // 表示节点所在冲突链的下一个节点的 buffer 数组下标，-1 表示不存在
+	hashListPrev int32 // 表示节点所在冲突链的上一个节点的 buffer 数组下标，-1 表示不存在
+	timeListNext int32 // 时间链表，含义与冲突链类似
+	timeListPrev int32 // 时间链表，含义与冲突链类似
+}
+
+var blankBytesLRUNodeForInit bytesLRUNode
+
+// 节点块，块内节点的key依次追加在arena中
+type bytesLRUNodeBlock struct {
+	nodes   []bytesLRUNode
+	arena   []byte
+	garbage int // arena中已删除的key占用的字节数
+}
+
+var bytesLRUNodeBlockPool = sync.Pool{New: func() interface{} {
+	return &bytesLRUNodeBlock{nodes: make([]bytesLRUNode, _BLOCK_SIZE)}
+}}
+
+// 注意：不是线程安全的
+// 以任意长度的[]byte或string为key的LRU，节点的存储方式与U64LRU相同，key存放在节点所在块的arena中：
+//   - 删除节点时buffer头部的节点移动至被删除的位置，其key追加到新位置所在块的arena
+//   - 块中已删除的key超过一半时整理arena，块被释放时arena随块一起重用
+//   - key的哈希值与长度有关，不支持冲突链调试，KeySize()为0
+type BytesLRU struct {
+	id string
+
+	ringBuffer       []*bytesLRUNodeBlock // 存储Map节点，以矩阵环的方式组织，提升内存申请释放效率
+	bufferStartIndex int32                // ringBuffer中的开始下标（二维矩阵下标），闭区间
+	bufferEndIndex   int32                // ringBuffer中的结束下标（二维矩阵下标），开区间
+
+	hashSlots    int32   // 上取整至2^N，哈希桶个数
+	hashSlotHead []int32 // 哈希桶，hashSlotHead[i] 表示哈希值为 i 的冲突链的第一个节点为 buffer[[ hashSlotHead[i] ]]
+	timeListHead int32
+	timeListTail int32
+
+	capacity int
+	size     int
+
+	compactBuffer []byte // 整理arena时暂存key
+
+	counter *Counter
+}
+
+func (m *BytesLRU) ID() string {
+	return m.id
+}
+
+// key的长度不固定，返回0
+func (m *BytesLRU) KeySize() int {
+	return 0
+}
+
+func (m *BytesLRU) Close() error {
+	return nil
+}
+
+func (m *BytesLRU) Size() int {
+	return m.size
+}
+
+func (m *BytesLRU) incIndex(index int32) int32 {
+	index++
+	if index>>_BLOCK_SIZE_BITS >= int32(len(m.ringBuffer)) {
+		return 0
+	}
+	return index
+}
+
+func (m *BytesLRU) getNode(index int32) *bytesLRUNode {
+	return &m.ringBuffer[index>>_BLOCK_SIZE_BITS].nodes[index&_BLOCK_SIZE_MASK]
+}
+
+// 返回值只在下一次修改LRU之前有效
+func (m *BytesLRU) getKey(index int32) []byte {
+	node := m.getNode(index)
+	return m.ringBuffer[index>>_BLOCK_SIZE_BITS].arena[node.keyOffset : node.keyOffset+node.keyLen]
+}
+
+func (m *BytesLRU) pushNodeToHashList(node *bytesLRUNode, nodeIndex int32) {
+	slot := m.compressHash(node.hash)
+	node.hashListNext = m.hashSlotHead[slot]
+	node.hashListPrev = -1
+	if node.hashListNext != -1 {
+		m.getNode(node.hashListNext).hashListPrev = nodeIndex
+	}
+	m.hashSlotHead[slot] = nodeIndex
+}
+
+func (m *BytesLRU) pushNodeToTimeList(node *bytesLRUNode, nodeIndex int32) {
+	node.timeListNext = m.timeListHead
+	node.timeListPrev = -1
+	if node.timeListNext != -1 {
+		m.getNode(node.timeListNext).timeListPrev = nodeIndex
+	}
+	m.timeListHead = nodeIndex
+	if m.timeListTail == -1 {
+		m.timeListTail = nodeIndex
+	}
+}
+
+func (m *BytesLRU) removeNodeFromHashList(node *bytesLRUNode, newNext, newPrev int32) {
+	if node.hashListPrev != -1 {
+		prevNode := m.getNode(node.hashListPrev)
+		prevNode.hashListNext = newNext
+	} else {
+		m.hashSlotHead[m.compressHash(node.hash)] = newNext
+	}
+
+	if node.hashListNext != -1 {
+		nextNode := m.getNode(node.hashListNext)
+		nextNode.hashListPrev = newPrev
+	}
+}
+
+func (m *BytesLRU) removeNodeFromTimeList(node *bytesLRUNode, newNext, newPrev int32) {
+	if node.timeListPrev != -1 {
+		prevNode := m.getNode(node.timeListPrev)
+		prevNode.timeListNext = newNext
+	} else {
+		m.timeListHead = newNext
+	}
+
+	if node.timeListNext != -1 {
+		nextNode := m.getNode(node.timeListNext)
+		nextNode.timeListPrev = newPrev
+	} else {
+		m.timeListTail = newPrev
+	}
+}
+
+// 将块中仍在使用的key紧凑地复制到arena的开头，已删除的节点keyLen为0，不需要区分
+func (m *BytesLRU) compactArena(block *bytesLRUNodeBlock) {
+	buffer := m.compactBuffer[:0]
+	for i := range block.nodes {
+		node := &block.nodes[i]
+		offset := int32(len(buffer))
+		buffer = append(buffer, block.arena[node.keyOffset:node.keyOffset+node.keyLen]...)
+		node.keyOffset = offset
+	}
+	block.arena = append(block.arena[:0], buffer...)
+	block.garbage = 0
+	m.compactBuffer = buffer
+}
+
+func (m *BytesLRU) removeNode(node *bytesLRUNode, nodeIndex int32) {
+	// 从哈希链表、时间链表中删除
+	m.removeNodeFromHashList(node, node.hashListNext, node.hashListPrev)
+	m.removeNodeFromTimeList(node, node.timeListNext, node.timeListPrev)
+
+	block := m.ringBuffer[nodeIndex>>_BLOCK_SIZE_BITS]
+	block.garbage += int(node.keyLen)
+
+	// 将节点交换至buffer头部
+	if nodeIndex != m.bufferStartIndex {
+		firstBlock := m.ringBuffer[m.bufferStartIndex>>_BLOCK_SIZE_BITS]
+		firstNode := m.getNode(m.bufferStartIndex)
+		firstBlock.garbage += int(firstNode.keyLen)
+		// 将firstNode内容拷贝至node，key追加到node所在块的arena
+		keyOffset := int32(len(block.arena))
+		block.arena = append(block.arena, m.getKey(m.bufferStartIndex)...)
+		*node = *firstNode
+		node.keyOffset = keyOffset
+		// 修改firstNode在哈希链、时间链的上下游指向node
+		m.removeNodeFromHashList(firstNode, nodeIndex, nodeIndex)
+		m.removeNodeFromTimeList(firstNode, nodeIndex, nodeIndex)
+		// 将firstNode初始化
+		*firstNode = blankBytesLRUNodeForInit
+
+		if block.garbage >= _ARENA_COMPACT_MIN_GARBAGE && block.garbage*2 > len(block.arena) {
+			m.compactArena(block)
+		}
+	} else {
+		*node = blankBytesLRUNodeForInit
+	}
+
+	// 释放头部节点
+	if m.bufferStartIndex&_BLOCK_SIZE_MASK == _BLOCK_SIZE_MASK {
+		m.putBlock(m.bufferStartIndex >> _BLOCK_SIZE_BITS)
+	}
+	m.bufferStartIndex = m.incIndex(m.bufferStartIndex)
+
+	m.size--
+}
+
+func (m *BytesLRU) putBlock(row int32) {
+	block := m.ringBuffer[row]
+	if cap(block.arena) > _ARENA_MAX_POOLED_SIZE {
+		block.arena = nil
+	}
+	block.arena = block.arena[:0]
+	block.garbage = 0
+	bytesLRUNodeBlockPool.Put(block)
+	m.ringBuffer[row] = nil
+}
+
+func (m *BytesLRU) updateNode(node *bytesLRUNode, nodeIndex int32, value interface{}) {
+	if nodeIndex != m.timeListHead {
+		// 从时间链表中删除
+		m.removeNodeFromTimeList(node, node.timeListNext, node.timeListPrev)
+		// 插入时间链表头部
+		m.pushNodeToTimeList(node, nodeIndex)
+	}
+
+	node.value = value
+}
+
+func bytesLRUNewNode[T string | []byte](m *BytesLRU, key T, hash uint32, value interface{}) {
+	// buffer空间检查
+	if m.size >= m.capacity {
+		node := m.getNode(m.timeListTail)
+		m.removeNode(node, m.timeListTail)
+	}
+	row := m.bufferEndIndex >> _BLOCK_SIZE_BITS
+	col := m.bufferEndIndex & _BLOCK_SIZE_MASK
+	if m.ringBuffer[row] == nil {
+		m.ringBuffer[row] = bytesLRUNodeBlockPool.Get().(*bytesLRUNodeBlock)
+	}
+	block := m.ringBuffer[row]
+	node := &block.nodes[col]
+	m.size++
+
+	// 更新key、value
+	node.hash = hash
+	node.keyOffset = int32(len(block.arena))
+	node.keyLen = int32(len(key))
+	block.arena = append(block.arena, key...)
+	node.value = value
+
+	// 新节点加入哈希链
+	m.pushNodeToHashList(node, m.bufferEndIndex)
+	// 新节点加入时间链
+	m.pushNodeToTimeList(node, m.bufferEndIndex)
+
+	// 更新buffer信息
+	m.bufferEndIndex = m.incIndex(m.bufferEndIndex)
+}
+
+func bytesLRUFind[T string | []byte](m *BytesLRU, key T, hash uint32, isAdd bool) (*bytesLRUNode, int32) {
+	m.counter.scanTimes++
+	width := 0
+	for hashListNext := m.hashSlotHead[m.compressHash(hash)]; hashListNext != -1; {
+		width++
+		node := m.getNode(hashListNext)
+		if node.hash == hash && string(m.getKey(hashListNext)) == string(key) {
+			m.counter.totalScan += width
+			if width > m.counter.Max {
+				m.counter.Max = width
+			}
+			return node, hashListNext
+		}
+		hashListNext = node.hashListNext
+	}
+	m.counter.totalScan += width
+	if isAdd {
+		width++
+	}
+	if width > m.counter.Max {
+		m.counter.Max = width
+	}
+	return nil, -1
+}
+
+func bytesLRUAdd[T string | []byte](m *BytesLRU, key T, hash uint32, value interface{}) {
+	node, nodeIndex := bytesLRUFind(m, key, hash, true)
+	if node != nil {
+		m.updateNode(node, nodeIndex, value)
+		return
+	}
+	bytesLRUNewNode(m, key, hash, value)
+}
+
+func bytesLRUGet[T string | []byte](m *BytesLRU, key T, hash uint32, peek bool) (interface{}, bool) {
+	node, nodeIndex := bytesLRUFind(m, key, hash, false)
+	if node != nil {
+		if !peek {
+			m.updateNode(node, nodeIndex, node.value)
+		}
+		return node.value, true
+	}
+	return nil, false
+}
+
+func bytesLRURemove[T string | []byte](m *BytesLRU, key T, hash uint32) bool {
+	for hashListNext := m.hashSlotHead[m.compressHash(hash)]; hashListNext != -1; {
+		node := m.getNode(hashListNext)
+		if node.hash == hash && string(m.getKey(hashListNext)) == string(key) {
+			m.removeNode(node, hashListNext)
+			return true
+		}
+		hashListNext = node.hashListNext
+	}
+	return false
+}
+
+// key已存在时更新value，LRU中保存key的副本，调用后可以修改key
+func (m *BytesLRU) Add(key []byte, value interface{}) {
+	bytesLRUAdd(m, key, keyhash.HashBytes(key), value)
+}
+
+func (m *BytesLRU) AddString(key string, value interface{}) {
+	bytesLRUAdd(m, key, keyhash.HashString(key), value)
+}
+
+func (m *BytesLRU) Get(key []byte, peek bool) (interface{}, bool) {
+	return bytesLRUGet(m, key, keyhash.HashBytes(key), peek)
+}
+
+func (m *BytesLRU) GetString(key string, peek bool) (interface{}, bool) {
+	return bytesLRUGet(m, key, keyhash.HashString(key), peek)
+}
+
+func (m *BytesLRU) Remove(key []byte) bool {
+	return bytesLRURemove(m, key, keyhash.HashBytes(key))
+}
+
+func (m *BytesLRU) RemoveString(key string) bool {
+	return bytesLRURemove(m, key, keyhash.HashString(key))
+}
+
+// callback返回true时停止遍历，key只在callback中有效，callback中不能修改LRU
+func (m *BytesLRU) Walk(callback func(key []byte, value interface{}) bool) {
+	for i := m.timeListHead; i != -1; {
+		node := m.getNode(i)
+		if exit := callback(m.getKey(i), node.value); exit {
+			break
+		}
+		i = node.timeListNext
+	}
+}
+
+func (m *BytesLRU) AddWithSlice(key []byte, value interface{}) {
+	m.Add(key, value)
+}
+
+func (m *BytesLRU) GetWithSlice(key []byte, peek bool) (interface{}, bool) {
+	return m.Get(key, peek)
+}
+
+func (m *BytesLRU) RemoveWithSlice(key []byte) bool {
+	return m.Remove(key)
+}
+
+func (m *BytesLRU) WalkWithSlice(callback func(key []byte, value interface{}) bool) {
+	m.Walk(callback)
+}
+
+func (m *BytesLRU) GetCounter() interface{} {
+	var counter *Counter
+	counter, m.counter = m.counter, &Counter{}
+	if counter.scanTimes != 0 {
+		counter.AvgScan = counter.totalScan / counter.scanTimes
+	}
+	counter.Size = m.size
+	counter.SetMemoryUsage(m.MemoryUsage())
+	return counter
+}
+
+// 返回占用的内存，节点块包括其中的arena，已删除的key和arena的空闲容量计入BlocksReserved
+func (m *BytesLRU) MemoryUsage() hmap.MemoryUsage {
+	usage := hmap.MemoryUsage{
+		SlotHeads: len(m.hashSlotHead) * 4,
+		Others:    cap(m.ringBuffer)*hmap.POINTER_SIZE + cap(m.compactBuffer),
+	}
+	allocated := 0
+	for _, block := range m.ringBuffer {
+		if block != nil {
+			allocated += len(block.nodes)
+			usage.BlocksInUse += len(block.arena) - block.garbage
+			usage.BlocksReserved += cap(block.arena) - len(block.arena) + block.garbage
+		}
+	}
+	usage.AddNodes(allocated, m.size, int(unsafe.Sizeof(bytesLRUNode{})))
+	return usage
+}
+
+func (m *BytesLRU) Clear() {
+	for i := range m.ringBuffer {
+		if m.ringBuffer[i] != nil {
+			nodes := m.ringBuffer[i].nodes
+			for j := range nodes {
+				nodes[j] = blankBytesLRUNodeForInit
+			}
+			m.putBlock(int32(i))
+		}
+	}
+	m.bufferStartIndex = 0
+	m.bufferEndIndex = 0
+
+	for i := range m.hashSlotHead {
+		m.hashSlotHead[i] = -1
+	}
+	m.timeListHead = -1
+	m.timeListTail = -1
+
+	m.size = 0
+}
+
+func (m *BytesLRU) compressHash(hash uint32) int32 {
+	return int32(hash) & (m.hashSlots - 1)
+}
+
+func NewBytesLRU(module string, hashSlots, capacity int) *BytesLRU {
+	hashSlots, _ = minPowerOfTwo(hashSlots)
+
+	m := &BytesLRU{
+		ringBuffer:   make([]*bytesLRUNodeBlock, (capacity+_BLOCK_SIZE)/_BLOCK_SIZE+1),
+		hashSlots:    int32(hashSlots),
+		hashSlotHead: make([]int32, hashSlots),
+		timeListHead: -1,
+		timeListTail: -1,
+		capacity:     capacity,
+		counter:      &Counter{},
+		id:           "lru-bytes-" + module,
+	}
+
+	for i := 0; i < len(m.hashSlotHead); i++ {
+		m.hashSlotHead[i] = -1
+	}
+
+	return m
+}
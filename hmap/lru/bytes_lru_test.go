@@ -0,0 +1,169 @@
+package lru
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestBytesLRU(t *testing.T) {
+	m := NewBytesLRU("test", 64, 4)
+
+	m.Add([]byte("a"), 1)
+	m.AddString("ab", 2)
+	m.Add(nil, 3)
+	m.AddString("/api/v1/users?id=1", 4)
+	if value, ok := m.GetString("a", false); !ok || value != 1 {
+		t.Errorf("结果预期为%v，实际为%v", 1, value)
+	}
+	if value, ok := m.Get([]byte("ab"), false); !ok || value != 2 {
+		t.Errorf("结果预期为%v，实际为%v", 2, value)
+	}
+	if value, ok := m.GetString("", true); !ok || value != 3 {
+		t.Errorf("空key结果预期为%v，实际为%v", 3, value)
+	}
+	if _, ok := m.GetString("abc", true); ok {
+		t.Error("abc不应存在")
+	}
+
+	// 调用后修改key不影响LRU
+	key := []byte("/api/v1/users?id=2")
+	m.Add(key, 5)
+	key[0] = 'x'
+	if value, ok := m.GetString("/api/v1/users?id=2", true); !ok || value != 5 {
+		t.Errorf("结果预期为%v，实际为%v", 5, value)
+	}
+	// 最久未访问的空key被淘汰
+	if _, ok := m.GetString("", true); ok {
+		t.Error("空key应被淘汰")
+	}
+	if m.Size() != 4 {
+		t.Errorf("Size预期为%d，实际为%d", 4, m.Size())
+	}
+
+	if !m.RemoveString("a") || m.Remove([]byte("a")) {
+		t.Error("Remove结果不正确")
+	}
+	var keys []string
+	m.Walk(func(key []byte, value interface{}) bool {
+		keys = append(keys, string(key))
+		return false
+	})
+	if strings.Join(keys, ",") != "/api/v1/users?id=2,ab,/api/v1/users?id=1" {
+		t.Errorf("Walk结果不正确，为%v", keys)
+	}
+
+	m.Clear()
+	if m.Size() != 0 {
+		t.Errorf("Clear后Size预期为0，实际为%d", m.Size())
+	}
+	if _, ok := m.GetString("ab", true); ok {
+		t.Error("Clear后ab不应存在")
+	}
+}
+
+// 检查链表、key与arena的一致性，返回所有块arena的总长度
+func checkBytesLRU(t *testing.T, m *BytesLRU, expect map[string]int) int {
+	if m.Size() != len(expect) {
+		t.Fatalf("Size预期为%d，实际为%d", len(expect), m.Size())
+	}
+	count := 0
+	m.Walk(func(key []byte, value interface{}) bool {
+		count++
+		if v, ok := expect[string(key)]; !ok || v != value {
+			t.Fatalf("key %q 的结果预期为%v，实际为%v", key, v, value)
+		}
+		return false
+	})
+	if count != len(expect) {
+		t.Fatalf("Walk预期遍历%d个，实际为%d个", len(expect), count)
+	}
+	arenaSize := 0
+	for _, block := range m.ringBuffer {
+		if block != nil {
+			arenaSize += len(block.arena)
+		}
+	}
+	return arenaSize
+}
+
+func TestBytesLRURandom(t *testing.T) {
+	capacity := 1000
+	m := NewBytesLRU("test", capacity, capacity)
+	expect := make(map[string]int)
+	keyOf := func(i int) string {
+		return fmt.Sprintf("select * from t%d where id = ?%s", i, strings.Repeat("#", i%64))
+	}
+
+	// 先填满，之后随机删除和添加，头部节点频繁地移动到被删除的位置
+	for i := 0; i < capacity; i++ {
+		m.AddString(keyOf(i), i)
+		expect[keyOf(i)] = i
+	}
+	for round := 0; round < 20; round++ {
+		for i := 0; i < capacity; i++ {
+			k := rand.Intn(capacity * 2)
+			if rand.Intn(2) == 0 {
+				removed := m.RemoveString(keyOf(k))
+				if _, ok := expect[keyOf(k)]; ok != removed {
+					t.Fatalf("Remove(%d)结果预期为%v，实际为%v", k, ok, removed)
+				}
+				delete(expect, keyOf(k))
+			} else if len(expect) < capacity {
+				m.Add([]byte(keyOf(k)), k)
+				expect[keyOf(k)] = k
+			}
+		}
+		arenaSize := checkBytesLRU(t, m, expect)
+		keyBytes := 0
+		for key := range expect {
+			keyBytes += len(key)
+		}
+		// 整理arena后，垃圾不超过存活key的长度以及每块 _ARENA_COMPACT_MIN_GARBAGE
+		if limit := keyBytes*2 + len(m.ringBuffer)*_ARENA_COMPACT_MIN_GARBAGE; arenaSize > limit {
+			t.Fatalf("arena总长度%d超过%d", arenaSize, limit)
+		}
+	}
+
+	// 超过容量时淘汰最久未访问的key
+	for i := 0; i < capacity*2; i++ {
+		m.AddString(keyOf(i), i)
+	}
+	for i := 0; i < capacity; i++ {
+		if _, ok := m.GetString(keyOf(i), true); ok {
+			t.Fatalf("%d应被淘汰", i)
+		}
+	}
+	for i := capacity; i < capacity*2; i++ {
+		if value, ok := m.GetString(keyOf(i), true); !ok || value != i {
+			t.Fatalf("结果预期为%v，实际为%v", i, value)
+		}
+	}
+}
+
+func BenchmarkBytesLRUAdd(b *testing.B) {
+	m := NewBytesLRU("test", 1<<16, 1<<16)
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("/api/v1/users/%d/orders", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.AddString(keys[i&0xFFFF], i)
+	}
+}
+
+func BenchmarkBytesLRUGet(b *testing.B) {
+	m := NewBytesLRU("test", 1<<16, 1<<16)
+	keys := make([][]byte, 1<<16)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("/api/v1/users/%d/orders", i))
+		m.Add(keys[i], i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Get(keys[i&0xFFFF], false)
+	}
+}
@@ -0,0 +1,79 @@
+package lru
+
+import "fmt"
+
+// 以[]byte为key的缓存，KeySize()不为0时key的长度必须等于KeySize()，为0时key的长度任意，
+// 所有宽度的LRU都实现该接口，可以在配置时通过NewCache选择key的宽度
+type Cache interface {
+	ID() string
+	KeySize() int
+	Close() error
+	Size() int
+
+	// key已存在时更新value
+	AddWithSlice(key []byte, value interface{})
+	// peek为true时不视为一次访问，不影响淘汰顺序
+	GetWithSlice(key []byte, peek bool) (interface{}, bool)
+	// 返回key是否存在并被删除
+	RemoveWithSlice(key []byte) bool
+	// callback返回true时停止遍历，key只在callback中有效，callback中不能修改缓存
+	WalkWithSlice(callback func(key []byte, value interface{}) bool)
+	Clear()
+
+	GetCounter() interface{}
+}
+
+// key长度（字节）到UBig LRU构造函数的映射，由生成的代码注册
+var ubigLRUConstructors = map[int]func(module string, hashSlots, capacity int) Cache{}
+
+// 返回key长度为keySize字节的LRU，支持8、16字节以及ubig_lru.tmpldata中的长度，
+// keySize为0时返回key长度任意的BytesLRU
+func NewCache(module string, keySize, hashSlots, capacity int) (Cache, error) {
+	switch keySize {
+	case 0:
+		return NewBytesLRU(module, hashSlots, capacity), nil
+	case 64 / 8:
+		return NewU64LRU(module, hashSlots, capacity), nil
+	case 128 / 8:
+		return NewU128LRU(module, hashSlots, capacity), nil
+	}
+	if newLRU, ok := ubigLRUConstructors[keySize]; ok {
+		return newLRU(module, hashSlots, capacity), nil
+	}
+	return nil, fmt.Errorf("unsupported lru key size %d", keySize)
+}
+
+// 以K为key的缓存，不同淘汰策略的实现可以互相替换
+type TypedCache[K comparable] interface {
+	ID() string
+	KeySize() int
+	Close() error
+	Size() int
+
+	// key已存在时更新value
+	Add(key K, value interface{})
+	// peek为true时不视为一次访问，不影响淘汰顺序
+	Get(key K, peek bool) (interface{}, bool)
+	// 返回key是否存在并被删除
+	Remove(key K) bool
+	// callback中不能修改缓存
+	Walk(callback func(key K, value interface{}))
+	Clear()
+
+	GetCounter() interface{}
+}
+
+var (
+	_ Cache = &U64LRU{}
+	_ Cache = &U128LRU{}
+	_ Cache = &ConcurrentU64LRU{}
+	_ Cache = &BytesLRU{}
+	_ Cache = &OffHeapU64LRU[uint64]{}
+	_ Cache = &MultiIndexLRU[uint64]{}
+	_ Cache = &U64DoubleKeyLRU{}
+	_ Cache = &U128U64DoubleKeyLRU{}
+
+	_ TypedCache[uint64]    = &U64LRU{}
+	_ TypedCache[[2]uint64] = u128TypedCache{}
+	_ TypedCache[uint64]    = &ConcurrentU64LRU{}
+)
@@ -0,0 +1,107 @@
+package lru
+
+import (
+	"encoding/binary"
+	"sort"
+	"testing"
+)
+
+// keySize为0时key的长度随i变化
+func cacheKey(keySize int, i uint64) []byte {
+	if keySize == 0 {
+		keySize = 8 + int(i%7)
+	}
+	key := make([]byte, keySize)
+	binary.BigEndian.PutUint64(key[keySize-8:], i)
+	return key
+}
+
+func testCache(t *testing.T, m Cache) {
+	keySize := m.KeySize()
+	for i := uint64(0); i < 100; i++ {
+		m.AddWithSlice(cacheKey(keySize, i), i)
+	}
+	// 容量为64，只保留最近添加的key
+	if m.Size() != 64 {
+		t.Errorf("%s: Size预期为%d，实际为%d", m.ID(), 64, m.Size())
+	}
+	for i := uint64(36); i < 100; i++ {
+		if value, ok := m.GetWithSlice(cacheKey(keySize, i), false); !ok || value != i {
+			t.Errorf("%s: 结果预期为%v，实际为%v", m.ID(), i, value)
+		}
+	}
+	if _, ok := m.GetWithSlice(cacheKey(keySize, 0), true); ok {
+		t.Errorf("%s: 0应被淘汰", m.ID())
+	}
+	if !m.RemoveWithSlice(cacheKey(keySize, 99)) || m.RemoveWithSlice(cacheKey(keySize, 99)) {
+		t.Errorf("%s: RemoveWithSlice结果不正确", m.ID())
+	}
+
+	var keys []uint64
+	m.WalkWithSlice(func(key []byte, value interface{}) bool {
+		if (keySize != 0 && len(key) != keySize) || binary.BigEndian.Uint64(key[len(key)-8:]) != value {
+			t.Errorf("%s: key %v 的value不正确，为%v", m.ID(), key, value)
+		}
+		keys = append(keys, value.(uint64))
+		return false
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	if len(keys) != 63 || keys[0] != 36 || keys[62] != 98 {
+		t.Errorf("%s: Walk结果不正确，为%v", m.ID(), keys)
+	}
+	walked := 0
+	m.WalkWithSlice(func(key []byte, value interface{}) bool {
+		walked++
+		return walked == 10
+	})
+	if walked != 10 {
+		t.Errorf("%s: callback返回true时应停止遍历，实际遍历%d个", m.ID(), walked)
+	}
+
+	if keySize != 0 {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: key长度不正确时应panic", m.ID())
+				}
+			}()
+			m.GetWithSlice(make([]byte, keySize+1), true)
+		}()
+	}
+
+	m.Clear()
+	if m.Size() != 0 {
+		t.Errorf("%s: Clear后Size预期为0，实际为%d", m.ID(), m.Size())
+	}
+	m.Close()
+}
+
+func TestCache(t *testing.T) {
+	keySizes := []int{0, 8, 16}
+	for keySize := range ubigLRUConstructors {
+		keySizes = append(keySizes, keySize)
+	}
+	for _, keySize := range keySizes {
+		m, err := NewCache("test", keySize, 64, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.KeySize() != keySize {
+			t.Errorf("KeySize预期为%d，实际为%d", keySize, m.KeySize())
+		}
+		testCache(t, m)
+	}
+	if _, err := NewCache("test", 12, 64, 64); err == nil {
+		t.Error("不支持的key长度应返回错误")
+	}
+
+	testCache(t, NewConcurrentU64LRU("test", 1, 64, 64))
+	testCache(t, NewU64MultiIndexLRU("test", 64, []int{64, 64}, 64))
+	testCache(t, NewU128MultiIndexLRU("test", 64, nil, 64))
+	testCache(t, NewU64DoubleKeyLRU("test", 64, 64, 64))
+	testCache(t, NewU128U64DoubleKeyLRU("test", 64, 64, 64))
+	for _, policy := range []Policy{POLICY_LRU, POLICY_SLRU} {
+		testCache(t, NewU64LRUWithPolicy("test", policy, 64, 64))
+		testCache(t, NewU128LRUWithPolicy("test", policy, 64, 64))
+	}
+}
@@ -1,5 +1,7 @@
 package lru
 
+import "github.com/SophonMesh/go-libs/hmap"
+
 const (
 	_BLOCK_SIZE_BITS = 8
 	_BLOCK_SIZE      = 1 << _BLOCK_SIZE_BITS
@@ -20,6 +22,8 @@ type Counter struct {
 	Size    int `statsd:"size"`
 	AvgScan int `statsd:"avg-scan"` // 平均扫描次数
 
+	hmap.MemoryCounter // GetCounter时的MemoryUsage
+
 	totalScan, scanTimes int
 }
 
@@ -30,5 +34,7 @@ type DoubleKeyLRUCounter struct {
 	MaxLongBucket  int `statsd:"max-long-bucket"` // 目前通过shortKey删除的含有最多的成员数值
 	AvgScan        int `statsd:"avg-scan"`        // 平均扫描次数
 
+	hmap.MemoryCounter // GetCounter时的MemoryUsage
+
 	totalScan, scanTimes int
 }
@@ -0,0 +1,422 @@
+package lru
+
+import (
+	"encoding/binary"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/SophonMesh/go-libs/hmap"
+	"github.com/SophonMesh/go-libs/hmap/keyhash"
+)
+
+type concurrentU64LRUNode struct {
+	key   uint64
+	value interface{}
+
+	hashListNext int32  // 表示节点所在冲突链的下一个节点的下标，-1 表示不存在
+	hashListPrev int32  // 表示节点所在冲突链的上一个节点的下标，-1 表示不存在
+	bucket       int32  // 所在哈希桶的下标+1，0 表示正在被分配，-1 表示空闲。原子读，持有所在桶的写锁时修改
+	referenced   uint32 // CLOCK的访问位，Get时原子置1，淘汰扫描时清0
+}
+
+type concurrentU64LRUNodeBlock [_BLOCK_SIZE]concurrentU64LRUNode
+
+type concurrentU64LRUShard struct {
+	buffer   []unsafe.Pointer // *concurrentU64LRUNodeBlock，与U64LRU相同以块的方式按需申请，原子读写
+	capacity int32
+	used     int32  // [0, used) 中的节点已分配过，原子读写
+	hand     uint32 // CLOCK指针，hand % capacity 为下一个检查是否淘汰的节点，原子递增
+	size     int32  // 冲突链中的节点个数，原子读写
+
+	hashSlotHead []int32
+	bucketLocks  []sync.RWMutex // 哈希桶 i 由 bucketLocks[i&(len(bucketLocks)-1)] 保护
+	shardBits    uint32         // 哈希值的低位用于选择分片，其余位用于选择分片内的哈希桶
+}
+
+func (s *concurrentU64LRUShard) compressHash(hash uint32) int32 {
+	return int32(hash>>s.shardBits) & int32(len(s.hashSlotHead)-1)
+}
+
+func (s *concurrentU64LRUShard) bucketLock(slot int32) *sync.RWMutex {
+	return &s.bucketLocks[slot&int32(len(s.bucketLocks)-1)]
+}
+
+func (s *concurrentU64LRUShard) block(index int32) *concurrentU64LRUNodeBlock {
+	return (*concurrentU64LRUNodeBlock)(atomic.LoadPointer(&s.buffer[index>>_BLOCK_SIZE_BITS]))
+}
+
+// 只用于已分配的节点，所在的块一定存在
+func (s *concurrentU64LRUShard) getNode(index int32) *concurrentU64LRUNode {
+	return &s.block(index)[index&_BLOCK_SIZE_MASK]
+}
+
+// 持有slot的桶锁时调用
+func (s *concurrentU64LRUShard) find(key uint64, slot int32) (*concurrentU64LRUNode, int32) {
+	for hashListNext := s.hashSlotHead[slot]; hashListNext != -1; {
+		node := s.getNode(hashListNext)
+		if node.key == key {
+			return node, hashListNext
+		}
+		hashListNext = node.hashListNext
+	}
+	return nil, -1
+}
+
+// 持有slot的桶写锁时调用
+func (s *concurrentU64LRUShard) pushNodeToHashList(node *concurrentU64LRUNode, nodeIndex int32, slot int32) {
+	node.hashListNext = s.hashSlotHead[slot]
+	node.hashListPrev = -1
+	if node.hashListNext != -1 {
+		s.getNode(node.hashListNext).hashListPrev = nodeIndex
+	}
+	s.hashSlotHead[slot] = nodeIndex
+	atomic.StoreInt32(&node.bucket, slot+1)
+	atomic.AddInt32(&s.size, 1)
+}
+
+// 持有节点所在桶的写锁时调用，从冲突链中删除后节点的状态改为bucket
+func (s *concurrentU64LRUShard) removeNodeFromHashList(node *concurrentU64LRUNode, bucket int32) {
+	if node.hashListPrev != -1 {
+		s.getNode(node.hashListPrev).hashListNext = node.hashListNext
+	} else {
+		s.hashSlotHead[node.bucket-1] = node.hashListNext
+	}
+	if node.hashListNext != -1 {
+		s.getNode(node.hashListNext).hashListPrev = node.hashListPrev
+	}
+	node.value = nil
+	atomic.StoreInt32(&node.bucket, bucket)
+	atomic.AddInt32(&s.size, -1)
+}
+
+// 持有held（新节点所在桶的写锁）时调用，返回一个状态为正在分配的节点：
+// 未满时分配新节点，否则原子递增hand扫描，空闲节点直接占用，跳过并清除访问位为1的节点，
+// 访问位为0的节点在获取所在桶的写锁后从冲突链中删除。为避免死锁，获取其它桶锁时使用TryLock，失败时跳过该节点
+func (s *concurrentU64LRUShard) allocate(held *sync.RWMutex) int32 {
+	for used := atomic.LoadInt32(&s.used); used < s.capacity; used = atomic.LoadInt32(&s.used) {
+		if atomic.CompareAndSwapInt32(&s.used, used, used+1) {
+			row := &s.buffer[used>>_BLOCK_SIZE_BITS]
+			if atomic.LoadPointer(row) == nil {
+				atomic.CompareAndSwapPointer(row, nil, unsafe.Pointer(new(concurrentU64LRUNodeBlock)))
+			}
+			return used
+		}
+	}
+
+	for scanned := uint32(1); ; scanned++ {
+		if scanned%(2*uint32(s.capacity)) == 0 {
+			// 候选节点所在的桶锁都被其它goroutine持有
+			runtime.Gosched()
+		}
+		index := int32((atomic.AddUint32(&s.hand, 1) - 1) % uint32(s.capacity))
+		block := s.block(index)
+		if block == nil {
+			// 其它goroutine分配了该节点，还未申请所在的块
+			continue
+		}
+		node := &block[index&_BLOCK_SIZE_MASK]
+		bucket := atomic.LoadInt32(&node.bucket)
+		switch {
+		case bucket == -1:
+			if atomic.CompareAndSwapInt32(&node.bucket, -1, 0) {
+				return index
+			}
+		case bucket == 0:
+		case atomic.LoadUint32(&node.referenced) != 0:
+			atomic.StoreUint32(&node.referenced, 0)
+		default:
+			lock := s.bucketLock(bucket - 1)
+			if lock != held && !lock.TryLock() {
+				continue
+			}
+			// 加锁前节点可能已被删除或淘汰
+			evicted := atomic.LoadInt32(&node.bucket) > 0
+			if evicted {
+				s.removeNodeFromHashList(node, 0)
+			}
+			if lock != held {
+				lock.Unlock()
+			}
+			if evicted {
+				return index
+			}
+		}
+	}
+}
+
+func (s *concurrentU64LRUShard) add(key uint64, slot int32, value interface{}) {
+	lock := s.bucketLock(slot)
+	lock.Lock()
+	if node, _ := s.find(key, slot); node != nil {
+		node.value = value
+		atomic.StoreUint32(&node.referenced, 1)
+		lock.Unlock()
+		return
+	}
+	// 确认key不存在后才分配节点，不会因为并发添加同一个key而多淘汰节点
+	index := s.allocate(lock)
+	node := s.getNode(index)
+	node.key = key
+	node.value = value
+	atomic.StoreUint32(&node.referenced, 0)
+	s.pushNodeToHashList(node, index, slot)
+	lock.Unlock()
+}
+
+func (s *concurrentU64LRUShard) remove(key uint64, slot int32) bool {
+	lock := s.bucketLock(slot)
+	lock.Lock()
+	node, _ := s.find(key, slot)
+	if node != nil {
+		s.removeNodeFromHashList(node, -1)
+	}
+	lock.Unlock()
+	return node != nil
+}
+
+// 依次持有每个哈希桶的读锁遍历冲突链，callback返回true时停止遍历
+func (s *concurrentU64LRUShard) walk(callback func(node *concurrentU64LRUNode) bool) bool {
+	for slot := range s.hashSlotHead {
+		lock := s.bucketLock(int32(slot))
+		lock.RLock()
+		for i := s.hashSlotHead[slot]; i != -1; {
+			node := s.getNode(i)
+			if exit := callback(node); exit {
+				lock.RUnlock()
+				return true
+			}
+			i = node.hashListNext
+		}
+		lock.RUnlock()
+	}
+	return false
+}
+
+// 分配节点时持有一个桶锁并TryLock其它桶锁，因此这里同样使用TryLock，失败时释放已持有的锁后重试
+func (s *concurrentU64LRUShard) lockAll() {
+	for {
+		locked := 0
+		for locked < len(s.bucketLocks) && s.bucketLocks[locked].TryLock() {
+			locked++
+		}
+		if locked == len(s.bucketLocks) {
+			return
+		}
+		for i := 0; i < locked; i++ {
+			s.bucketLocks[i].Unlock()
+		}
+		runtime.Gosched()
+	}
+}
+
+func (s *concurrentU64LRUShard) clear() {
+	s.lockAll()
+	for i := range s.buffer {
+		atomic.StorePointer(&s.buffer[i], nil)
+	}
+	for i := range s.hashSlotHead {
+		s.hashSlotHead[i] = -1
+	}
+	atomic.StoreInt32(&s.size, 0)
+	atomic.StoreInt32(&s.used, 0)
+	atomic.StoreUint32(&s.hand, 0)
+	for i := range s.bucketLocks {
+		s.bucketLocks[i].Unlock()
+	}
+}
+
+func (s *concurrentU64LRUShard) memoryUsage() hmap.MemoryUsage {
+	allocated := 0
+	for i := range s.buffer {
+		if atomic.LoadPointer(&s.buffer[i]) != nil {
+			allocated += _BLOCK_SIZE
+		}
+	}
+	usage := hmap.MemoryUsage{
+		SlotHeads: len(s.hashSlotHead) * 4,
+		Others:    len(s.buffer)*hmap.POINTER_SIZE + len(s.bucketLocks)*int(unsafe.Sizeof(sync.RWMutex{})),
+	}
+	usage.AddNodes(allocated, int(atomic.LoadInt32(&s.size)), int(unsafe.Sizeof(concurrentU64LRUNode{})))
+	return usage
+}
+
+// 线程安全的LRU近似实现，使用CLOCK淘汰策略：
+// Get只设置节点的访问位，不移动节点；淘汰时跳过并清除访问位为1的节点，淘汰访问位为0的节点。
+// key按哈希值分配到多个分片，每个分片有独立的哈希桶、节点存储和CLOCK指针，淘汰只在分片内进行。
+// 所有操作只持有所在哈希桶的锁，哈希桶较多时相邻的桶共用同一把锁（每个分片最多 _CONCURRENT_BUCKET_LOCKS 把）：
+// 添加新key时在持有桶写锁、确认key不存在后分配节点，CLOCK指针原子递增，淘汰其它桶中的节点时TryLock其桶锁。
+type ConcurrentU64LRU struct {
+	id string
+
+	shards []*concurrentU64LRUShard
+}
+
+// 每个分片的桶锁个数上限
+const _CONCURRENT_BUCKET_LOCKS = 256
+
+func (m *ConcurrentU64LRU) ID() string {
+	return m.id
+}
+
+func (m *ConcurrentU64LRU) KeySize() int {
+	return 64 / 8
+}
+
+func (m *ConcurrentU64LRU) Close() error {
+	return nil
+}
+
+func (m *ConcurrentU64LRU) Size() int {
+	size := 0
+	for _, s := range m.shards {
+		size += int(atomic.LoadInt32(&s.size))
+	}
+	return size
+}
+
+func (m *ConcurrentU64LRU) locate(key uint64) (*concurrentU64LRUShard, int32) {
+	hash := uint32(keyhash.Jenkins(key))
+	s := m.shards[hash&uint32(len(m.shards)-1)]
+	return s, s.compressHash(hash)
+}
+
+func (m *ConcurrentU64LRU) Add(key uint64, value interface{}) {
+	s, slot := m.locate(key)
+	s.add(key, slot, value)
+}
+
+func (m *ConcurrentU64LRU) Remove(key uint64) bool {
+	s, slot := m.locate(key)
+	return s.remove(key, slot)
+}
+
+// peek为true时不设置访问位
+func (m *ConcurrentU64LRU) Get(key uint64, peek bool) (interface{}, bool) {
+	s, slot := m.locate(key)
+	lock := s.bucketLock(slot)
+	lock.RLock()
+	node, _ := s.find(key, slot)
+	if node == nil {
+		lock.RUnlock()
+		return nil, false
+	}
+	value := node.value
+	// 避免重复写入同一cache line
+	if !peek && atomic.LoadUint32(&node.referenced) == 0 {
+		atomic.StoreUint32(&node.referenced, 1)
+	}
+	lock.RUnlock()
+	return value, true
+}
+
+// 依次遍历每个分片的每个哈希桶，遍历某个桶时持有其读锁，callback中不能修改LRU
+func (m *ConcurrentU64LRU) Walk(callback func(key uint64, value interface{})) {
+	for _, s := range m.shards {
+		s.walk(func(node *concurrentU64LRUNode) bool {
+			callback(node.key, node.value)
+			return false
+		})
+	}
+}
+
+func (m *ConcurrentU64LRU) AddWithSlice(key []byte, value interface{}) {
+	if len(key) != 8 {
+		panic("传入key的长度不等于 8 字节")
+	}
+	m.Add(binary.BigEndian.Uint64(key), value)
+}
+
+func (m *ConcurrentU64LRU) GetWithSlice(key []byte, peek bool) (interface{}, bool) {
+	if len(key) != 8 {
+		panic("传入key的长度不等于 8 字节")
+	}
+	return m.Get(binary.BigEndian.Uint64(key), peek)
+}
+
+func (m *ConcurrentU64LRU) RemoveWithSlice(key []byte) bool {
+	if len(key) != 8 {
+		panic("传入key的长度不等于 8 字节")
+	}
+	return m.Remove(binary.BigEndian.Uint64(key))
+}
+
+// 遍历顺序与Walk相同，callback返回true时停止遍历
+func (m *ConcurrentU64LRU) WalkWithSlice(callback func(key []byte, value interface{}) bool) {
+	var key [8]byte
+	for _, s := range m.shards {
+		exit := s.walk(func(node *concurrentU64LRUNode) bool {
+			binary.BigEndian.PutUint64(key[:], node.key)
+			return callback(key[:], node.value)
+		})
+		if exit {
+			return
+		}
+	}
+}
+
+func (m *ConcurrentU64LRU) Clear() {
+	for _, s := range m.shards {
+		s.clear()
+	}
+}
+
+func (m *ConcurrentU64LRU) GetCounter() interface{} {
+	counter := &Counter{Size: m.Size()}
+	counter.SetMemoryUsage(m.MemoryUsage())
+	return counter
+}
+
+// 返回所有分片占用的内存之和，不包括value指向的内存，桶锁计入Others
+func (m *ConcurrentU64LRU) MemoryUsage() hmap.MemoryUsage {
+	usage := hmap.MemoryUsage{Others: cap(m.shards) * hmap.POINTER_SIZE}
+	for _, s := range m.shards {
+		usage.Add(s.memoryUsage())
+	}
+	return usage
+}
+
+// shards：分片数量，上取整至2^N，不大于0时为 4*GOMAXPROCS，超过capacity时减少至不超过capacity的2^N
+// hashSlots：所有分片的哈希桶个数之和，每个分片的哈希桶个数上取整至2^N
+// capacity：所有分片的容量之和，不大于0时为1，平均分配到各分片，余数分配给前面的分片
+func NewConcurrentU64LRU(module string, shards, hashSlots, capacity int) *ConcurrentU64LRU {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+	shards, shardBits := minPowerOfTwo(shards)
+	for shards > capacity {
+		shards, shardBits = shards/2, shardBits-1
+	}
+	shardHashSlots, _ := minPowerOfTwo((hashSlots + shards - 1) / shards)
+	bucketLocks := shardHashSlots
+	if bucketLocks > _CONCURRENT_BUCKET_LOCKS {
+		bucketLocks = _CONCURRENT_BUCKET_LOCKS
+	}
+
+	m := &ConcurrentU64LRU{
+		id:     "lru64-concurrent-" + module,
+		shards: make([]*concurrentU64LRUShard, shards),
+	}
+	for i := range m.shards {
+		shardCapacity := capacity / shards
+		if i < capacity%shards {
+			shardCapacity++
+		}
+		s := &concurrentU64LRUShard{
+			buffer:       make([]unsafe.Pointer, (shardCapacity+_BLOCK_SIZE-1)/_BLOCK_SIZE),
+			capacity:     int32(shardCapacity),
+			hashSlotHead: make([]int32, shardHashSlots),
+			bucketLocks:  make([]sync.RWMutex, bucketLocks),
+			shardBits:    uint32(shardBits),
+		}
+		for j := range s.hashSlotHead {
+			s.hashSlotHead[j] = -1
+		}
+		m.shards[i] = s
+	}
+	return m
+}
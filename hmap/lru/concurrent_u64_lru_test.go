@@ -0,0 +1,235 @@
+package lru
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentU64LRU(t *testing.T) {
+	lru := NewConcurrentU64LRU("test", 1, 4, 3)
+	for i := 1; i <= 3; i++ {
+		lru.Add(uint64(i), i)
+	}
+	// 1被访问过，淘汰时跳过1，淘汰2
+	lru.Get(1, false)
+	lru.Add(4, 4)
+	if _, ok := lru.Get(2, true); ok {
+		t.Error("2应被淘汰")
+	}
+	for _, key := range []uint64{1, 3, 4} {
+		if value, ok := lru.Get(key, true); !ok || value != int(key) {
+			t.Errorf("结果预期为%v，实际为%v", key, value)
+		}
+	}
+	// peek不设置访问位，3和4都未被访问，淘汰3
+	lru.Add(5, 5)
+	if _, ok := lru.Get(3, true); ok {
+		t.Error("3应被淘汰")
+	}
+	// 更新已存在的key不会淘汰
+	lru.Add(5, 50)
+	if value, _ := lru.Get(5, true); value != 50 || lru.Size() != 3 {
+		t.Errorf("结果预期为%v，实际为%v", 50, value)
+	}
+
+	if !lru.Remove(1) || lru.Remove(1) {
+		t.Error("Remove结果不正确")
+	}
+	walked := map[uint64]interface{}{}
+	lru.Walk(func(key uint64, value interface{}) {
+		walked[key] = value
+	})
+	if len(walked) != 2 || walked[4] != 4 || walked[5] != 50 {
+		t.Errorf("Walk结果不正确，为%v", walked)
+	}
+
+	lru.Clear()
+	if _, ok := lru.Get(4, true); ok || lru.Size() != 0 {
+		t.Errorf("Clear后Size预期为0，实际为%d", lru.Size())
+	}
+	lru.Add(6, 6)
+	if value, ok := lru.Get(6, false); !ok || value != 6 {
+		t.Errorf("结果预期为%v，实际为%v", 6, value)
+	}
+}
+
+func TestConcurrentU64LRUCapacity(t *testing.T) {
+	// capacity为所有分片的容量之和，分片数不超过capacity
+	for _, shards := range []int{0, 3, 8, 64} {
+		lru := NewConcurrentU64LRU("test", shards, 64, 10)
+		for i := 0; i < 1000; i++ {
+			lru.Add(uint64(i), i)
+		}
+		if lru.Size() != 10 {
+			t.Errorf("分片数%d: Size预期为%d，实际为%d", shards, 10, lru.Size())
+		}
+	}
+}
+
+func TestConcurrentU64LRURandom(t *testing.T) {
+	for _, seed := range []int64{42, 233, 1024} {
+		rand.Seed(seed)
+		// 容量足够时不会淘汰，结果应与map一致
+		lru := NewConcurrentU64LRU("test", 4, 64, 1024)
+		expected := make(map[uint64]int)
+		for i := 0; i < 10000; i++ {
+			key := uint64(rand.Intn(512))
+			switch rand.Intn(4) {
+			case 0:
+				_, in := expected[key]
+				if lru.Remove(key) != in {
+					t.Fatalf("测试%d: Remove(%d)结果不正确", seed, key)
+				}
+				delete(expected, key)
+			case 1:
+				value, ok := lru.Get(key, false)
+				if v, in := expected[key]; in != ok || (in && v != value) {
+					t.Fatalf("测试%d: Get(%d)结果预期为%v，实际为%v", seed, key, v, value)
+				}
+			default:
+				v := rand.Int()
+				lru.Add(key, v)
+				expected[key] = v
+			}
+		}
+		if lru.Size() != len(expected) {
+			t.Fatalf("测试%d: Size预期为%d，实际为%d", seed, len(expected), lru.Size())
+		}
+		lru.Walk(func(key uint64, value interface{}) {
+			if expected[key] != value {
+				t.Fatalf("测试%d: key %d 结果预期为%v，实际为%v", seed, key, expected[key], value)
+			}
+		})
+	}
+}
+
+func TestConcurrentU64LRUConcurrent(t *testing.T) {
+	lru := NewConcurrentU64LRU("test", 8, 1024, 1024)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < 10000; i++ {
+				key := uint64(r.Intn(4096))
+				switch r.Intn(8) {
+				case 0:
+					lru.Remove(key)
+				case 1, 2:
+					lru.Add(key, key)
+				default:
+					if value, ok := lru.Get(key, false); ok && value != key {
+						t.Errorf("key %d 的value不正确，为%v", key, value)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if size := lru.Size(); size > 1024 {
+		t.Errorf("Size不应超过容量1024，实际为%d", size)
+	}
+}
+
+const (
+	_ZIPF_KEYS     = 1 << 20
+	_ZIPF_CAPACITY = 1 << 14
+)
+
+func zipfKeys(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, 1.1, 1, _ZIPF_KEYS-1)
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = z.Uint64()
+	}
+	return keys
+}
+
+// 按Get未命中时Add的方式回放访问序列，返回命中率
+func replay(get func(uint64) bool, add func(uint64), keys []uint64) float64 {
+	hits := 0
+	for _, key := range keys {
+		if get(key) {
+			hits++
+		} else {
+			add(key)
+		}
+	}
+	return float64(hits) / float64(len(keys))
+}
+
+func TestConcurrentU64LRUHitRatio(t *testing.T) {
+	keys := zipfKeys(1<<19, 42)
+
+	lru := NewU64LRU("test", _ZIPF_CAPACITY, _ZIPF_CAPACITY)
+	lruRatio := replay(func(key uint64) bool {
+		_, ok := lru.Get(key, false)
+		return ok
+	}, func(key uint64) { lru.Add(key, nil) }, keys)
+
+	clock := NewConcurrentU64LRU("test", 0, _ZIPF_CAPACITY, _ZIPF_CAPACITY)
+	clockRatio := replay(func(key uint64) bool {
+		_, ok := clock.Get(key, false)
+		return ok
+	}, func(key uint64) { clock.Add(key, nil) }, keys)
+
+	// CLOCK是LRU的近似，Zipf分布下命中率应接近
+	if clockRatio < lruRatio-0.02 {
+		t.Errorf("CLOCK命中率%.4f与LRU命中率%.4f相差过大", clockRatio, lruRatio)
+	}
+}
+
+// 各goroutine从不同位置开始回放同一Zipf访问序列，报告吞吐和命中率
+func benchmarkZipf(b *testing.B, get func(uint64) bool, add func(uint64)) {
+	keys := zipfKeys(1<<20, 42)
+	var m sync.Mutex
+	var hits, total int
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		localHits, localTotal := 0, 0
+		for i := rand.Int(); pb.Next(); i++ {
+			key := keys[i&(len(keys)-1)]
+			if get(key) {
+				localHits++
+			} else {
+				add(key)
+			}
+			localTotal++
+		}
+
+		m.Lock()
+		hits += localHits
+		total += localTotal
+		m.Unlock()
+	})
+	b.ReportMetric(float64(hits)/float64(total)*100, "hit%")
+}
+
+func BenchmarkU64LRUZipf(b *testing.B) {
+	lru := NewU64LRU("test", _ZIPF_CAPACITY, _ZIPF_CAPACITY)
+	var m sync.Mutex
+	benchmarkZipf(b, func(key uint64) bool {
+		m.Lock()
+		_, ok := lru.Get(key, false)
+		m.Unlock()
+		return ok
+	}, func(key uint64) {
+		m.Lock()
+		lru.Add(key, nil)
+		m.Unlock()
+	})
+}
+
+func BenchmarkConcurrentU64LRUZipf(b *testing.B) {
+	lru := NewConcurrentU64LRU("test", 0, _ZIPF_CAPACITY, _ZIPF_CAPACITY)
+	benchmarkZipf(b, func(key uint64) bool {
+		_, ok := lru.Get(key, false)
+		return ok
+	}, func(key uint64) {
+		lru.Add(key, nil)
+	})
+}
@@ -0,0 +1,87 @@
+package lru
+
+const (
+	_SKETCH_DEPTH       = 4
+	_SKETCH_MAX_COUNT   = 15 // 计数器为4比特
+	_SKETCH_WIDTH_RATE  = 4  // 每行计数器个数为容量的 _SKETCH_WIDTH_RATE 倍，减少冲突
+	_SKETCH_SAMPLE_RATE = 10 // 累计增加 _SKETCH_SAMPLE_RATE * capacity 次后所有计数器减半
+)
+
+var sketchSeeds = [_SKETCH_DEPTH]uint32{0x97cb3127, 0xc3a5c85c, 0x9ae16a3b, 0xcbf29ce4}
+
+// 估计key的访问频率，用于W-TinyLFU的准入判断：
+//   - 共 _SKETCH_DEPTH 行，每行 width 个4比特计数器，每个uint64存放16个计数器
+//   - 估计值为key在各行对应计数器的最小值，只会高估不会低估
+//   - 计数器定期减半，使频率估计偏向近期的访问
+type countMinSketch struct {
+	table []uint64 // 第row行第i个计数器位于 table[row*width/16 + i/16] 的第 i%16 个4比特
+	width uint32   // 上取整至2^N，不小于16
+
+	additions  int
+	sampleSize int
+}
+
+// capacity为缓存的容量
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := capacity * _SKETCH_WIDTH_RATE
+	if width < 16 {
+		width = 16
+	}
+	width, _ = minPowerOfTwo(width)
+	return &countMinSketch{
+		table:      make([]uint64, _SKETCH_DEPTH*width/16),
+		width:      uint32(width),
+		sampleSize: _SKETCH_SAMPLE_RATE * capacity,
+	}
+}
+
+// 返回第row行计数器在table中的下标和偏移
+func (s *countMinSketch) locate(hash uint32, row int) (int, uint32) {
+	h := (hash ^ sketchSeeds[row]) * 0x9e3779b1
+	h ^= h >> 15
+	i := h & (s.width - 1)
+	return row*int(s.width>>4) + int(i>>4), (i & 15) << 2
+}
+
+func (s *countMinSketch) increment(hash uint32) {
+	added := false
+	for row := 0; row < _SKETCH_DEPTH; row++ {
+		index, offset := s.locate(hash, row)
+		if (s.table[index]>>offset)&_SKETCH_MAX_COUNT < _SKETCH_MAX_COUNT {
+			s.table[index] += 1 << offset
+			added = true
+		}
+	}
+	if added {
+		s.additions++
+		if s.additions >= s.sampleSize {
+			s.reset()
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(hash uint32) int {
+	count := uint64(_SKETCH_MAX_COUNT)
+	for row := 0; row < _SKETCH_DEPTH; row++ {
+		index, offset := s.locate(hash, row)
+		if c := (s.table[index] >> offset) & _SKETCH_MAX_COUNT; c < count {
+			count = c
+		}
+	}
+	return int(count)
+}
+
+// 所有计数器减半
+func (s *countMinSketch) reset() {
+	for i, v := range s.table {
+		s.table[i] = (v >> 1) & 0x7777777777777777
+	}
+	s.additions /= 2
+}
+
+func (s *countMinSketch) clear() {
+	for i := range s.table {
+		s.table[i] = 0
+	}
+	s.additions = 0
+}
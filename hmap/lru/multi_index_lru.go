@@ -0,0 +1,809 @@
+package lru
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/SophonMesh/go-libs/hmap"
+	"github.com/SophonMesh/go-libs/hmap/keyhash"
+)
+
+type multiIndexLRUNode[K comparable] struct {
+	key   K
+	value interface{}
+
+	hashSlot     int32 // key所在的哈希桶
+	hashListNext int32 // 表示节点所在冲突链的下一个节点的 buffer 数组下标，-1 表示不存在
+	hashListPrev int32 // 表示节点所在冲突链的上一个节点的 buffer 数组下标，-1 表示不存在
+	timeListNext int32 // 时间链表，含义与冲突链类似
+	timeListPrev int32 // 时间链表，含义与冲突链类似
+}
+
+// 二级索引节点，与主节点位于ringBuffer中相同的下标
+type indexNode struct {
+	key  uint64
+	next int32 // 表示节点所在索引冲突链的下一个节点的 buffer 数组下标，-1 表示不存在
+	prev int32 // 表示节点所在索引冲突链的上一个节点的 buffer 数组下标，-1 表示不存在
+}
+
+type secondaryIndex struct {
+	hashSlots    int32   // 上取整至2^N，哈希桶个数
+	hashSlotHead []int32 // 哈希桶，hashSlotHead[i] 表示索引key哈希值为 i 的第一个节点为 buffer[[ hashSlotHead[i] ]]
+}
+
+// 注意：不是线程安全的
+// 以K为主key的LRU，每个节点另有N个uint64的二级索引key（N在创建时指定），
+// 可以通过二级索引key查找、删除、遍历所有对应的节点，二级索引key不要求唯一
+type MultiIndexLRU[K comparable] struct {
+	id string
+
+	ringBuffer       [][]multiIndexLRUNode[K] // 存储Map节点，以矩阵环的方式组织，提升内存申请释放效率
+	indexBuffer      [][]indexNode            // 存储二级索引节点，与ringBuffer的块一一对应，每块长度为 _BLOCK_SIZE * len(indexes)
+	bufferStartIndex int32                    // ringBuffer中的开始下标（二维矩阵下标），闭区间
+	bufferEndIndex   int32                    // ringBuffer中的结束下标（二维矩阵下标），开区间
+
+	hashSlots    int32   // 上取整至2^N，哈希桶个数
+	hashSlotBits uint32  // hashSlots中低位连续0比特个数
+	hashSlotHead []int32 // 哈希桶，hashSlotHead[i] 表示哈希值为 i 的冲突链的第一个节点为 buffer[[ hashSlotHead[i] ]]
+
+	indexes []secondaryIndex
+
+	timeListHead int32
+	timeListTail int32
+
+	capacity int
+	size     int
+
+	keySize int
+	hash    func(key K) int32      // key的哈希值，compressHash后作为哈希桶下标
+	putKey  func(bs []byte, key K) // 将key写入冲突链
+	getKey  func(bs []byte) K      // 从[]byte读取key，bs的长度为keySize
+
+	zeroIndexKeys []uint64 // 通过AddWithSlice添加的节点的索引key
+
+	nodeBlockPool  sync.Pool
+	indexBlockPool sync.Pool
+
+	counter *DoubleKeyLRUCounter
+
+	batchSlots hmap.BatchSlots // 批量操作时预先计算的哈希桶
+
+	collisionChainDebugThreshold uint32       // scan宽度超过该值时保留冲突链信息，为0时不保存
+	debugChain                   atomic.Value // 冲突链，类型为[]byte
+	debugChainRead               uint32       // 冲突链是否已读，如果已读替换为新的 (atomic.Value无法清空)
+}
+
+func (m *MultiIndexLRU[K]) ID() string {
+	return m.id
+}
+
+func (m *MultiIndexLRU[K]) KeySize() int {
+	return m.keySize
+}
+
+func (m *MultiIndexLRU[K]) Close() error {
+	hmap.DeregisterForDebug(m)
+	return nil
+}
+
+func (m *MultiIndexLRU[K]) Size() int {
+	return m.size
+}
+
+// 二级索引的个数
+func (m *MultiIndexLRU[K]) Indexes() int {
+	return len(m.indexes)
+}
+
+func (m *MultiIndexLRU[K]) incIndex(index int32) int32 {
+	index++
+	if index>>_BLOCK_SIZE_BITS >= int32(len(m.ringBuffer)) {
+		return 0
+	}
+	return index
+}
+
+func (m *MultiIndexLRU[K]) getNode(index int32) *multiIndexLRUNode[K] {
+	return &m.ringBuffer[index>>_BLOCK_SIZE_BITS][index&_BLOCK_SIZE_MASK]
+}
+
+func (m *MultiIndexLRU[K]) getIndexNode(i int, index int32) *indexNode {
+	return &m.indexBuffer[index>>_BLOCK_SIZE_BITS][int(index&_BLOCK_SIZE_MASK)*len(m.indexes)+i]
+}
+
+func (m *MultiIndexLRU[K]) pushNodeToHashList(node *multiIndexLRUNode[K], nodeIndex int32) {
+	node.hashListNext = m.hashSlotHead[node.hashSlot]
+	node.hashListPrev = -1
+	if node.hashListNext != -1 {
+		m.getNode(node.hashListNext).hashListPrev = nodeIndex
+	}
+	m.hashSlotHead[node.hashSlot] = nodeIndex
+}
+
+func (m *MultiIndexLRU[K]) pushNodeToIndexList(i int, node *indexNode, nodeIndex int32) {
+	slotHead := m.indexes[i].hashSlotHead
+	slot := m.compressIndexHash(i, node.key)
+	node.next = slotHead[slot]
+	node.prev = -1
+	if node.next != -1 {
+		m.getIndexNode(i, node.next).prev = nodeIndex
+	}
+	slotHead[slot] = nodeIndex
+}
+
+func (m *MultiIndexLRU[K]) pushNodeToTimeList(node *multiIndexLRUNode[K], nodeIndex int32) {
+	node.timeListNext = m.timeListHead
+	node.timeListPrev = -1
+	if node.timeListNext != -1 {
+		m.getNode(node.timeListNext).timeListPrev = nodeIndex
+	}
+	m.timeListHead = nodeIndex
+	if m.timeListTail == -1 {
+		m.timeListTail = nodeIndex
+	}
+}
+
+func (m *MultiIndexLRU[K]) removeNodeFromHashList(node *multiIndexLRUNode[K], newNext, newPrev int32) {
+	if node.hashListPrev != -1 {
+		prevNode := m.getNode(node.hashListPrev)
+		prevNode.hashListNext = newNext
+	} else {
+		m.hashSlotHead[node.hashSlot] = newNext
+	}
+
+	if node.hashListNext != -1 {
+		nextNode := m.getNode(node.hashListNext)
+		nextNode.hashListPrev = newPrev
+	}
+}
+
+func (m *MultiIndexLRU[K]) removeNodeFromIndexList(i int, node *indexNode, newNext, newPrev int32) {
+	if node.prev != -1 {
+		m.getIndexNode(i, node.prev).next = newNext
+	} else {
+		m.indexes[i].hashSlotHead[m.compressIndexHash(i, node.key)] = newNext
+	}
+
+	if node.next != -1 {
+		m.getIndexNode(i, node.next).prev = newPrev
+	}
+}
+
+func (m *MultiIndexLRU[K]) removeNodeFromTimeList(node *multiIndexLRUNode[K], newNext, newPrev int32) {
+	if node.timeListPrev != -1 {
+		prevNode := m.getNode(node.timeListPrev)
+		prevNode.timeListNext = newNext
+	} else {
+		m.timeListHead = newNext
+	}
+
+	if node.timeListNext != -1 {
+		nextNode := m.getNode(node.timeListNext)
+		nextNode.timeListPrev = newPrev
+	} else {
+		m.timeListTail = newPrev
+	}
+}
+
+// 删除指定node，buffer头部的节点会被移动至nodeIndex，此时返回其原来的下标，否则返回-1
+// 遍历链表的同时删除节点时，若链表的下一个节点为返回值，需要改为从nodeIndex继续遍历
+func (m *MultiIndexLRU[K]) removeNode(node *multiIndexLRUNode[K], nodeIndex int32) int32 {
+	// 从哈希链表、索引链表、时间链表中删除
+	m.removeNodeFromHashList(node, node.hashListNext, node.hashListPrev)
+	for i := range m.indexes {
+		in := m.getIndexNode(i, nodeIndex)
+		m.removeNodeFromIndexList(i, in, in.next, in.prev)
+	}
+	m.removeNodeFromTimeList(node, node.timeListNext, node.timeListPrev)
+
+	movedIndex := int32(-1)
+	// 将节点交换至buffer头部
+	if nodeIndex != m.bufferStartIndex {
+		movedIndex = m.bufferStartIndex
+		firstNode := m.getNode(movedIndex)
+		// 将firstNode内容拷贝至node
+		*node = *firstNode
+		// 修改firstNode在哈希链、时间链的上下游指向node
+		m.removeNodeFromHashList(firstNode, nodeIndex, nodeIndex)
+		m.removeNodeFromTimeList(firstNode, nodeIndex, nodeIndex)
+		// 将firstNode初始化
+		*firstNode = multiIndexLRUNode[K]{}
+		for i := range m.indexes {
+			in, firstIn := m.getIndexNode(i, nodeIndex), m.getIndexNode(i, movedIndex)
+			*in = *firstIn
+			m.removeNodeFromIndexList(i, firstIn, nodeIndex, nodeIndex)
+			*firstIn = indexNode{}
+		}
+	} else {
+		*node = multiIndexLRUNode[K]{}
+		for i := range m.indexes {
+			*m.getIndexNode(i, nodeIndex) = indexNode{}
+		}
+	}
+
+	// 释放头部节点
+	if m.bufferStartIndex&_BLOCK_SIZE_MASK == _BLOCK_SIZE_MASK {
+		row := m.bufferStartIndex >> _BLOCK_SIZE_BITS
+		m.nodeBlockPool.Put(m.ringBuffer[row])
+		m.ringBuffer[row] = nil
+		if len(m.indexes) > 0 {
+			m.indexBlockPool.Put(m.indexBuffer[row])
+			m.indexBuffer[row] = nil
+		}
+	}
+	m.bufferStartIndex = m.incIndex(m.bufferStartIndex)
+
+	m.size--
+
+	return movedIndex
+}
+
+func (m *MultiIndexLRU[K]) updateNode(node *multiIndexLRUNode[K], nodeIndex int32, value interface{}) {
+	if nodeIndex != m.timeListHead {
+		// 从时间链表中删除
+		m.removeNodeFromTimeList(node, node.timeListNext, node.timeListPrev)
+		// 插入时间链表头部
+		m.pushNodeToTimeList(node, nodeIndex)
+	}
+
+	node.value = value
+}
+
+func (m *MultiIndexLRU[K]) newNode(key K, hashSlot int32, indexKeys []uint64, value interface{}) {
+	// buffer空间检查
+	if m.size >= m.capacity {
+		node := m.getNode(m.timeListTail)
+		m.removeNode(node, m.timeListTail)
+	}
+	row := m.bufferEndIndex >> _BLOCK_SIZE_BITS
+	col := m.bufferEndIndex & _BLOCK_SIZE_MASK
+	if m.ringBuffer[row] == nil {
+		m.ringBuffer[row] = m.nodeBlockPool.Get().([]multiIndexLRUNode[K])
+		if len(m.indexes) > 0 {
+			m.indexBuffer[row] = m.indexBlockPool.Get().([]indexNode)
+		}
+	}
+	node := &m.ringBuffer[row][col]
+	m.size++
+
+	// 更新key、value
+	node.key = key
+	node.hashSlot = hashSlot
+	node.value = value
+
+	// 新节点加入哈希链
+	m.pushNodeToHashList(node, m.bufferEndIndex)
+
+	// 新节点加入索引链
+	for i, indexKey := range indexKeys {
+		in := m.getIndexNode(i, m.bufferEndIndex)
+		in.key = indexKey
+		m.pushNodeToIndexList(i, in, m.bufferEndIndex)
+	}
+
+	// 新节点加入时间链
+	m.pushNodeToTimeList(node, m.bufferEndIndex)
+
+	// 更新buffer信息
+	m.bufferEndIndex = m.incIndex(m.bufferEndIndex)
+}
+
+func (m *MultiIndexLRU[K]) GetCounter() interface{} {
+	var counter *DoubleKeyLRUCounter
+	counter, m.counter = m.counter, &DoubleKeyLRUCounter{}
+	if counter.scanTimes != 0 {
+		counter.AvgScan = counter.totalScan / counter.scanTimes
+	}
+	counter.Size = m.size
+	counter.SetMemoryUsage(m.MemoryUsage())
+	return counter
+}
+
+// 返回占用的内存，包括二级索引的哈希桶和节点块，不包括value指向的内存
+func (m *MultiIndexLRU[K]) MemoryUsage() hmap.MemoryUsage {
+	usage := hmap.NodeBlocksUsage(m.ringBuffer, m.size)
+	usage.Add(hmap.NodeBlocksUsage(m.indexBuffer, m.size*len(m.indexes)))
+	usage.SlotHeads = len(m.hashSlotHead) * 4
+	for _, index := range m.indexes {
+		usage.SlotHeads += len(index.hashSlotHead) * 4
+	}
+	usage.Others += cap(m.batchSlots) * 4
+	return usage
+}
+
+// 将节点的第i个索引key修改为indexKey，并移动至对应的索引冲突链
+func (m *MultiIndexLRU[K]) relinkIndexNode(i int, nodeIndex int32, indexKey uint64) {
+	in := m.getIndexNode(i, nodeIndex)
+	if in.key == indexKey {
+		return
+	}
+	m.removeNodeFromIndexList(i, in, in.next, in.prev)
+	in.key = indexKey
+	m.pushNodeToIndexList(i, in, nodeIndex)
+}
+
+// 返回是否添加了新节点，key已存在时更新value和索引key并返回false
+func (m *MultiIndexLRU[K]) add(key K, hashSlot int32, value interface{}, indexKeys []uint64) bool {
+	node, nodeIndex := m.find(key, hashSlot, true)
+	if node != nil {
+		for i, indexKey := range indexKeys {
+			m.relinkIndexNode(i, nodeIndex, indexKey)
+		}
+		m.updateNode(node, nodeIndex, value)
+		return false
+	}
+	m.newNode(key, hashSlot, indexKeys, value)
+	return true
+}
+
+// indexKeys的个数需要与创建时指定的索引个数相同，依次对应各个索引
+// key已存在时更新value，索引key发生变化的节点会移动至新索引key下
+func (m *MultiIndexLRU[K]) Add(key K, value interface{}, indexKeys ...uint64) {
+	if len(indexKeys) != len(m.indexes) {
+		panic(fmt.Sprintf("传入索引key的个数%d不等于索引个数%d", len(indexKeys), len(m.indexes)))
+	}
+	m.add(key, m.compressHash(key), value, indexKeys)
+}
+
+// 批量添加，keys[i]的索引key为indexKeys[i*n:(i+1)*n]，n为索引个数，added[i]表示keys[i]是否为新添加的
+// slice长度的约定见hmap.CheckBatchInput，indexKeys的长度需为len(keys)*n，不满足时panic
+func (m *MultiIndexLRU[K]) AddBatch(keys []K, values []interface{}, indexKeys []uint64, added []bool) {
+	hmap.CheckBatchInput(len(keys)*len(m.indexes), len(indexKeys))
+	m.addBatch(keys, values, indexKeys, len(m.indexes), added)
+}
+
+// keys[i]的索引key为indexKeys[i*stride:i*stride+n]，stride为0时所有key使用相同的n个索引key
+func (m *MultiIndexLRU[K]) addBatch(keys []K, values []interface{}, indexKeys []uint64, stride int, added []bool) {
+	hmap.CheckBatchInput(len(keys), len(values))
+	hmap.CheckBatchOutput(len(keys), added)
+	slots := m.batchHashSlots(keys)
+	n := len(m.indexes)
+	for i, key := range keys {
+		ok := m.add(key, slots[i], values[i], indexKeys[i*stride:i*stride+n])
+		if added != nil {
+			added[i] = ok
+		}
+	}
+}
+
+// 在遍历冲突链之前计算整批key的哈希桶
+func (m *MultiIndexLRU[K]) batchHashSlots(keys []K) []int32 {
+	slots := m.batchSlots.Resize(len(keys))
+	for i, key := range keys {
+		slots[i] = m.compressHash(key)
+	}
+	return slots
+}
+
+// 修改key的第index个索引key，不更新LRU时间，key不存在时返回false
+func (m *MultiIndexLRU[K]) UpdateIndexKey(key K, index int, indexKey uint64) bool {
+	node, nodeIndex := m.find(key, m.compressHash(key), false)
+	if node == nil {
+		return false
+	}
+	m.relinkIndexNode(index, nodeIndex, indexKey)
+	return true
+}
+
+func (m *MultiIndexLRU[K]) remove(key K, hashSlot int32) bool {
+	for hashListNext := m.hashSlotHead[hashSlot]; hashListNext != -1; {
+		node := m.getNode(hashListNext)
+		if node.key == key {
+			m.removeNode(node, hashListNext)
+			return true
+		}
+		hashListNext = node.hashListNext
+	}
+	return false
+}
+
+func (m *MultiIndexLRU[K]) Remove(key K) {
+	m.remove(key, m.compressHash(key))
+}
+
+// 批量删除，removed[i]表示keys[i]是否存在并被删除
+// slice长度的约定见hmap.CheckBatchInput，不满足时panic
+func (m *MultiIndexLRU[K]) RemoveBatch(keys []K, removed []bool) {
+	hmap.CheckBatchOutput(len(keys), removed)
+	slots := m.batchHashSlots(keys)
+	for i, key := range keys {
+		ok := m.remove(key, slots[i])
+		if removed != nil {
+			removed[i] = ok
+		}
+	}
+}
+
+func (m *MultiIndexLRU[K]) get(key K, hashSlot int32, peek bool) (interface{}, bool) {
+	node, nodeIndex := m.find(key, hashSlot, false)
+	if node != nil {
+		if !peek {
+			m.updateNode(node, nodeIndex, node.value)
+		}
+		return node.value, true
+	}
+	return nil, false
+}
+
+func (m *MultiIndexLRU[K]) Get(key K, peek bool) (interface{}, bool) {
+	return m.get(key, m.compressHash(key), peek)
+}
+
+// 批量查询，keys[i]对应的value和是否存在写入values[i]和found[i]
+// slice长度的约定见hmap.CheckBatchInput，不满足时panic
+func (m *MultiIndexLRU[K]) GetBatch(keys []K, peek bool, values []interface{}, found []bool) {
+	hmap.CheckBatchOutput(len(keys), values)
+	hmap.CheckBatchOutput(len(keys), found)
+	slots := m.batchHashSlots(keys)
+	for i, key := range keys {
+		value, ok := m.get(key, slots[i], peek)
+		if values != nil {
+			values[i] = value
+		}
+		if found != nil {
+			found[i] = ok
+		}
+	}
+}
+
+// 删除第index个索引key为indexKey的所有节点，返回删除的个数
+func (m *MultiIndexLRU[K]) RemoveBy(index int, indexKey uint64) int {
+	delCount := 0
+	maxScan := 0
+
+	for next := m.indexes[index].hashSlotHead[m.compressIndexHash(index, indexKey)]; next != -1; {
+		nodeIndex := next
+		in := m.getIndexNode(index, nodeIndex)
+		next = in.next
+		maxScan++
+
+		if in.key == indexKey {
+			if movedIndex := m.removeNode(m.getNode(nodeIndex), nodeIndex); movedIndex != -1 && movedIndex == next {
+				next = nodeIndex
+			}
+			delCount++
+		}
+	}
+
+	if maxScan > m.counter.MaxShortBucket {
+		m.counter.MaxShortBucket = maxScan
+	}
+	if m.counter.MaxLongBucket < delCount {
+		m.counter.MaxLongBucket = delCount
+	}
+	return delCount
+}
+
+// 返回第index个索引key为indexKey的所有value，不更新LRU时间
+func (m *MultiIndexLRU[K]) PeekBy(index int, indexKey uint64) ([]interface{}, bool) {
+	values := m.AppendBy([]interface{}{}, index, indexKey)
+	if len(values) > 0 {
+		return values, true
+	}
+	return nil, false
+}
+
+// 将第index个索引key为indexKey的所有value追加到dst并返回，不更新LRU时间
+// 复用dst时不会申请内存
+func (m *MultiIndexLRU[K]) AppendBy(dst []interface{}, index int, indexKey uint64) []interface{} {
+	maxScan := 0
+	slot := m.compressIndexHash(index, indexKey)
+
+	for next := m.indexes[index].hashSlotHead[slot]; next != -1; {
+		in := m.getIndexNode(index, next)
+		maxScan++
+		if in.key == indexKey {
+			dst = append(dst, m.getNode(next).value)
+		}
+		next = in.next
+	}
+	m.updateIndexScan(index, slot, maxScan)
+	return dst
+}
+
+// 遍历第index个索引key为indexKey的所有节点，不更新LRU时间，callback返回true时停止遍历
+// callback中不能修改LRU
+func (m *MultiIndexLRU[K]) WalkBy(index int, indexKey uint64, callback func(key K, value interface{}) bool) {
+	maxScan := 0
+	slot := m.compressIndexHash(index, indexKey)
+
+	for next := m.indexes[index].hashSlotHead[slot]; next != -1; {
+		in := m.getIndexNode(index, next)
+		maxScan++
+		if in.key == indexKey {
+			node := m.getNode(next)
+			if exit := callback(node.key, node.value); exit {
+				break
+			}
+		}
+		next = in.next
+	}
+	m.updateIndexScan(index, slot, maxScan)
+}
+
+// 更新索引冲突链的统计信息，scan宽度超过阈值时保存冲突链
+func (m *MultiIndexLRU[K]) updateIndexScan(index int, slot int32, maxScan int) {
+	if maxScan > m.counter.MaxShortBucket {
+		m.counter.MaxShortBucket = maxScan
+	}
+
+	if threshold := int(atomic.LoadUint32(&m.collisionChainDebugThreshold)); threshold > 0 && maxScan >= threshold {
+		chain := make([]byte, m.KeySize()*maxScan)
+		m.generateIndexCollisionChainIn(index, chain, slot)
+		m.debugChain.Store(chain)
+		atomic.StoreUint32(&m.debugChainRead, 0)
+	}
+}
+
+func (m *MultiIndexLRU[K]) find(key K, slot int32, isAdd bool) (*multiIndexLRUNode[K], int32) {
+	m.counter.scanTimes++
+	width := 0
+	for hashListNext := m.hashSlotHead[slot]; hashListNext != -1; {
+		width++
+		node := m.getNode(hashListNext)
+		if node.key == key {
+			m.counter.totalScan += width
+			if width > m.counter.Max {
+				m.counter.Max = width
+			}
+
+			if atomic.LoadUint32(&m.debugChainRead) == 1 {
+				// 已读，构造新的chain
+				if threshold := int(atomic.LoadUint32(&m.collisionChainDebugThreshold)); threshold > 0 && width >= threshold {
+					chain := make([]byte, m.KeySize()*width)
+					m.generateCollisionChainIn(chain, slot)
+					m.debugChain.Store(chain)
+					atomic.StoreUint32(&m.debugChainRead, 0)
+				}
+			}
+			return node, hashListNext
+		}
+		hashListNext = node.hashListNext
+	}
+	m.counter.totalScan += width
+	if isAdd {
+		width++
+	}
+	if width > m.counter.Max {
+		m.counter.Max = width
+	}
+
+	if atomic.LoadUint32(&m.debugChainRead) == 1 {
+		// 已读，构造新的chain
+		if threshold := int(atomic.LoadUint32(&m.collisionChainDebugThreshold)); threshold > 0 && width >= threshold {
+			chain := make([]byte, m.KeySize()*width)
+			offset := 0
+			if isAdd {
+				m.putKey(chain, key)
+				offset += m.KeySize()
+			}
+			m.generateCollisionChainIn(chain[offset:], slot)
+			m.debugChain.Store(chain)
+			atomic.StoreUint32(&m.debugChainRead, 0)
+		}
+	}
+	return nil, -1
+}
+
+func (m *MultiIndexLRU[K]) generateCollisionChainIn(bs []byte, index int32) {
+	offset := 0
+	bsLen := len(bs)
+
+	for hashListNext := m.hashSlotHead[index]; hashListNext != -1 && offset < bsLen; {
+		node := m.getNode(hashListNext)
+		m.putKey(bs[offset:], node.key)
+		offset += m.KeySize()
+		hashListNext = node.hashListNext
+	}
+}
+
+// 索引key写入每个KeySize()长度的开头
+func (m *MultiIndexLRU[K]) generateIndexCollisionChainIn(i int, bs []byte, slot int32) {
+	offset := 0
+	bsLen := len(bs)
+
+	for next := m.indexes[i].hashSlotHead[slot]; next != -1 && offset < bsLen; {
+		in := m.getIndexNode(i, next)
+		binary.BigEndian.PutUint64(bs[offset:], in.key)
+		offset += m.KeySize()
+		next = in.next
+	}
+}
+
+func (m *MultiIndexLRU[K]) GetCollisionChain() []byte {
+	if atomic.LoadUint32(&m.debugChainRead) == 1 {
+		return nil
+	}
+	chain := m.debugChain.Load()
+	atomic.StoreUint32(&m.debugChainRead, 1)
+	if chain == nil {
+		return nil
+	}
+	return chain.([]byte)
+}
+
+func (m *MultiIndexLRU[K]) SetCollisionChainDebugThreshold(t int) {
+	atomic.StoreUint32(&m.collisionChainDebugThreshold, uint32(t))
+	// 标记为已读，刷新链
+	if t > 0 {
+		atomic.StoreUint32(&m.debugChainRead, 1)
+	}
+}
+
+func (m *MultiIndexLRU[K]) Walk(callback func(key K, value interface{})) {
+	for i := m.timeListHead; i != -1; {
+		node := m.getNode(i)
+		callback(node.key, node.value)
+		i = node.timeListNext
+	}
+}
+
+func (m *MultiIndexLRU[K]) sliceKey(key []byte) K {
+	if len(key) != m.keySize {
+		panic(fmt.Sprintf("传入key的长度不等于 %d 字节", m.keySize))
+	}
+	return m.getKey(key)
+}
+
+// 新添加的节点的索引key都为0，key已存在时只更新value，不修改索引key
+func (m *MultiIndexLRU[K]) AddWithSlice(key []byte, value interface{}) {
+	k := m.sliceKey(key)
+	hashSlot := m.compressHash(k)
+	node, nodeIndex := m.find(k, hashSlot, true)
+	if node != nil {
+		m.updateNode(node, nodeIndex, value)
+		return
+	}
+	m.newNode(k, hashSlot, m.zeroIndexKeys, value)
+}
+
+func (m *MultiIndexLRU[K]) GetWithSlice(key []byte, peek bool) (interface{}, bool) {
+	k := m.sliceKey(key)
+	return m.get(k, m.compressHash(k), peek)
+}
+
+func (m *MultiIndexLRU[K]) RemoveWithSlice(key []byte) bool {
+	k := m.sliceKey(key)
+	return m.remove(k, m.compressHash(k))
+}
+
+// callback返回true时停止遍历
+func (m *MultiIndexLRU[K]) WalkWithSlice(callback func(key []byte, value interface{}) bool) {
+	key := make([]byte, m.keySize)
+	for i := m.timeListHead; i != -1; {
+		node := m.getNode(i)
+		m.putKey(key, node.key)
+		if exit := callback(key, node.value); exit {
+			return
+		}
+		i = node.timeListNext
+	}
+}
+
+func (m *MultiIndexLRU[K]) Clear() {
+	for i := range m.ringBuffer {
+		if m.ringBuffer[i] != nil {
+			for j := 0; j < len(m.ringBuffer[i]); j++ {
+				m.ringBuffer[i][j].value = nil
+			}
+			m.nodeBlockPool.Put(m.ringBuffer[i])
+			m.ringBuffer[i] = nil
+		}
+		if m.indexBuffer[i] != nil {
+			m.indexBlockPool.Put(m.indexBuffer[i])
+			m.indexBuffer[i] = nil
+		}
+	}
+	m.bufferStartIndex = 0
+	m.bufferEndIndex = 0
+
+	for i := range m.hashSlotHead {
+		m.hashSlotHead[i] = -1
+	}
+
+	for _, index := range m.indexes {
+		for i := range index.hashSlotHead {
+			index.hashSlotHead[i] = -1
+		}
+	}
+
+	m.timeListHead = -1
+	m.timeListTail = -1
+
+	m.size = 0
+
+	atomic.StoreUint32(&m.debugChainRead, 1)
+}
+
+func (m *MultiIndexLRU[K]) compressHash(key K) int32 {
+	return m.hash(key) & (m.hashSlots - 1)
+}
+
+func (m *MultiIndexLRU[K]) compressIndexHash(i int, indexKey uint64) int32 {
+	return keyhash.Jenkins(indexKey) & (m.indexes[i].hashSlots - 1)
+}
+
+func newMultiIndexLRU[K comparable](id string, hashSlots int, indexHashSlots []int, capacity int, keySize int, hash func(key K) int32, putKey func(bs []byte, key K), getKey func(bs []byte) K) *MultiIndexLRU[K] {
+	hashSlots, hashSlotBits := minPowerOfTwo(hashSlots)
+	nBlocks := (capacity+_BLOCK_SIZE)/_BLOCK_SIZE + 1
+	nIndexes := len(indexHashSlots)
+
+	m := &MultiIndexLRU[K]{
+		ringBuffer:   make([][]multiIndexLRUNode[K], nBlocks),
+		indexBuffer:  make([][]indexNode, nBlocks),
+		hashSlots:    int32(hashSlots),
+		hashSlotBits: uint32(hashSlotBits),
+		hashSlotHead: make([]int32, hashSlots),
+
+		indexes: make([]secondaryIndex, nIndexes),
+
+		timeListHead: -1,
+		timeListTail: -1,
+		capacity:     capacity,
+
+		keySize: keySize,
+		hash:    hash,
+		putKey:  putKey,
+		getKey:  getKey,
+
+		zeroIndexKeys: make([]uint64, nIndexes),
+
+		nodeBlockPool: sync.Pool{New: func() interface{} {
+			return make([]multiIndexLRUNode[K], _BLOCK_SIZE)
+		}},
+		indexBlockPool: sync.Pool{New: func() interface{} {
+			return make([]indexNode, _BLOCK_SIZE*nIndexes)
+		}},
+
+		counter: &DoubleKeyLRUCounter{},
+		id:      id,
+	}
+
+	for i := 0; i < len(m.hashSlotHead); i++ {
+		m.hashSlotHead[i] = -1
+	}
+
+	for i, slots := range indexHashSlots {
+		slots, _ = minPowerOfTwo(slots)
+		m.indexes[i].hashSlots = int32(slots)
+		m.indexes[i].hashSlotHead = make([]int32, slots)
+		for j := range m.indexes[i].hashSlotHead {
+			m.indexes[i].hashSlotHead[j] = -1
+		}
+	}
+
+	hmap.RegisterForDebug(m)
+
+	return m
+}
+
+// 以uint64为主key，indexHashSlots为每个二级索引的哈希桶个数，其长度即为二级索引的个数
+func NewU64MultiIndexLRU(module string, hashSlots int, indexHashSlots []int, capacity int) *MultiIndexLRU[uint64] {
+	return newMultiIndexLRU("lru64-multi-index-"+module, hashSlots, indexHashSlots, capacity, 64/8,
+		keyhash.Jenkins,
+		func(bs []byte, key uint64) {
+			binary.BigEndian.PutUint64(bs, key)
+		},
+		func(bs []byte) uint64 {
+			return binary.BigEndian.Uint64(bs)
+		},
+	)
+}
+
+// 以[2]uint64为主key，indexHashSlots为每个二级索引的哈希桶个数，其长度即为二级索引的个数
+func NewU128MultiIndexLRU(module string, hashSlots int, indexHashSlots []int, capacity int) *MultiIndexLRU[[2]uint64] {
+	return newMultiIndexLRU("lru128-multi-index-"+module, hashSlots, indexHashSlots, capacity, 128/8,
+		func(key [2]uint64) int32 {
+			return keyhash.Jenkins128(key[0], key[1])
+		},
+		func(bs []byte, key [2]uint64) {
+			binary.BigEndian.PutUint64(bs, key[0])
+			binary.BigEndian.PutUint64(bs[8:], key[1])
+		},
+		func(bs []byte) [2]uint64 {
+			return [2]uint64{binary.BigEndian.Uint64(bs), binary.BigEndian.Uint64(bs[8:])}
+		},
+	)
+}
@@ -0,0 +1,182 @@
+package lru
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+const (
+	_INDEX_POD  = 0
+	_INDEX_NODE = 1
+	_INDEX_VPC  = 2
+)
+
+func sortedValues(values []interface{}) []int {
+	result := make([]int, 0, len(values))
+	for _, v := range values {
+		result = append(result, v.(int))
+	}
+	sort.Ints(result)
+	return result
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMultiIndexLRUThreeIndexes(t *testing.T) {
+	lru := NewU64MultiIndexLRU("test", 64, []int{4, 2, 1}, 64)
+	defer lru.Close()
+
+	// flow i属于pod i%4，node i%2，vpc 0
+	for i := 0; i < 8; i++ {
+		lru.Add(uint64(i), i, uint64(i%4), uint64(i%2), 0)
+	}
+
+	if values, ok := lru.PeekBy(_INDEX_POD, 1); !ok || !equalInts(sortedValues(values), []int{1, 5}) {
+		t.Errorf("PeekBy pod结果不正确，为%v", values)
+	}
+	if values, ok := lru.PeekBy(_INDEX_NODE, 0); !ok || !equalInts(sortedValues(values), []int{0, 2, 4, 6}) {
+		t.Errorf("PeekBy node结果不正确，为%v", values)
+	}
+	if values, ok := lru.PeekBy(_INDEX_POD, 4); ok {
+		t.Errorf("PeekBy不应查到不存在的pod，实际为%v", values)
+	}
+
+	walked := 0
+	lru.WalkBy(_INDEX_VPC, 0, func(key uint64, value interface{}) bool {
+		walked++
+		return walked >= 3
+	})
+	if walked != 3 {
+		t.Errorf("WalkBy应在callback返回true时停止，实际遍历%d个", walked)
+	}
+
+	// 删除node 1上的所有flow，其它索引应同步更新
+	if n := lru.RemoveBy(_INDEX_NODE, 1); n != 4 {
+		t.Errorf("RemoveBy删除个数预期为4，实际为%d", n)
+	}
+	if values, ok := lru.PeekBy(_INDEX_POD, 1); ok {
+		t.Errorf("pod 1的flow应已删除，实际为%v", values)
+	}
+	if values, _ := lru.PeekBy(_INDEX_VPC, 0); !equalInts(sortedValues(values), []int{0, 2, 4, 6}) {
+		t.Errorf("PeekBy vpc结果不正确，为%v", values)
+	}
+	if _, ok := lru.Get(3, true); ok || lru.Size() != 4 {
+		t.Errorf("RemoveBy后Size预期为4，实际为%d", lru.Size())
+	}
+
+	lru.Clear()
+	if values, ok := lru.PeekBy(_INDEX_VPC, 0); ok || lru.Size() != 0 {
+		t.Errorf("Clear后不应有节点，实际为%v", values)
+	}
+}
+
+func TestMultiIndexLRUEvict(t *testing.T) {
+	lru := NewU128MultiIndexLRU("test", 4, []int{2, 2}, 3)
+	defer lru.Close()
+
+	for i := 0; i < 4; i++ {
+		lru.Add([2]uint64{0, uint64(i)}, i, uint64(i%2), uint64(i))
+	}
+	if _, ok := lru.Get([2]uint64{0, 0}, true); ok {
+		t.Error("超过capacity时应淘汰最久未使用的节点")
+	}
+	if values, _ := lru.PeekBy(0, 0); !equalInts(sortedValues(values), []int{2}) {
+		t.Errorf("淘汰后索引结果不正确，为%v", values)
+	}
+	if values, ok := lru.PeekBy(1, 0); ok {
+		t.Errorf("淘汰后索引结果不正确，为%v", values)
+	}
+}
+
+func TestMultiIndexLRUWithSlice(t *testing.T) {
+	m := NewU64MultiIndexLRU("test", 64, []int{64}, 64)
+	m.Add(1, "a", 5)
+	// key已存在时不修改索引key，新添加的节点索引key为0
+	m.AddWithSlice([]byte{0, 0, 0, 0, 0, 0, 0, 1}, "b")
+	m.AddWithSlice([]byte{0, 0, 0, 0, 0, 0, 0, 2}, "c")
+	if values, _ := m.PeekBy(0, 5); len(values) != 1 || values[0] != "b" {
+		t.Errorf("结果预期为%v，实际为%v", []interface{}{"b"}, values)
+	}
+	if values, _ := m.PeekBy(0, 0); len(values) != 1 || values[0] != "c" {
+		t.Errorf("结果预期为%v，实际为%v", []interface{}{"c"}, values)
+	}
+}
+
+func TestMultiIndexLRURandom(t *testing.T) {
+	type item struct {
+		indexKeys [2]uint64
+		value     int
+	}
+
+	for _, seed := range []int64{42, 233, 1024} {
+		rand.Seed(seed)
+		lru := NewU64MultiIndexLRU("test", 16, []int{4, 8}, 1024)
+		expected := make(map[uint64]item)
+
+		for i := 0; i < 10000; i++ {
+			key := uint64(rand.Intn(256))
+			switch rand.Intn(8) {
+			case 0:
+				lru.Remove(key)
+				delete(expected, key)
+			case 1:
+				index, indexKey := rand.Intn(2), uint64(rand.Intn(16))
+				n := 0
+				for k, v := range expected {
+					if v.indexKeys[index] == indexKey {
+						delete(expected, k)
+						n++
+					}
+				}
+				if deleted := lru.RemoveBy(index, indexKey); deleted != n {
+					t.Fatalf("测试%d: RemoveBy删除个数预期为%d，实际为%d", seed, n, deleted)
+				}
+			case 2:
+				index, indexKey := rand.Intn(2), uint64(rand.Intn(16))
+				v, in := expected[key]
+				if lru.UpdateIndexKey(key, index, indexKey) != in {
+					t.Fatalf("测试%d: UpdateIndexKey结果不正确", seed)
+				}
+				if in {
+					v.indexKeys[index] = indexKey
+					expected[key] = v
+				}
+			default:
+				v := item{[2]uint64{uint64(rand.Intn(16)), uint64(rand.Intn(16))}, rand.Int()}
+				lru.Add(key, v.value, v.indexKeys[0], v.indexKeys[1])
+				expected[key] = v
+			}
+		}
+
+		if lru.Size() != len(expected) {
+			t.Fatalf("测试%d: Size预期为%d，实际为%d", seed, len(expected), lru.Size())
+		}
+		for index := 0; index < 2; index++ {
+			for indexKey := uint64(0); indexKey < 16; indexKey++ {
+				want := []int{}
+				for _, v := range expected {
+					if v.indexKeys[index] == indexKey {
+						want = append(want, v.value)
+					}
+				}
+				sort.Ints(want)
+				values, _ := lru.PeekBy(index, indexKey)
+				if got := sortedValues(values); !equalInts(got, want) {
+					t.Fatalf("测试%d: 索引%d key %d结果预期为%v，实际为%v", seed, index, indexKey, want, got)
+				}
+			}
+		}
+		lru.Close()
+	}
+}
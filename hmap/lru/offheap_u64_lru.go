@@ -0,0 +1,393 @@
+package lru
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/SophonMesh/go-libs/hmap"
+	"github.com/SophonMesh/go-libs/hmap/keyhash"
+	"github.com/SophonMesh/go-libs/hmap/offheap"
+)
+
+type offHeapU64LRUNode[V any] struct {
+	key   uint64
+	value V
+
+	hashListNext int32 // 表示节点所在冲突链的下一个节点的 buffer 数组下标，-1 表示不存在
+	hashListPrev int32 // 表示节点所在冲突链的上一个节点的 buffer 数组下标，-1 表示不存在
+	timeListNext int32 // 时间链表，含义与冲突链类似
+	timeListPrev int32 // 时间链表，含义与冲突链类似
+}
+
+// 保存在offheap.Storage中的状态
+type offHeapU64LRUState struct {
+	capacity int64
+	size     int64
+
+	bufferStartIndex int32
+	bufferEndIndex   int32
+	timeListHead     int32
+	timeListTail     int32
+
+	dirty uint32 // Add、Remove、Clear和非peek的Get后为1，Sync后为0
+}
+
+// 注意：不是线程安全的
+// 与U64LRU相同，但value的类型V不能含指针，哈希桶和节点块位于mmap映射的内存中，不由GC管理：
+//   - path为空时使用匿名映射
+//   - path不为空时映射该文件，以相同的hashSlots、capacity和V重新打开时恢复Close或Sync时的数据。
+//     淘汰链表在每次访问时都会修改，无法保证进程异常退出时数据一致：上次Sync之后有过修改
+//     （包括非peek的Get）时进程异常退出，重新打开后LRU为空
+//   - 同一文件同时只能被一个OffHeapU64LRU打开，见offheap.Storage
+//   - ringBuffer的第i行固定为storage的第i个块，块释放后不归还，内存占用由capacity决定
+//   - Close后不能再使用
+type OffHeapU64LRU[V any] struct {
+	id string
+
+	ringBuffer [][]offHeapU64LRUNode[V] // 存储Map节点，以矩阵环的方式组织，开始、结束下标保存在state中
+
+	hashSlots    int32   // 上取整至2^N，哈希桶个数
+	hashSlotHead []int32 // 哈希桶，hashSlotHead[i] 表示哈希值为 i 的冲突链的第一个节点为 buffer[[ hashSlotHead[i] ]]
+
+	storage *offheap.Storage
+	state   *offHeapU64LRUState // 位于storage中
+
+	counter *Counter
+}
+
+func NewOffHeapU64LRU[V any](module string, hashSlots, capacity int, path string) (*OffHeapU64LRU[V], error) {
+	if !offheap.PointerFree[V]() {
+		var v V
+		return nil, fmt.Errorf("offheap lru value type %T contains pointers", v)
+	}
+	hashSlots, _ = minPowerOfTwo(hashSlots)
+	storage, err := offheap.Open(path, "lru64", hashSlots*4, _BLOCK_SIZE*offheap.SizeOf[offHeapU64LRUNode[V]](), offheap.Layout[offHeapU64LRUNode[V]]())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &OffHeapU64LRU[V]{
+		ringBuffer:   make([][]offHeapU64LRUNode[V], (capacity+_BLOCK_SIZE)/_BLOCK_SIZE+1),
+		hashSlots:    int32(hashSlots),
+		hashSlotHead: offheap.Slice[int32](storage.Slots()),
+		storage:      storage,
+		state:        offheap.Pointer[offHeapU64LRUState](storage.Meta()),
+		counter:      &Counter{},
+		id:           "lru64-offheap-" + module,
+	}
+	if stored := m.state.capacity; !storage.Created() && stored != int64(capacity) {
+		storage.Close()
+		return nil, fmt.Errorf("offheap lru file %s has capacity %d, not %d", path, stored, capacity)
+	}
+	// 映射只占用虚拟地址空间，实际的内存或磁盘空间在写入时才分配
+	for i := range m.ringBuffer {
+		block, err := storage.Block(i)
+		if err != nil {
+			storage.Close()
+			return nil, err
+		}
+		m.ringBuffer[i] = offheap.Slice[offHeapU64LRUNode[V]](block)
+	}
+	if storage.Created() || m.state.dirty != 0 {
+		m.state.capacity = int64(capacity)
+		m.Clear()
+		m.state.dirty = 0
+	}
+
+	return m, nil
+}
+
+func (m *OffHeapU64LRU[V]) ID() string {
+	return m.id
+}
+
+func (m *OffHeapU64LRU[V]) KeySize() int {
+	return 64 / 8
+}
+
+// 写回文件并解除映射
+func (m *OffHeapU64LRU[V]) Close() error {
+	if m.storage == nil {
+		return nil
+	}
+	err := m.Sync()
+	if closeErr := m.storage.Close(); err == nil {
+		err = closeErr
+	}
+	m.storage, m.state = nil, nil
+	m.ringBuffer, m.hashSlotHead = nil, nil
+	return err
+}
+
+// 将mmap映射的内容写回文件，之后重新打开可以恢复当前的数据
+func (m *OffHeapU64LRU[V]) Sync() error {
+	dirty := m.state.dirty
+	m.state.dirty = 0
+	if err := m.storage.Sync(); err != nil {
+		m.state.dirty = dirty
+		return err
+	}
+	return nil
+}
+
+func (m *OffHeapU64LRU[V]) Size() int {
+	return int(m.state.size)
+}
+
+func (m *OffHeapU64LRU[V]) incIndex(index int32) int32 {
+	index++
+	if index>>_BLOCK_SIZE_BITS >= int32(len(m.ringBuffer)) {
+		return 0
+	}
+	return index
+}
+
+func (m *OffHeapU64LRU[V]) getNode(index int32) *offHeapU64LRUNode[V] {
+	return &m.ringBuffer[index>>_BLOCK_SIZE_BITS][index&_BLOCK_SIZE_MASK]
+}
+
+func (m *OffHeapU64LRU[V]) pushNodeToHashList(node *offHeapU64LRUNode[V], nodeIndex int32, hash int32) {
+	node.hashListNext = m.hashSlotHead[hash]
+	node.hashListPrev = -1
+	if node.hashListNext != -1 {
+		m.getNode(node.hashListNext).hashListPrev = nodeIndex
+	}
+	m.hashSlotHead[hash] = nodeIndex
+}
+
+func (m *OffHeapU64LRU[V]) pushNodeToTimeList(node *offHeapU64LRUNode[V], nodeIndex int32) {
+	node.timeListNext = m.state.timeListHead
+	node.timeListPrev = -1
+	if node.timeListNext != -1 {
+		m.getNode(node.timeListNext).timeListPrev = nodeIndex
+	}
+	m.state.timeListHead = nodeIndex
+	if m.state.timeListTail == -1 {
+		m.state.timeListTail = nodeIndex
+	}
+}
+
+func (m *OffHeapU64LRU[V]) removeNodeFromHashList(node *offHeapU64LRUNode[V], newNext, newPrev int32) {
+	if node.hashListPrev != -1 {
+		prevNode := m.getNode(node.hashListPrev)
+		prevNode.hashListNext = newNext
+	} else {
+		m.hashSlotHead[m.compressHash(node.key)] = newNext
+	}
+
+	if node.hashListNext != -1 {
+		nextNode := m.getNode(node.hashListNext)
+		nextNode.hashListPrev = newPrev
+	}
+}
+
+func (m *OffHeapU64LRU[V]) removeNodeFromTimeList(node *offHeapU64LRUNode[V], newNext, newPrev int32) {
+	if node.timeListPrev != -1 {
+		prevNode := m.getNode(node.timeListPrev)
+		prevNode.timeListNext = newNext
+	} else {
+		m.state.timeListHead = newNext
+	}
+
+	if node.timeListNext != -1 {
+		nextNode := m.getNode(node.timeListNext)
+		nextNode.timeListPrev = newPrev
+	} else {
+		m.state.timeListTail = newPrev
+	}
+}
+
+func (m *OffHeapU64LRU[V]) removeNode(node *offHeapU64LRUNode[V], nodeIndex int32) {
+	// 从哈希链表、时间链表中删除
+	m.removeNodeFromHashList(node, node.hashListNext, node.hashListPrev)
+	m.removeNodeFromTimeList(node, node.timeListNext, node.timeListPrev)
+
+	// 将节点交换至buffer头部
+	if nodeIndex != m.state.bufferStartIndex {
+		firstNode := m.getNode(m.state.bufferStartIndex)
+		// 将firstNode内容拷贝至node
+		*node = *firstNode
+		// 修改firstNode在哈希链、时间链的上下游指向node
+		m.removeNodeFromHashList(firstNode, nodeIndex, nodeIndex)
+		m.removeNodeFromTimeList(firstNode, nodeIndex, nodeIndex)
+	}
+
+	// 节点不含指针，不需要初始化
+	m.state.bufferStartIndex = m.incIndex(m.state.bufferStartIndex)
+
+	m.state.size--
+}
+
+func (m *OffHeapU64LRU[V]) updateNode(node *offHeapU64LRUNode[V], nodeIndex int32, value V) {
+	if nodeIndex != m.state.timeListHead {
+		// 从时间链表中删除
+		m.removeNodeFromTimeList(node, node.timeListNext, node.timeListPrev)
+		// 插入时间链表头部
+		m.pushNodeToTimeList(node, nodeIndex)
+	}
+
+	node.value = value
+}
+
+func (m *OffHeapU64LRU[V]) newNode(key uint64, value V) {
+	// buffer空间检查
+	if m.state.size >= m.state.capacity {
+		node := m.getNode(m.state.timeListTail)
+		m.removeNode(node, m.state.timeListTail)
+	}
+	node := m.getNode(m.state.bufferEndIndex)
+	m.state.size++
+
+	// 新节点加入哈希链
+	m.pushNodeToHashList(node, m.state.bufferEndIndex, m.compressHash(key))
+	// 新节点加入时间链
+	m.pushNodeToTimeList(node, m.state.bufferEndIndex)
+	// 更新key、value
+	node.key = key
+	node.value = value
+
+	// 更新buffer信息
+	m.state.bufferEndIndex = m.incIndex(m.state.bufferEndIndex)
+}
+
+func (m *OffHeapU64LRU[V]) find(key uint64, isAdd bool) (*offHeapU64LRUNode[V], int32) {
+	m.counter.scanTimes++
+	width := 0
+	for hashListNext := m.hashSlotHead[m.compressHash(key)]; hashListNext != -1; {
+		width++
+		node := m.getNode(hashListNext)
+		if node.key == key {
+			m.counter.totalScan += width
+			if width > m.counter.Max {
+				m.counter.Max = width
+			}
+			return node, hashListNext
+		}
+		hashListNext = node.hashListNext
+	}
+	m.counter.totalScan += width
+	if isAdd {
+		width++
+	}
+	if width > m.counter.Max {
+		m.counter.Max = width
+	}
+	return nil, -1
+}
+
+func (m *OffHeapU64LRU[V]) Add(key uint64, value V) {
+	m.state.dirty = 1
+	node, nodeIndex := m.find(key, true)
+	if node != nil {
+		m.updateNode(node, nodeIndex, value)
+		return
+	}
+	m.newNode(key, value)
+}
+
+func (m *OffHeapU64LRU[V]) Get(key uint64, peek bool) (V, bool) {
+	node, nodeIndex := m.find(key, false)
+	if node != nil {
+		if !peek {
+			m.state.dirty = 1
+			m.updateNode(node, nodeIndex, node.value)
+		}
+		return node.value, true
+	}
+	var v V
+	return v, false
+}
+
+func (m *OffHeapU64LRU[V]) Remove(key uint64) bool {
+	for hashListNext := m.hashSlotHead[m.compressHash(key)]; hashListNext != -1; {
+		node := m.getNode(hashListNext)
+		if node.key == key {
+			m.state.dirty = 1
+			m.removeNode(node, hashListNext)
+			return true
+		}
+		hashListNext = node.hashListNext
+	}
+	return false
+}
+
+// callback返回true时停止遍历，callback中不能修改LRU
+func (m *OffHeapU64LRU[V]) Walk(callback func(key uint64, value V) bool) {
+	for i := m.state.timeListHead; i != -1; {
+		node := m.getNode(i)
+		if exit := callback(node.key, node.value); exit {
+			break
+		}
+		i = node.timeListNext
+	}
+}
+
+// value的类型必须为V
+func (m *OffHeapU64LRU[V]) AddWithSlice(key []byte, value interface{}) {
+	if len(key) != 8 {
+		panic("传入key的长度不等于 8 字节")
+	}
+	m.Add(binary.BigEndian.Uint64(key), value.(V))
+}
+
+func (m *OffHeapU64LRU[V]) GetWithSlice(key []byte, peek bool) (interface{}, bool) {
+	if len(key) != 8 {
+		panic("传入key的长度不等于 8 字节")
+	}
+	if value, ok := m.Get(binary.BigEndian.Uint64(key), peek); ok {
+		return value, true
+	}
+	return nil, false
+}
+
+func (m *OffHeapU64LRU[V]) RemoveWithSlice(key []byte) bool {
+	if len(key) != 8 {
+		panic("传入key的长度不等于 8 字节")
+	}
+	return m.Remove(binary.BigEndian.Uint64(key))
+}
+
+func (m *OffHeapU64LRU[V]) WalkWithSlice(callback func(key []byte, value interface{}) bool) {
+	var keyBytes [8]byte
+	m.Walk(func(key uint64, value V) bool {
+		binary.BigEndian.PutUint64(keyBytes[:], key)
+		return callback(keyBytes[:], value)
+	})
+}
+
+func (m *OffHeapU64LRU[V]) GetCounter() interface{} {
+	var counter *Counter
+	counter, m.counter = m.counter, &Counter{}
+	if counter.scanTimes != 0 {
+		counter.AvgScan = counter.totalScan / counter.scanTimes
+	}
+	counter.Size = int(m.state.size)
+	counter.SetMemoryUsage(m.MemoryUsage())
+	return counter
+}
+
+// 返回mmap映射中哈希桶和节点块的大小，创建时已映射所有的块，尚未写入的部分不占用物理内存
+func (m *OffHeapU64LRU[V]) MemoryUsage() hmap.MemoryUsage {
+	usage := hmap.NodeBlocksUsage(m.ringBuffer, int(m.state.size))
+	usage.SlotHeads = len(m.hashSlotHead) * 4
+	return usage
+}
+
+// 节点不含指针，只重置哈希桶和链表，不清空节点
+func (m *OffHeapU64LRU[V]) Clear() {
+	m.state.dirty = 1
+	m.state.bufferStartIndex = 0
+	m.state.bufferEndIndex = 0
+
+	for i := range m.hashSlotHead {
+		m.hashSlotHead[i] = -1
+	}
+	m.state.timeListHead = -1
+	m.state.timeListTail = -1
+
+	m.state.size = 0
+}
+
+func (m *OffHeapU64LRU[V]) compressHash(hash uint64) int32 {
+	return keyhash.Jenkins(hash) & (m.hashSlots - 1)
+}
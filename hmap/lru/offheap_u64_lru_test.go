@@ -0,0 +1,96 @@
+package lru
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type offHeapTestValue struct {
+	count uint32
+	bytes uint64
+}
+
+func TestOffHeapU64LRU(t *testing.T) {
+	if _, err := NewOffHeapU64LRU[interface{}]("test", 64, 64, ""); err == nil {
+		t.Error("value含指针时应返回错误")
+	}
+
+	m, err := NewOffHeapU64LRU[uint64]("test", 64, 64, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCache(t, m)
+
+	path := filepath.Join(t.TempDir(), "lru")
+	capacity := 1000
+	lru, err := NewOffHeapU64LRU[offHeapTestValue]("test", capacity, capacity, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < capacity*2; i++ {
+		lru.Add(uint64(i), offHeapTestValue{uint32(i), uint64(i) * 2})
+	}
+	lru.Get(uint64(capacity), false)
+	if err := lru.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后数据和淘汰顺序不变
+	if _, err := NewOffHeapU64LRU[offHeapTestValue]("test", capacity, capacity*2, path); err == nil {
+		t.Error("capacity不同时应返回错误")
+	}
+	// 大小相同但内存布局不同
+	if _, err := NewOffHeapU64LRU[[2]uint64]("test", capacity, capacity, path); err == nil {
+		t.Error("value类型不同时应返回错误")
+	}
+	lru, err = NewOffHeapU64LRU[offHeapTestValue]("test", capacity, capacity, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewOffHeapU64LRU[offHeapTestValue]("test", capacity, capacity, path); err == nil {
+		t.Error("文件已被打开时应返回错误")
+	}
+	if lru.Size() != capacity {
+		t.Errorf("Size预期为%d，实际为%d", capacity, lru.Size())
+	}
+	for i := 0; i < capacity*2; i++ {
+		value, ok := lru.Get(uint64(i), true)
+		if ok != (i >= capacity) || (ok && value != offHeapTestValue{uint32(i), uint64(i) * 2}) {
+			t.Fatalf("key %d 的结果为%v，存在=%v", i, value, ok)
+		}
+	}
+	lru.Add(uint64(capacity*2), offHeapTestValue{})
+	if _, ok := lru.Get(uint64(capacity), true); !ok {
+		t.Errorf("最近访问过的%d不应被淘汰", capacity)
+	}
+	if _, ok := lru.Get(uint64(capacity+1), true); ok {
+		t.Errorf("%d应被淘汰", capacity+1)
+	}
+
+	// 修改后未Sync即退出，重新打开后为空
+	lru.Remove(uint64(capacity))
+	lru.storage.Close()
+	lru, err = NewOffHeapU64LRU[offHeapTestValue]("test", capacity, capacity, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lru.Close()
+	if lru.Size() != 0 {
+		t.Errorf("Size预期为0，实际为%d", lru.Size())
+	}
+	lru.Add(1, offHeapTestValue{1, 2})
+	if value, ok := lru.Get(1, true); !ok || value.count != 1 {
+		t.Errorf("结果预期为%v，实际为%v", 1, value.count)
+	}
+}
+
+func BenchmarkOffHeapU64LRU(b *testing.B) {
+	capacity := 1 << 20
+	m, _ := NewOffHeapU64LRU[uint64]("test", capacity, capacity, "")
+	defer m.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Add(uint64(i), uint64(i))
+		m.Get(uint64(i>>1), false)
+	}
+}
@@ -0,0 +1,173 @@
+package lru
+
+// 淘汰策略，U64LRU、U128LRU在构造时选择，不同策略使用不同的淘汰链表：
+//   - POLICY_LRU：一个链表
+//   - POLICY_LFU：每个访问次数一个链表，访问次数饱和于 _LFU_MAX_FREQ
+//   - POLICY_SLRU：试用区和保护区两个链表，保护区满时将其最久未访问的节点降级至试用区
+//   - POLICY_W_TINY_LFU：窗口、试用区和保护区三个链表，访问频率由Count-Min Sketch估计，
+//     对只访问一次的大量key（如扫描）有较好的抵抗能力
+type Policy uint8
+
+const (
+	POLICY_LRU        Policy = iota // 淘汰最久未访问的节点
+	POLICY_LFU                      // 淘汰访问次数最少的节点，次数相同时淘汰最久未访问的节点，访问次数定期减半
+	POLICY_SLRU                     // 分段LRU，新节点进入试用区，在试用区被再次访问时进入保护区，优先淘汰试用区的节点
+	POLICY_W_TINY_LFU               // 新节点进入窗口LRU，被窗口淘汰的节点与SLRU试用区淘汰的节点比较访问频率，保留频率高的节点
+)
+
+func (p Policy) String() string {
+	switch p {
+	case POLICY_LRU:
+		return "lru"
+	case POLICY_LFU:
+		return "lfu"
+	case POLICY_SLRU:
+		return "slru"
+	case POLICY_W_TINY_LFU:
+		return "w-tiny-lfu"
+	}
+	return "unknown"
+}
+
+const (
+	_PROTECTED_PERCENT = 80 // SLRU中保护区占的比例，W-TinyLFU中为占SLRU部分的比例
+	_WINDOW_PERCENT    = 1  // W-TinyLFU中窗口LRU占的比例
+
+	_LFU_MAX_FREQ   = 15
+	_LFU_AGING_RATE = 10 // 累计访问 _LFU_AGING_RATE * capacity 次后所有节点的访问次数减半
+)
+
+// 淘汰链表的下标，POLICY_LRU只使用_LIST_PROBATION，POLICY_LFU中下标为访问次数
+const (
+	_LIST_PROBATION = 0
+	_LIST_PROTECTED = 1
+	_LIST_WINDOW    = 2
+)
+
+// 按m.policy申请淘汰链表，m.policy不合法时改为POLICY_LRU
+func (m *baseLRU[K]) initPolicy() {
+	switch m.policy {
+	case POLICY_LFU:
+		m.lists = make([]lruList, _LFU_MAX_FREQ+1)
+	case POLICY_SLRU:
+		m.lists = make([]lruList, _LIST_PROTECTED+1)
+		m.protectedCapacity = m.capacity * _PROTECTED_PERCENT / 100
+	case POLICY_W_TINY_LFU:
+		m.lists = make([]lruList, _LIST_WINDOW+1)
+		m.windowCapacity = m.capacity * _WINDOW_PERCENT / 100
+		if m.windowCapacity <= 0 {
+			m.windowCapacity = 1
+		}
+		m.protectedCapacity = (m.capacity - m.windowCapacity) * _PROTECTED_PERCENT / 100
+		m.sketch = newCountMinSketch(m.capacity)
+	default:
+		m.policy = POLICY_LRU
+		m.lists = make([]lruList, _LIST_PROBATION+1)
+	}
+	m.clearPolicy()
+}
+
+func (m *baseLRU[K]) clearPolicy() {
+	for i := range m.lists {
+		m.lists[i] = lruList{head: -1, tail: -1}
+	}
+	if m.sketch != nil {
+		m.sketch.clear()
+	}
+	m.accesses = 0
+}
+
+// 新节点加入的淘汰链表
+func (m *baseLRU[K]) newNodeList() uint8 {
+	if m.policy == POLICY_W_TINY_LFU {
+		return _LIST_WINDOW
+	}
+	return _LIST_PROBATION
+}
+
+// 按策略选择被淘汰的节点，用于POLICY_W_TINY_LFU以外的策略
+func (m *baseLRU[K]) victim() int32 {
+	switch m.policy {
+	case POLICY_LFU:
+		for i := range m.lists {
+			if m.lists[i].tail != -1 {
+				return m.lists[i].tail
+			}
+		}
+	case POLICY_SLRU:
+		if m.lists[_LIST_PROBATION].tail != -1 {
+			return m.lists[_LIST_PROBATION].tail
+		}
+		return m.lists[_LIST_PROTECTED].tail
+	}
+	return m.lists[_LIST_PROBATION].tail
+}
+
+// 访问已存在的节点
+func (m *baseLRU[K]) accessNode(node *lruNode[K], nodeIndex int32) {
+	switch m.policy {
+	case POLICY_LFU:
+		list := node.list
+		if list < _LFU_MAX_FREQ {
+			list++
+		}
+		m.moveNodeToList(node, nodeIndex, list)
+		m.accesses++
+		if m.accesses >= _LFU_AGING_RATE*m.capacity {
+			m.ageLFU()
+		}
+	case POLICY_SLRU, POLICY_W_TINY_LFU:
+		if node.list != _LIST_PROBATION {
+			m.moveNodeToHead(node, nodeIndex)
+			return
+		}
+		// 晋升至保护区，保护区满时将其最久未访问的节点降级至试用区
+		m.moveNodeToList(node, nodeIndex, _LIST_PROTECTED)
+		if m.lists[_LIST_PROTECTED].size > m.protectedCapacity {
+			tail := m.lists[_LIST_PROTECTED].tail
+			m.moveNodeToList(m.getNode(tail), tail, _LIST_PROBATION)
+		}
+	default:
+		m.moveNodeToHead(node, nodeIndex)
+	}
+}
+
+// 所有节点的访问次数减半，访问次数相同的节点保持原来的先后顺序
+func (m *baseLRU[K]) ageLFU() {
+	for freq := 1; freq < len(m.lists); freq++ {
+		for i := m.lists[freq].tail; i != -1; {
+			node := m.getNode(i)
+			prev := node.listPrev
+			m.moveNodeToList(node, i, uint8(freq/2))
+			i = prev
+		}
+	}
+	m.accesses = 0
+}
+
+// 窗口超过容量时，将窗口最久未访问的节点作为候选移入试用区；
+// 总数超过容量时，比较候选与试用区最先被淘汰的节点的访问频率，淘汰频率较低的节点
+func (m *baseLRU[K]) admit() {
+	if m.lists[_LIST_WINDOW].size <= m.windowCapacity {
+		return
+	}
+	candidate := m.lists[_LIST_WINDOW].tail
+	candidateNode := m.getNode(candidate)
+	m.moveNodeToList(candidateNode, candidate, _LIST_PROBATION)
+	if m.size <= m.capacity {
+		return
+	}
+
+	victim := m.lists[_LIST_PROBATION].tail
+	if victim == candidate {
+		victim = m.lists[_LIST_PROTECTED].tail
+	}
+	if victim != -1 {
+		victimNode := m.getNode(victim)
+		if m.sketch.estimate(candidateNode.hash) > m.sketch.estimate(victimNode.hash) {
+			m.removeNode(victimNode, victim)
+			return
+		}
+	}
+	m.removeNode(candidateNode, candidate)
+}
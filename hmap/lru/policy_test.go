@@ -0,0 +1,388 @@
+package lru
+
+import (
+	"bufio"
+	"flag"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+)
+
+var allPolicies = []Policy{POLICY_LRU, POLICY_LFU, POLICY_SLRU, POLICY_W_TINY_LFU}
+
+// 检查链表、哈希桶与节点数一致
+func checkPolicyLists[K comparable](t *testing.T, m *baseLRU[K]) {
+	t.Helper()
+	total := 0
+	for l := range m.lists {
+		size := 0
+		prev := int32(-1)
+		for i := m.lists[l].head; i != -1; i = m.getNode(i).listNext {
+			node := m.getNode(i)
+			if int(node.list) != l || node.listPrev != prev {
+				t.Fatalf("%s: 链表%d的节点%d不正确", m.Policy(), l, i)
+			}
+			if found, index := m.find(node.key, node.hash, false); found != node || index != i {
+				t.Fatalf("%s: 链表%d的节点%d不在哈希桶中", m.Policy(), l, i)
+			}
+			prev = i
+			size++
+		}
+		if m.lists[l].tail != prev || m.lists[l].size != size {
+			t.Fatalf("%s: 链表%d的长度预期为%d，实际为%d", m.Policy(), l, size, m.lists[l].size)
+		}
+		total += size
+	}
+	if total != m.Size() || m.Size() > m.capacity {
+		t.Fatalf("%s: Size预期为%d，实际为%d", m.Policy(), total, m.Size())
+	}
+	if m.policy == POLICY_SLRU || m.policy == POLICY_W_TINY_LFU {
+		if m.lists[_LIST_PROTECTED].size > m.protectedCapacity {
+			t.Fatalf("%s: 保护区长度%d超过容量%d", m.Policy(), m.lists[_LIST_PROTECTED].size, m.protectedCapacity)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	for _, policy := range allPolicies {
+		m := NewU64LRUWithPolicy("test", policy, 16, 4)
+		if m.Policy() != policy {
+			t.Errorf("结果预期为%v，实际为%v", policy, m.Policy())
+		}
+		m.Add(1, 10)
+		m.Add(2, 20)
+		m.Add(1, 11)
+		if value, ok := m.Get(1, false); !ok || value != 11 {
+			t.Errorf("%s: 结果预期为%v，实际为%v", policy, 11, value)
+		}
+		if value, ok := m.Get(2, true); !ok || value != 20 {
+			t.Errorf("%s: 结果预期为%v，实际为%v", policy, 20, value)
+		}
+		if _, ok := m.Get(3, false); ok {
+			t.Errorf("%s: 不应查到不存在的key", policy)
+		}
+		if !m.Remove(2) || m.Remove(2) || m.Size() != 1 {
+			t.Errorf("%s: Remove结果不正确", policy)
+		}
+
+		for i := uint64(10); i < 100; i++ {
+			m.Add(i, i)
+			checkPolicyLists(t, &m.baseLRU)
+		}
+		if m.Size() != 4 {
+			t.Errorf("%s: Size预期为%d，实际为%d", policy, 4, m.Size())
+		}
+		walked := 0
+		m.Walk(func(key uint64, value interface{}) {
+			if key >= 10 && value != key {
+				t.Errorf("%s: key %d 的value不正确，为%v", policy, key, value)
+			}
+			walked++
+		})
+		if walked != 4 {
+			t.Errorf("%s: Walk节点数预期为%d，实际为%d", policy, 4, walked)
+		}
+
+		counter := m.GetCounter().(*Counter)
+		if counter.Size != 4 {
+			t.Errorf("%s: 统计不正确，为%+v", policy, counter)
+		}
+
+		m.Clear()
+		checkPolicyLists(t, &m.baseLRU)
+		if _, ok := m.Get(99, true); ok || m.Size() != 0 {
+			t.Errorf("%s: Clear后不应有节点，当前长度为%d", policy, m.Size())
+		}
+		m.Close()
+	}
+}
+
+func TestPolicyU128(t *testing.T) {
+	for _, policy := range allPolicies {
+		m := NewU128LRUWithPolicy("test", policy, 1024, 1024)
+		for i := uint64(0); i < 1000; i++ {
+			m.Add(i, i+1, i)
+		}
+		// 通过TypedCache访问的是同一份数据
+		c := m.AsTypedCache()
+		for i := uint64(0); i < 1000; i++ {
+			if value, ok := c.Get([2]uint64{i, i + 1}, false); !ok || value != i {
+				t.Fatalf("%s: 结果预期为%v，实际为%v", policy, i, value)
+			}
+		}
+		if !c.Remove([2]uint64{0, 1}) || m.Remove(0, 1) || c.Size() != 999 {
+			t.Errorf("%s: Remove结果不正确", policy)
+		}
+		checkPolicyLists(t, &m.baseLRU)
+	}
+}
+
+// 随机操作后检查内部状态，容量足够时结果应与map一致
+func TestPolicyRandom(t *testing.T) {
+	for _, policy := range allPolicies {
+		for _, capacity := range []int{1, 7, 300, 1024} {
+			rand.Seed(int64(capacity))
+			m := NewU64LRUWithPolicy("test", policy, 64, capacity)
+			expected := make(map[uint64]int)
+			for i := 0; i < 20000; i++ {
+				key := uint64(rand.Intn(512))
+				switch rand.Intn(4) {
+				case 0:
+					_, in := expected[key]
+					if m.Remove(key) != in && capacity >= 512 {
+						t.Fatalf("%s: Remove(%d)结果不正确", policy, key)
+					}
+					delete(expected, key)
+				case 1:
+					value, ok := m.Get(key, false)
+					if v, in := expected[key]; ok && value != v || capacity >= 512 && ok != in {
+						t.Fatalf("%s: Get(%d)结果预期为%v，实际为%v", policy, key, v, value)
+					}
+				default:
+					v := rand.Int()
+					m.Add(key, v)
+					expected[key] = v
+				}
+				if i%1000 == 0 {
+					checkPolicyLists(t, &m.baseLRU)
+				}
+			}
+			checkPolicyLists(t, &m.baseLRU)
+		}
+	}
+}
+
+func TestPolicyLFU(t *testing.T) {
+	m := NewU64LRUWithPolicy("test", POLICY_LFU, 16, 3)
+	m.Add(1, 1)
+	m.Add(2, 2)
+	m.Add(3, 3)
+	m.Get(1, false)
+	m.Get(1, false)
+	m.Get(2, false)
+	// 3的访问次数最少
+	m.Add(4, 4)
+	if _, ok := m.Get(3, true); ok {
+		t.Error("3应被淘汰")
+	}
+	// 4与2相比访问次数更少
+	m.Add(5, 5)
+	if _, ok := m.Get(4, true); ok {
+		t.Error("4应被淘汰")
+	}
+	if _, ok := m.Get(1, true); !ok {
+		t.Error("1不应被淘汰")
+	}
+
+	// 访问次数定期减半
+	m = NewU64LRUWithPolicy("test", POLICY_LFU, 16, 4)
+	m.Add(1, 1)
+	for i := 0; i < _LFU_AGING_RATE*4; i++ {
+		m.Get(1, false)
+	}
+	if node, _ := m.find(1, m.hash(1), false); node.list != _LFU_MAX_FREQ/2 {
+		t.Errorf("结果预期为%v，实际为%v", _LFU_MAX_FREQ/2, node.list)
+	}
+	checkPolicyLists(t, &m.baseLRU)
+}
+
+func TestPolicySLRU(t *testing.T) {
+	m := NewU64LRUWithPolicy("test", POLICY_SLRU, 16, 10)
+	for i := uint64(0); i < 8; i++ {
+		m.Add(i, i)
+		m.Get(i, false)
+	}
+	// 只访问一次的key不会淘汰保护区的key
+	for i := uint64(100); i < 200; i++ {
+		m.Add(i, i)
+	}
+	for i := uint64(0); i < 8; i++ {
+		if _, ok := m.Get(i, true); !ok {
+			t.Errorf("%d不应被淘汰", i)
+		}
+	}
+	checkPolicyLists(t, &m.baseLRU)
+}
+
+func TestPolicyWTinyLFU(t *testing.T) {
+	m := NewU64LRUWithPolicy("test", POLICY_W_TINY_LFU, 128, 100)
+	for round := 0; round < 3; round++ {
+		for i := uint64(0); i < 90; i++ {
+			if _, ok := m.Get(i, false); !ok {
+				m.Add(i, i)
+			}
+		}
+	}
+	// 只访问一次的key无法替换访问频率更高的key
+	for i := uint64(1000); i < 2000; i++ {
+		m.Add(i, i)
+	}
+	hits := 0
+	for i := uint64(0); i < 90; i++ {
+		if _, ok := m.Get(i, true); ok {
+			hits++
+		}
+	}
+	if hits < 85 {
+		t.Errorf("命中个数预期不小于%d，实际为%d", 85, hits)
+	}
+	checkPolicyLists(t, &m.baseLRU)
+}
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(64)
+	for i := uint32(0); i < 20; i++ {
+		for j := uint32(0); j <= i; j++ {
+			s.increment(i * 0x9e3779b1)
+		}
+	}
+	// 只会高估，且饱和于_SKETCH_MAX_COUNT
+	for i := uint32(0); i < 20; i++ {
+		expected := int(i + 1)
+		if expected > _SKETCH_MAX_COUNT {
+			expected = _SKETCH_MAX_COUNT
+		}
+		if count := s.estimate(i * 0x9e3779b1); count < expected {
+			t.Errorf("结果预期不小于%d，实际为%d", expected, count)
+		}
+	}
+
+	hot := uint32(19)
+	hot *= 0x9e3779b1
+	s.reset()
+	if count := s.estimate(hot); count != _SKETCH_MAX_COUNT/2 {
+		t.Errorf("结果预期为%d，实际为%d", _SKETCH_MAX_COUNT/2, count)
+	}
+	s.clear()
+	if count := s.estimate(hot); count != 0 {
+		t.Errorf("结果预期为%d，实际为%d", 0, count)
+	}
+}
+
+// 访问序列，每行一个十进制uint64 key，用于回放线上的访问序列
+var traceFile = flag.String("lru.trace", "", "trace file for BenchmarkPolicyTrace, one decimal uint64 key per line")
+
+const _TRACE_CAPACITY = 1 << 12
+
+type trace struct {
+	name string
+	keys []uint64
+}
+
+// hot个key服从Zipf分布，每隔一定次数插入一段只访问一次的key
+func scanTrace(n, hot, scanInterval, scanLength int) []uint64 {
+	r := rand.New(rand.NewSource(42))
+	z := rand.NewZipf(r, 1.01, 1, uint64(hot-1))
+	keys := make([]uint64, 0, n)
+	next := uint64(hot)
+	for i := 1; len(keys) < n; i++ {
+		keys = append(keys, z.Uint64())
+		if scanInterval > 0 && i%scanInterval == 0 {
+			for j := 0; j < scanLength && len(keys) < n; j++ {
+				keys = append(keys, next)
+				next++
+			}
+		}
+	}
+	return keys
+}
+
+// 循环访问loop个key
+func loopTrace(n, loop int) []uint64 {
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = uint64(i % loop)
+	}
+	return keys
+}
+
+func loadTraces(tb testing.TB) []trace {
+	traces := []trace{
+		{"zipf", scanTrace(1<<20, 1<<16, 0, 0)},
+		{"scan", scanTrace(1<<20, 1<<16, 1<<12, 1<<12)},
+		{"loop", loopTrace(1<<20, _TRACE_CAPACITY*5/4)},
+	}
+	if *traceFile == "" {
+		return traces
+	}
+
+	f, err := os.Open(*traceFile)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	var keys []uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, err := strconv.ParseUint(scanner.Text(), 10, 64)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		tb.Fatal(err)
+	}
+	return append(traces, trace{"file", keys})
+}
+
+// Get未命中时Add，返回命中次数
+func replayTrace(m TypedCache[uint64], keys []uint64) int {
+	hits := 0
+	for _, key := range keys {
+		if _, ok := m.Get(key, false); ok {
+			hits++
+		} else {
+			m.Add(key, nil)
+		}
+	}
+	return hits
+}
+
+func TestPolicyHitRatio(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	for _, tr := range loadTraces(t)[:3] {
+		ratios := make(map[Policy]float64)
+		for _, policy := range allPolicies {
+			m := NewU64LRUWithPolicy("test", policy, _TRACE_CAPACITY, _TRACE_CAPACITY)
+			ratios[policy] = float64(replayTrace(m, tr.keys)) / float64(len(tr.keys))
+			t.Logf("%s %s: %.2f%%", tr.name, policy, ratios[policy]*100)
+		}
+		// 扫描时SLRU和W-TinyLFU的命中率应高于LRU，循环访问时W-TinyLFU的命中率应高于LRU
+		var better []Policy
+		switch tr.name {
+		case "scan":
+			better = []Policy{POLICY_SLRU, POLICY_W_TINY_LFU}
+		case "loop":
+			better = []Policy{POLICY_W_TINY_LFU}
+		}
+		for _, policy := range better {
+			if ratios[policy] <= ratios[POLICY_LRU] {
+				t.Errorf("%s: %s命中率%.4f不高于LRU命中率%.4f", tr.name, policy, ratios[policy], ratios[POLICY_LRU])
+			}
+		}
+	}
+}
+
+// 回放访问序列，报告各淘汰策略的命中率，通过 -lru.trace 指定文件回放线上的访问序列
+func BenchmarkPolicyTrace(b *testing.B) {
+	for _, tr := range loadTraces(b) {
+		for _, policy := range allPolicies {
+			b.Run(tr.name+"/"+policy.String(), func(b *testing.B) {
+				m := NewU64LRUWithPolicy("test", policy, _TRACE_CAPACITY, _TRACE_CAPACITY)
+				hits := 0
+				for i := 0; i < b.N; i += len(tr.keys) {
+					n := len(tr.keys)
+					if b.N-i < n {
+						n = b.N - i
+					}
+					hits += replayTrace(m, tr.keys[:n])
+				}
+				b.ReportMetric(float64(hits)/float64(b.N)*100, "hit%")
+			})
+		}
+	}
+}
@@ -2,6 +2,7 @@ package lru
 
 import (
 	"bytes"
+	"math/rand"
 	"testing"
 
 	"github.com/SophonMesh/go-libs/hmap"
@@ -171,6 +172,49 @@ func BenchmarkU128LRUPeek(b *testing.B) {
 	lru.Close()
 }
 
+func TestU128LRUBatch(t *testing.T) {
+	lru := NewU128LRU("test", 64, 64)
+	defer lru.Close()
+
+	key0s := []uint64{0, 0, 1, 1}
+	key1s := []uint64{0, 1, 0, 1}
+	added := make([]bool, len(key0s))
+	lru.AddBatch(key0s, key1s, []interface{}{0, 1, 2, 3}, added)
+	if lru.Size() != 4 {
+		t.Errorf("Size预期为4，实际为%d", lru.Size())
+	}
+	for i := range added {
+		if !added[i] {
+			t.Errorf("AddBatch第%d个结果预期为true", i)
+		}
+	}
+	expectPanic(t, "key1s长度不一致", func() { lru.GetBatch(key0s, key1s[:1], false, nil, nil) })
+
+	key0s = []uint64{1, 2, 0}
+	key1s = []uint64{0, 2, 1}
+	values := make([]interface{}, len(key0s))
+	found := make([]bool, len(key0s))
+	lru.GetBatch(key0s, key1s, false, values, found)
+	expectedValues := []interface{}{2, nil, 1}
+	expectedFound := []bool{true, false, true}
+	for i := range key0s {
+		if values[i] != expectedValues[i] || found[i] != expectedFound[i] {
+			t.Errorf("GetBatch第%d个结果预期为%v %v，实际为%v %v", i, expectedValues[i], expectedFound[i], values[i], found[i])
+		}
+	}
+
+	removed := make([]bool, len(key0s))
+	lru.RemoveBatch(key0s, key1s, removed)
+	for i := range key0s {
+		if removed[i] != expectedFound[i] {
+			t.Errorf("RemoveBatch第%d个结果预期为%v，实际为%v", i, expectedFound[i], removed[i])
+		}
+	}
+	if lru.Size() != 2 {
+		t.Errorf("Size预期为2，实际为%d", lru.Size())
+	}
+}
+
 func TestU128LRUCollisionChain(t *testing.T) {
 	m := NewU128LRU("test", 2, 100)
 	m.SetCollisionChainDebugThreshold(5)
@@ -200,3 +244,37 @@ func TestU128LRUCollisionChain(t *testing.T) {
 
 	m.Close()
 }
+
+func benchmarkU128LRURandom(b *testing.B) (*U128LRU, []uint64) {
+	capacity := 1 << 22
+	lru := NewU128LRU("test", capacity, capacity)
+	for i := 0; i < capacity; i++ {
+		lru.Add(0, uint64(i), i)
+	}
+	keys := make([]uint64, 1<<16)
+	for i := range keys {
+		keys[i] = uint64(rand.Intn(capacity))
+	}
+	b.ResetTimer()
+	return lru, keys
+}
+
+func BenchmarkU128LRUGetRandom(b *testing.B) {
+	lru, keys := benchmarkU128LRURandom(b)
+	for i := 0; i < b.N; i++ {
+		lru.Get(0, keys[i&(len(keys)-1)], true)
+	}
+	lru.Close()
+}
+
+func BenchmarkU128LRUGetBatch(b *testing.B) {
+	lru, keys := benchmarkU128LRURandom(b)
+	key0s := make([]uint64, _BATCH_SIZE)
+	values := make([]interface{}, _BATCH_SIZE)
+	found := make([]bool, _BATCH_SIZE)
+	for i := 0; i < b.N; i += _BATCH_SIZE {
+		start := i & (len(keys) - 1)
+		lru.GetBatch(key0s, keys[start:start+_BATCH_SIZE], true, values, found)
+	}
+	lru.Close()
+}
@@ -111,6 +111,35 @@ func TestU128U64LRUAddAndRemoveByShortKey(t *testing.T) {
 	}
 }
 
+func TestU128U64LRUUpdateShortKey(t *testing.T) {
+	lru := NewU128U64DoubleKeyLRU("test", 16, 2, 16)
+	defer lru.Close()
+
+	for i := 0; i < 4; i++ {
+		lru.Add(uint64(i), uint64(i), _FLOW_ID_TCP, i)
+	}
+	// 已存在的longKey通过Add迁移至新的shortKey
+	lru.Add(1, 1, _FLOW_ID_UDP, 1)
+	if !lru.UpdateShortKey(3, 3, _FLOW_ID_UDP) {
+		t.Error("UpdateShortKey应能找到已存在的longKey")
+	}
+	if lru.UpdateShortKey(3, 4, _FLOW_ID_UDP) {
+		t.Error("UpdateShortKey不应找到不存在的longKey")
+	}
+
+	if values, _ := lru.PeekByShortKey(_FLOW_ID_TCP); len(values) != 2 {
+		t.Errorf("shortKey %d下预期有2个节点，实际为%v", _FLOW_ID_TCP, values)
+	}
+	if n := lru.RemoveByShortKey(_FLOW_ID_UDP); n != 2 {
+		t.Errorf("RemoveByShortKey删除个数预期为2，实际为%d", n)
+	}
+	for i, expected := range []bool{true, false, true, false} {
+		if _, ok := lru.Get(uint64(i), uint64(i), true); ok != expected {
+			t.Errorf("longKey %d存在性预期为%v", i, expected)
+		}
+	}
+}
+
 func TestU128U64LRUCollisionChain(t *testing.T) {
 	m := NewU128U64DoubleKeyLRU("test", _SHORT_KEY_SORTS, _SHORT_KEY_SORTS, _CAPACITY)
 	m.SetCollisionChainDebugThreshold(5)
@@ -156,3 +185,109 @@ func TestU128U64LRUCollisionChain(t *testing.T) {
 	m.Clear()
 	m.Close()
 }
+
+func TestU128U64LRUAppendAndWalkByShortKey(t *testing.T) {
+	lru := NewU128U64DoubleKeyLRU("test", 16, 2, 16)
+	defer lru.Close()
+
+	for i := 0; i < 8; i++ {
+		if i%2 == 0 {
+			lru.Add(uint64(i), uint64(i+1), _FLOW_ID_TCP, i)
+		} else {
+			lru.Add(uint64(i), uint64(i+1), _FLOW_ID_UDP, i)
+		}
+	}
+
+	buffer := make([]interface{}, 0, 8)
+	if buffer = lru.AppendByShortKey(buffer, _FLOW_ID_TCP); !equalInts(sortedValues(buffer), []int{0, 2, 4, 6}) {
+		t.Errorf("AppendByShortKey结果不正确，为%v", buffer)
+	}
+
+	walked := 0
+	lru.WalkByShortKey(_FLOW_ID_UDP, func(longKey0, longKey1 uint64, value interface{}) bool {
+		if longKey0%2 != 1 || longKey1 != longKey0+1 || int(longKey0) != value.(int) {
+			t.Errorf("WalkByShortKey结果不正确，longKey为%d-%d，value为%v", longKey0, longKey1, value)
+		}
+		walked++
+		return false
+	})
+	if walked != 4 {
+		t.Errorf("WalkByShortKey应遍历4个节点，实际为%d", walked)
+	}
+}
+
+func TestU128U64DoubleKeyLRUBatch(t *testing.T) {
+	lru := NewU128U64DoubleKeyLRU("test", 16, 16, 16)
+
+	key0s := []uint64{0, 0, 1, 0}
+	key1s := []uint64{1, 2, 1, 1}
+	added := make([]bool, len(key0s))
+	lru.AddBatch(key0s, key1s, []uint64{1, 1, 2, 2}, []interface{}{0, 1, 2, 3}, added)
+	if !added[0] || !added[1] || !added[2] || added[3] {
+		t.Errorf("AddBatch结果不正确，added为%v", added)
+	}
+	if values, _ := lru.PeekByShortKey(2); len(values) != 2 {
+		t.Errorf("AddBatch未更新shortKey，shortKey 2为%v", values)
+	}
+
+	key0s = []uint64{0, 1, 2}
+	key1s = []uint64{1, 1, 2}
+	values := make([]interface{}, len(key0s))
+	found := make([]bool, len(key0s))
+	lru.GetBatch(key0s, key1s, true, values, found)
+	if values[0] != 3 || values[1] != 2 || !found[0] || !found[1] || found[2] {
+		t.Errorf("GetBatch结果不正确，为%v %v", values, found)
+	}
+
+	removed := make([]bool, len(key0s))
+	lru.RemoveBatch(key0s, key1s, removed)
+	if !removed[0] || !removed[1] || removed[2] || lru.Size() != 1 {
+		t.Errorf("RemoveBatch结果不正确，为%v", removed)
+	}
+
+	expectPanic(t, "AddByShortKey", func() {
+		lru.AddByShortKey([]uint64{3, 4}, []uint64{3}, 1, []interface{}{3, 4})
+	})
+	expectPanic(t, "GetBatch", func() {
+		lru.GetBatch([]uint64{3, 4}, []uint64{3, 4}, true, []interface{}{nil}, nil)
+	})
+}
+
+func benchmarkU128U64DoubleKeyLRU(b *testing.B) *U128U64DoubleKeyLRU {
+	lru := NewU128U64DoubleKeyLRU("test", 1<<16, 1<<10, 1<<16)
+	for i := 0; i < 1<<16; i++ {
+		lru.Add(0, uint64(i), uint64(i&(1<<10-1)), i)
+	}
+	b.ResetTimer()
+	return lru
+}
+
+func BenchmarkU128U64DoubleKeyLRUPeekByShortKey(b *testing.B) {
+	lru := benchmarkU128U64DoubleKeyLRU(b)
+	defer lru.Close()
+	for i := 0; i < b.N; i++ {
+		lru.PeekByShortKey(uint64(i & (1<<10 - 1)))
+	}
+}
+
+func BenchmarkU128U64DoubleKeyLRUAppendByShortKey(b *testing.B) {
+	lru := benchmarkU128U64DoubleKeyLRU(b)
+	defer lru.Close()
+	buffer := make([]interface{}, 0, 64)
+	for i := 0; i < b.N; i++ {
+		buffer = lru.AppendByShortKey(buffer[:0], uint64(i&(1<<10-1)))
+	}
+}
+
+func BenchmarkU128U64DoubleKeyLRUWalkByShortKey(b *testing.B) {
+	lru := benchmarkU128U64DoubleKeyLRU(b)
+	defer lru.Close()
+	sum := 0
+	callback := func(longKey0, longKey1 uint64, value interface{}) bool {
+		sum += value.(int)
+		return false
+	}
+	for i := 0; i < b.N; i++ {
+		lru.WalkByShortKey(uint64(i&(1<<10-1)), callback)
+	}
+}
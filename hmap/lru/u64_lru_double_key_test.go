@@ -148,6 +148,35 @@ func TestU64LRUAddAndRemoveByOneShortKey(t *testing.T) {
 
 }
 
+func TestU64LRUUpdateShortKey(t *testing.T) {
+	lru := NewU64DoubleKeyLRU("test", _CAPACITY, _SHORT_KEY_SORTS, _CAPACITY)
+	defer lru.Close()
+
+	for i := 0; i < 4; i++ {
+		lru.Add(uint64(i), _EVEN_NUMBER_KEY, i)
+	}
+	// 已存在的longKey通过Add迁移至新的shortKey
+	lru.Add(1, _ODD_NUMBER_KEY, 1)
+	if !lru.UpdateShortKey(3, _ODD_NUMBER_KEY) {
+		t.Error("UpdateShortKey应能找到已存在的longKey")
+	}
+	if lru.UpdateShortKey(_NOT_EXIST_KEY, _ODD_NUMBER_KEY) {
+		t.Error("UpdateShortKey不应找到不存在的longKey")
+	}
+
+	if values, _ := lru.PeekByShortKey(_EVEN_NUMBER_KEY); len(values) != 2 {
+		t.Errorf("shortKey %d下预期有2个节点，实际为%v", _EVEN_NUMBER_KEY, values)
+	}
+	if n := lru.RemoveByShortKey(_ODD_NUMBER_KEY); n != 2 {
+		t.Errorf("RemoveByShortKey删除个数预期为2，实际为%d", n)
+	}
+	for i, expected := range []bool{true, false, true, false} {
+		if _, ok := lru.Get(uint64(i), true); ok != expected {
+			t.Errorf("longKey %d存在性预期为%v", i, expected)
+		}
+	}
+}
+
 func TestU64DoubleKeyLRUCollisionChain(t *testing.T) {
 	m := NewU64DoubleKeyLRU("test", _SHORT_KEY_SORTS, _SHORT_KEY_SORTS, _CAPACITY)
 	m.SetCollisionChainDebugThreshold(5)
@@ -192,3 +221,118 @@ func TestU64DoubleKeyLRUCollisionChain(t *testing.T) {
 	m.Clear()
 	m.Close()
 }
+
+func TestU64LRUAppendAndWalkByShortKey(t *testing.T) {
+	lru := NewU64DoubleKeyLRU("test", _CAPACITY, _SHORT_KEY_SORTS, _CAPACITY)
+	defer lru.Close()
+
+	for i := 0; i < _CAPACITY; i++ {
+		if i%2 == 0 {
+			lru.Add(uint64(i), _EVEN_NUMBER_KEY, i)
+		} else {
+			lru.Add(uint64(i), _ODD_NUMBER_KEY, i)
+		}
+	}
+
+	buffer := make([]interface{}, 0, _CAPACITY)
+	buffer = lru.AppendByShortKey(buffer, _EVEN_NUMBER_KEY)
+	buffer = lru.AppendByShortKey(buffer, _NOT_EXIST_KEY)
+	if values := sortedValues(buffer); !equalInts(values, []int{0, 2, 4, 6, 8}) {
+		t.Errorf("AppendByShortKey结果不正确，为%v", values)
+	}
+	buffer = lru.AppendByShortKey(buffer[:0], _ODD_NUMBER_KEY)
+	if values := sortedValues(buffer); !equalInts(values, []int{1, 3, 5, 7, 9}) {
+		t.Errorf("AppendByShortKey结果不正确，为%v", values)
+	}
+
+	lru.WalkByShortKey(_ODD_NUMBER_KEY, func(longKey uint64, value interface{}) bool {
+		if longKey%2 != 1 || int(longKey) != value.(int) {
+			t.Errorf("WalkByShortKey结果不正确，longKey为%d，value为%v", longKey, value)
+		}
+		return false
+	})
+	walked := 0
+	lru.WalkByShortKey(_ODD_NUMBER_KEY, func(longKey uint64, value interface{}) bool {
+		walked++
+		return true
+	})
+	if walked != 1 {
+		t.Errorf("WalkByShortKey应在callback返回true时停止，实际遍历%d个", walked)
+	}
+}
+
+func TestU64DoubleKeyLRUBatch(t *testing.T) {
+	lru := NewU64DoubleKeyLRU("test", _CAPACITY, _SHORT_KEY_SORTS, _CAPACITY)
+
+	keys := []uint64{0, 1, 2, 0}
+	shortKeys := []uint64{_EVEN_NUMBER_KEY, _ODD_NUMBER_KEY, _EVEN_NUMBER_KEY, _ODD_NUMBER_KEY}
+	added := make([]bool, len(keys))
+	lru.AddBatch(keys, shortKeys, []interface{}{0, 1, 2, 3}, added)
+	if !added[0] || !added[1] || !added[2] || added[3] {
+		t.Errorf("AddBatch结果不正确，added为%v", added)
+	}
+	// key 0的shortKey被更新为奇数
+	if values, _ := lru.PeekByShortKey(_ODD_NUMBER_KEY); len(values) != 2 {
+		t.Errorf("AddBatch未更新shortKey，奇数为%v", values)
+	}
+
+	keys = []uint64{0, 2, _NOT_EXIST_KEY}
+	values := make([]interface{}, len(keys))
+	found := make([]bool, len(keys))
+	lru.GetBatch(keys, true, values, found)
+	if values[0] != 3 || values[1] != 2 || !found[0] || !found[1] || found[2] {
+		t.Errorf("GetBatch结果不正确，为%v %v", values, found)
+	}
+
+	removed := make([]bool, len(keys))
+	lru.RemoveBatch(keys, removed)
+	if !removed[0] || !removed[1] || removed[2] || lru.Size() != 1 {
+		t.Errorf("RemoveBatch结果不正确，为%v", removed)
+	}
+
+	expectPanic(t, "AddByShortKey", func() {
+		lru.AddByShortKey([]uint64{3, 4}, _EVEN_NUMBER_KEY, []interface{}{3})
+	})
+	expectPanic(t, "AddBatch", func() {
+		lru.AddBatch([]uint64{3, 4}, []uint64{_EVEN_NUMBER_KEY}, []interface{}{3, 4}, nil)
+	})
+}
+
+func benchmarkU64DoubleKeyLRU(b *testing.B) *U64DoubleKeyLRU {
+	lru := NewU64DoubleKeyLRU("test", 1<<16, 1<<10, 1<<16)
+	for i := 0; i < 1<<16; i++ {
+		lru.Add(uint64(i), uint64(i&(1<<10-1)), i)
+	}
+	b.ResetTimer()
+	return lru
+}
+
+func BenchmarkU64DoubleKeyLRUPeekByShortKey(b *testing.B) {
+	lru := benchmarkU64DoubleKeyLRU(b)
+	defer lru.Close()
+	for i := 0; i < b.N; i++ {
+		lru.PeekByShortKey(uint64(i & (1<<10 - 1)))
+	}
+}
+
+func BenchmarkU64DoubleKeyLRUAppendByShortKey(b *testing.B) {
+	lru := benchmarkU64DoubleKeyLRU(b)
+	defer lru.Close()
+	buffer := make([]interface{}, 0, 64)
+	for i := 0; i < b.N; i++ {
+		buffer = lru.AppendByShortKey(buffer[:0], uint64(i&(1<<10-1)))
+	}
+}
+
+func BenchmarkU64DoubleKeyLRUWalkByShortKey(b *testing.B) {
+	lru := benchmarkU64DoubleKeyLRU(b)
+	defer lru.Close()
+	sum := 0
+	callback := func(longKey uint64, value interface{}) bool {
+		sum += value.(int)
+		return false
+	}
+	for i := 0; i < b.N; i++ {
+		lru.WalkByShortKey(uint64(i&(1<<10-1)), callback)
+	}
+}
@@ -2,7 +2,9 @@ package lru
 
 import (
 	"bytes"
+	"math/rand"
 	"testing"
+	"unsafe"
 
 	"github.com/SophonMesh/go-libs/hmap"
 )
@@ -155,6 +157,91 @@ func BenchmarkU64LRUPeek(b *testing.B) {
 	lru.Close()
 }
 
+func TestU64LRUZeroCapacity(t *testing.T) {
+	lru := NewU64LRU("test", 16, 0)
+	defer lru.Close()
+
+	// capacity不大于0时为1，只保留最后添加的key
+	lru.Add(1, 1)
+	lru.Add(2, 2)
+	if _, ok := lru.Get(1, true); ok || lru.Size() != 1 {
+		t.Errorf("capacity为0时应按1处理，Size为%d", lru.Size())
+	}
+	if value, ok := lru.Get(2, true); !ok || value != 2 {
+		t.Errorf("结果预期为%v，实际为%v", 2, value)
+	}
+}
+
+func TestU64LRUBatch(t *testing.T) {
+	lru := NewU64LRU("test", 64, 64)
+	defer lru.Close()
+
+	keys := []uint64{1, 2, 3, 4, 5, 6, 7, 8}
+	values := []interface{}{10, 20, 30, 40, 50, 60, 70, 80}
+	lru.AddBatch(keys, values, nil)
+	// 批量中重复的key，后者覆盖前者
+	added := make([]bool, 3)
+	lru.AddBatch([]uint64{9, 9, 1}, []interface{}{90, 91, 10}, added)
+	if lru.Size() != 9 {
+		t.Errorf("Size预期为9，实际为%d", lru.Size())
+	}
+	if !added[0] || added[1] || added[2] {
+		t.Errorf("AddBatch结果预期为[true false false]，实际为%v", added)
+	}
+
+	keys = []uint64{1, 9, 100, 8}
+	results := make([]interface{}, len(keys))
+	found := make([]bool, len(keys))
+	lru.GetBatch(keys, true, results, found)
+	expectedResults := []interface{}{10, 91, nil, 80}
+	expectedFound := []bool{true, true, false, true}
+	for i := range keys {
+		if results[i] != expectedResults[i] || found[i] != expectedFound[i] {
+			t.Errorf("GetBatch第%d个结果预期为%v %v，实际为%v %v", i, expectedResults[i], expectedFound[i], results[i], found[i])
+		}
+	}
+
+	removed := make([]bool, len(keys))
+	lru.RemoveBatch(keys, removed)
+	for i := range keys {
+		if removed[i] != expectedFound[i] {
+			t.Errorf("RemoveBatch第%d个结果预期为%v，实际为%v", i, expectedFound[i], removed[i])
+		}
+	}
+	lru.RemoveBatch([]uint64{2}, nil)
+	if lru.Size() != 5 {
+		t.Errorf("Size预期为5，实际为%d", lru.Size())
+	}
+	for _, key := range []uint64{1, 2, 8, 9} {
+		if _, ok := lru.Get(key, true); ok {
+			t.Errorf("key %d应已被删除", key)
+		}
+	}
+}
+
+func expectPanic(t *testing.T, name string, f func()) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s应panic", name)
+		}
+	}()
+	f()
+}
+
+func TestU64LRUBatchLength(t *testing.T) {
+	lru := NewU64LRU("test", 64, 64)
+	defer lru.Close()
+
+	keys := []uint64{1, 2}
+	expectPanic(t, "AddBatch values长度不一致", func() { lru.AddBatch(keys, []interface{}{1}, nil) })
+	expectPanic(t, "AddBatch added长度不一致", func() { lru.AddBatch(keys, []interface{}{1, 2}, make([]bool, 1)) })
+	expectPanic(t, "GetBatch长度不一致", func() { lru.GetBatch(keys, true, make([]interface{}, 3), nil) })
+	expectPanic(t, "RemoveBatch长度不一致", func() { lru.RemoveBatch(keys, make([]bool, 1)) })
+	if lru.Size() != 0 {
+		t.Errorf("长度不一致时不应修改LRU，Size实际为%d", lru.Size())
+	}
+}
+
 func TestU64LRUCollisionChain(t *testing.T) {
 	m := NewU64LRU("test", 2, 100)
 	m.SetCollisionChainDebugThreshold(5)
@@ -184,3 +271,128 @@ func TestU64LRUCollisionChain(t *testing.T) {
 
 	m.Close()
 }
+
+const _BATCH_SIZE = 256
+
+// 随机访问超过cache大小的LRU，对比单个查询与批量查询
+func benchmarkU64LRURandom(b *testing.B) (*U64LRU, []uint64) {
+	capacity := 1 << 22
+	lru := NewU64LRU("test", capacity, capacity)
+	for i := 0; i < capacity; i++ {
+		lru.Add(uint64(i), i)
+	}
+	keys := make([]uint64, 1<<16)
+	for i := range keys {
+		keys[i] = uint64(rand.Intn(capacity))
+	}
+	b.ResetTimer()
+	return lru, keys
+}
+
+func BenchmarkU64LRUGetRandom(b *testing.B) {
+	lru, keys := benchmarkU64LRURandom(b)
+	for i := 0; i < b.N; i++ {
+		lru.Get(keys[i&(len(keys)-1)], true)
+	}
+	lru.Close()
+}
+
+func BenchmarkU64LRUGetBatch(b *testing.B) {
+	lru, keys := benchmarkU64LRURandom(b)
+	values := make([]interface{}, _BATCH_SIZE)
+	found := make([]bool, _BATCH_SIZE)
+	for i := 0; i < b.N; i += _BATCH_SIZE {
+		start := i & (len(keys) - 1)
+		lru.GetBatch(keys[start:start+_BATCH_SIZE], true, values, found)
+	}
+	lru.Close()
+}
+
+func BenchmarkU64LRUAddRandom(b *testing.B) {
+	lru, keys := benchmarkU64LRURandom(b)
+	for i := 0; i < b.N; i++ {
+		lru.Add(keys[i&(len(keys)-1)], nil)
+	}
+	lru.Close()
+}
+
+func BenchmarkU64LRUAddBatch(b *testing.B) {
+	lru, keys := benchmarkU64LRURandom(b)
+	values := make([]interface{}, _BATCH_SIZE)
+	for i := 0; i < b.N; i += _BATCH_SIZE {
+		start := i & (len(keys) - 1)
+		lru.AddBatch(keys[start:start+_BATCH_SIZE], values, nil)
+	}
+	lru.Close()
+}
+
+// 每次删除_BATCH_SIZE个key后重新添加，添加不计入时间
+func BenchmarkU64LRURemoveRandom(b *testing.B) {
+	lru, keys := benchmarkU64LRURandom(b)
+	values := make([]interface{}, _BATCH_SIZE)
+	for i := 0; i < b.N; i += _BATCH_SIZE {
+		start := i & (len(keys) - 1)
+		for _, key := range keys[start : start+_BATCH_SIZE] {
+			lru.Remove(key)
+		}
+		b.StopTimer()
+		lru.AddBatch(keys[start:start+_BATCH_SIZE], values, nil)
+		b.StartTimer()
+	}
+	lru.Close()
+}
+
+func BenchmarkU64LRURemoveBatch(b *testing.B) {
+	lru, keys := benchmarkU64LRURandom(b)
+	values := make([]interface{}, _BATCH_SIZE)
+	for i := 0; i < b.N; i += _BATCH_SIZE {
+		start := i & (len(keys) - 1)
+		lru.RemoveBatch(keys[start:start+_BATCH_SIZE], nil)
+		b.StopTimer()
+		lru.AddBatch(keys[start:start+_BATCH_SIZE], values, nil)
+		b.StartTimer()
+	}
+	lru.Close()
+}
+
+func TestU64LRUMemoryUsage(t *testing.T) {
+	capacity := 1024
+	lru := NewU64LRU("test", capacity, capacity)
+	nodeSize := int(unsafe.Sizeof(lruNode[uint64]{}))
+
+	usage := lru.MemoryUsage()
+	if usage.SlotHeads != capacity*4 || usage.BlocksInUse != 0 || usage.BlocksReserved != 0 {
+		t.Errorf("结果预期为%v，实际为%v", hmap.MemoryUsage{SlotHeads: capacity * 4}, usage)
+	}
+
+	// 添加300个节点，申请2个块
+	for i := 0; i < 300; i++ {
+		lru.Add(uint64(i), uint64(i))
+	}
+	usage = lru.MemoryUsage()
+	if usage.BlocksInUse != 300*nodeSize || usage.BlocksReserved != (2*_BLOCK_SIZE-300)*nodeSize {
+		t.Errorf("节点块结果预期为%d/%d，实际为%d/%d",
+			300*nodeSize, (2*_BLOCK_SIZE-300)*nodeSize, usage.BlocksInUse, usage.BlocksReserved)
+	}
+	counter := lru.GetCounter().(*Counter)
+	if counter.MemorySlotHeads != usage.SlotHeads || counter.MemoryBlocksInUse != usage.BlocksInUse ||
+		counter.MemoryBlocksReserved != usage.BlocksReserved || counter.MemoryOthers != usage.Others {
+		t.Errorf("Counter结果预期为%v，实际为%v", usage, counter)
+	}
+
+	// 删除的节点所在的块仍被占用
+	for i := 0; i < 100; i++ {
+		lru.Remove(uint64(i))
+	}
+	usage = lru.MemoryUsage()
+	if usage.BlocksInUse != 200*nodeSize || usage.BlocksReserved != (2*_BLOCK_SIZE-200)*nodeSize {
+		t.Errorf("节点块结果预期为%d/%d，实际为%d/%d",
+			200*nodeSize, (2*_BLOCK_SIZE-200)*nodeSize, usage.BlocksInUse, usage.BlocksReserved)
+	}
+
+	lru.Clear()
+	usage = lru.MemoryUsage()
+	if usage.BlocksInUse != 0 || usage.BlocksReserved != 0 || usage.Total() != usage.SlotHeads+usage.Others {
+		t.Errorf("Clear后节点块预期为0，实际为%v", usage)
+	}
+}
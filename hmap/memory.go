@@ -0,0 +1,61 @@
+package hmap
+
+import "unsafe"
+
+const (
+	SLICE_HEADER_SIZE = int(unsafe.Sizeof([]byte(nil)))
+	POINTER_SIZE      = int(unsafe.Sizeof(uintptr(0)))
+	INTERFACE_SIZE    = int(unsafe.Sizeof(interface{}(nil)))
+)
+
+// 容器占用的内存（字节），只统计容器自身的结构，不包括interface{}类型的value、Entry等指向的内存
+type MemoryUsage struct {
+	SlotHeads      int // 哈希桶，以及时间链表头等长度固定的数组
+	BlocksInUse    int // 节点块中存放有效节点的部分
+	BlocksReserved int // 节点块中已申请但没有存放有效节点的部分，块在释放前不会归还sync.Pool
+	Others         int // 块索引、批量操作和输出的缓冲区等
+}
+
+func (u MemoryUsage) Total() int {
+	return u.SlotHeads + u.BlocksInUse + u.BlocksReserved + u.Others
+}
+
+func (u *MemoryUsage) Add(other MemoryUsage) {
+	u.SlotHeads += other.SlotHeads
+	u.BlocksInUse += other.BlocksInUse
+	u.BlocksReserved += other.BlocksReserved
+	u.Others += other.Others
+}
+
+// 统计节点块，allocated为已申请的节点数，used为有效节点数
+func (u *MemoryUsage) AddNodes(allocated, used, nodeSize int) {
+	u.BlocksInUse += used * nodeSize
+	u.BlocksReserved += (allocated - used) * nodeSize
+}
+
+// 统计以矩阵方式组织的节点块，未申请的块为nil，used为有效节点数，块索引计入Others
+func NodeBlocksUsage[B ~[]T, T any](blocks []B, used int) MemoryUsage {
+	allocated := 0
+	for _, block := range blocks {
+		allocated += len(block)
+	}
+	var node T
+	usage := MemoryUsage{Others: cap(blocks) * SLICE_HEADER_SIZE}
+	usage.AddNodes(allocated, used, int(unsafe.Sizeof(node)))
+	return usage
+}
+
+// 嵌入各容器的Counter，GetCounter时通过SetMemoryUsage填入
+type MemoryCounter struct {
+	MemorySlotHeads      int `statsd:"memory-slot-heads"`
+	MemoryBlocksInUse    int `statsd:"memory-blocks-in-use"`
+	MemoryBlocksReserved int `statsd:"memory-blocks-reserved"`
+	MemoryOthers         int `statsd:"memory-others"`
+}
+
+func (c *MemoryCounter) SetMemoryUsage(usage MemoryUsage) {
+	c.MemorySlotHeads = usage.SlotHeads
+	c.MemoryBlocksInUse = usage.BlocksInUse
+	c.MemoryBlocksReserved = usage.BlocksReserved
+	c.MemoryOthers = usage.Others
+}
@@ -0,0 +1,40 @@
+package hmap
+
+import (
+	"testing"
+	"unsafe"
+)
+
+type testNode struct {
+	key   uint64
+	value uint32
+	next  int32
+}
+
+func TestNodeBlocksUsage(t *testing.T) {
+	nodeSize := int(unsafe.Sizeof(testNode{}))
+	blocks := make([][]testNode, 4)
+	blocks[1] = make([]testNode, 256)
+	blocks[2] = make([]testNode, 256)
+	usage := NodeBlocksUsage(blocks, 300)
+	expected := MemoryUsage{
+		BlocksInUse:    300 * nodeSize,
+		BlocksReserved: 212 * nodeSize,
+		Others:         4 * SLICE_HEADER_SIZE,
+	}
+	if usage != expected {
+		t.Errorf("结果预期为%v，实际为%v", expected, usage)
+	}
+
+	usage.Add(MemoryUsage{SlotHeads: 1024, Others: 8})
+	if usage.Total() != 1024+512*nodeSize+4*SLICE_HEADER_SIZE+8 {
+		t.Errorf("Total结果预期为%d，实际为%d", 1024+512*nodeSize+4*SLICE_HEADER_SIZE+8, usage.Total())
+	}
+
+	var counter MemoryCounter
+	counter.SetMemoryUsage(usage)
+	if counter.MemorySlotHeads != usage.SlotHeads || counter.MemoryBlocksInUse != usage.BlocksInUse ||
+		counter.MemoryBlocksReserved != usage.BlocksReserved || counter.MemoryOthers != usage.Others {
+		t.Errorf("MemoryCounter结果预期为%v，实际为%+v", usage, counter)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !(linux || darwin || freebsd)
+
+package offheap
+
+import (
+	"io"
+	"os"
+)
+
+// 不支持flock，同一文件只能被一个Storage打开需要由使用者保证
+func flock(_ *os.File) error {
+	return nil
+}
+
+// 不支持mmap的平台上使用Go堆上的内存代替，[]byte不含指针，GC不会扫描其内容；
+// 映射文件时读入文件的内容，Sync和Close时写回
+func mmap(file *os.File, offset int64, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if file == nil {
+		return b, nil
+	}
+	if _, err := file.ReadAt(b, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+func msync(file *os.File, offset int64, b []byte) error {
+	if file == nil {
+		return nil
+	}
+	_, err := file.WriteAt(b, offset)
+	return err
+}
+
+func munmap(file *os.File, offset int64, b []byte) error {
+	return msync(file, offset, b)
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd
+
+package offheap
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// 加排他锁，文件已被其它进程或Storage锁住时返回错误，关闭文件时释放
+func flock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// file为nil时进行匿名映射
+func mmap(file *os.File, offset int64, size int) ([]byte, error) {
+	if file == nil {
+		return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	}
+	return syscall.Mmap(int(file.Fd()), offset, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func msync(_ *os.File, _ int64, b []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// MAP_SHARED的内容在解除映射后由操作系统写回文件
+func munmap(_ *os.File, _ int64, b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,296 @@
+package offheap
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"reflect"
+	"unsafe"
+)
+
+const (
+	_HEADER_SIZE      = 256     // 文件头的字节数，其中从 _USER_META_OFFSET 开始的部分由使用者定义
+	_USER_META_OFFSET = 128     // 使用者定义的元数据在文件头中的偏移
+	_CHUNK_SIZE       = 4 << 20 // 每次映射的块区域的目标字节数
+	_VERSION          = 2
+)
+
+var _MAGIC = [8]byte{'H', 'M', 'A', 'P', 'O', 'F', 'F', 'H'}
+
+type header struct {
+	magic     [8]byte
+	version   uint32
+	blockSize uint32
+	slotsSize uint64
+	name      [32]byte
+	layout    uint64 // 块中存放的类型的内存布局，见Layout
+}
+
+// 不在Go堆上的块存储，用于存放不含指针的哈希桶和节点块，使GC的代价不随节点数增长：
+//   - 内存布局为 文件头 | 哈希桶 | chunk 0 | chunk 1 | ...，每个chunk包含若干个块，按需映射
+//   - path为空时使用匿名映射，进程退出后数据丢失
+//   - path不为空时映射文件，以相同的name、slotsSize、blockSize、layout重新打开时可以恢复其中的数据，
+//     进程退出时未写回的数据由操作系统写回，机器掉电前需要调用Sync
+//   - 打开文件时加排他的flock，同一文件同时只能被一个Storage打开，不支持flock的平台上不加锁，
+//     需要由使用者保证
+//
+// 注意：不是线程安全的，Close之后通过Slots、Block、Meta得到的内存都不能再访问
+type Storage struct {
+	file     *os.File
+	fileSize int64
+	created  bool
+
+	meta     []byte // 文件头和哈希桶
+	metaSize int
+
+	blockSize   int
+	chunkBlocks int // 每个chunk包含的块数
+	chunkSize   int // 上取整至页大小
+	chunks      [][]byte
+}
+
+func roundUp(n, align int) int {
+	return (n + align - 1) / align * align
+}
+
+// 打开块存储，slotsSize为哈希桶区域的字节数，blockSize为每个块的字节数，layout为块中存放的类型的Layout。
+// name用于区分不同的容器，重新打开文件时name、slotsSize、blockSize、layout必须与创建时相同
+func Open(path, name string, slotsSize, blockSize int, layout uint64) (*Storage, error) {
+	if len(name) > len(header{}.name) {
+		return nil, fmt.Errorf("offheap name %q is too long", name)
+	}
+	if blockSize <= 0 || blockSize%8 != 0 {
+		return nil, fmt.Errorf("invalid offheap block size %d", blockSize)
+	}
+	pageSize := os.Getpagesize()
+	s := &Storage{
+		metaSize:  roundUp(_HEADER_SIZE+slotsSize, pageSize),
+		blockSize: blockSize,
+	}
+	s.chunkBlocks = _CHUNK_SIZE / blockSize
+	if s.chunkBlocks == 0 {
+		s.chunkBlocks = 1
+	}
+	s.chunkSize = roundUp(s.chunkBlocks*blockSize, pageSize)
+
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := flock(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("offheap file %s is in use: %w", path, err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		s.file = file
+		s.fileSize = info.Size()
+		s.created = s.fileSize == 0
+		if !s.created && s.fileSize < int64(s.metaSize) {
+			file.Close()
+			return nil, fmt.Errorf("offheap file %s is truncated", path)
+		}
+	} else {
+		s.created = true
+	}
+
+	meta, err := s.mmap(0, s.metaSize)
+	if err != nil {
+		s.closeFile()
+		return nil, err
+	}
+	s.meta = meta
+
+	h := Pointer[header](s.meta)
+	if s.created {
+		h.magic = _MAGIC
+		h.version = _VERSION
+		h.blockSize = uint32(blockSize)
+		h.slotsSize = uint64(slotsSize)
+		copy(h.name[:], name)
+		h.layout = layout
+	} else {
+		var expectName [32]byte
+		copy(expectName[:], name)
+		if h.magic != _MAGIC || h.version != _VERSION || h.name != expectName ||
+			h.blockSize != uint32(blockSize) || h.slotsSize != uint64(slotsSize) || h.layout != layout {
+			s.Close()
+			return nil, fmt.Errorf("offheap file %s does not match %s (slots %d bytes, block %d bytes, layout %#x)", path, name, slotsSize, blockSize, layout)
+		}
+	}
+	return s, nil
+}
+
+// 映射文件或匿名内存中从offset开始的size字节，文件长度不足时扩展文件
+func (s *Storage) mmap(offset int64, size int) ([]byte, error) {
+	if s.file != nil && s.fileSize < offset+int64(size) {
+		if err := s.file.Truncate(offset + int64(size)); err != nil {
+			return nil, err
+		}
+		s.fileSize = offset + int64(size)
+	}
+	return mmap(s.file, offset, size)
+}
+
+func (s *Storage) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// 是否为新建的存储，新建时哈希桶、元数据和块的内容都为0
+func (s *Storage) Created() bool {
+	return s.created
+}
+
+// 返回哈希桶区域
+func (s *Storage) Slots() []byte {
+	h := Pointer[header](s.meta)
+	return s.meta[_HEADER_SIZE : _HEADER_SIZE+int(h.slotsSize)]
+}
+
+// 返回由使用者定义的元数据区域，用于保存节点数、链表头尾等状态
+func (s *Storage) Meta() []byte {
+	return s.meta[_USER_META_OFFSET:_HEADER_SIZE]
+}
+
+// 返回第i个块，所在的chunk尚未映射时进行映射。同一个i总是返回同一段内存
+func (s *Storage) Block(i int) ([]byte, error) {
+	chunk := i / s.chunkBlocks
+	for len(s.chunks) <= chunk {
+		offset := int64(s.metaSize) + int64(len(s.chunks))*int64(s.chunkSize)
+		b, err := s.mmap(offset, s.chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		s.chunks = append(s.chunks, b)
+	}
+	offset := i % s.chunkBlocks * s.blockSize
+	return s.chunks[chunk][offset : offset+s.blockSize : offset+s.blockSize], nil
+}
+
+// 已映射的字节数
+func (s *Storage) MappedSize() int {
+	return s.metaSize + len(s.chunks)*s.chunkSize
+}
+
+// 将映射的内容写回文件，匿名映射时不做任何操作
+func (s *Storage) Sync() error {
+	if s.file == nil {
+		return nil
+	}
+	if err := msync(s.file, 0, s.meta); err != nil {
+		return err
+	}
+	for i, chunk := range s.chunks {
+		if err := msync(s.file, int64(s.metaSize)+int64(i)*int64(s.chunkSize), chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 解除所有映射并关闭文件，返回遇到的第一个错误
+func (s *Storage) Close() error {
+	var firstErr error
+	if s.meta != nil {
+		firstErr = munmap(s.file, 0, s.meta)
+		s.meta = nil
+	}
+	for i, chunk := range s.chunks {
+		if err := munmap(s.file, int64(s.metaSize)+int64(i)*int64(s.chunkSize), chunk); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.chunks = nil
+	if err := s.closeFile(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// T是否不含指针，只有不含指针的类型才能存放在Storage中
+func PointerFree[T any]() bool {
+	return pointerFree(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func pointerFree(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return t.Len() == 0 || pointerFree(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !pointerFree(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// 返回T的内存布局的哈希值，由各字段的类型、大小和偏移计算，不包括类型名和字段名，
+// 用于检查重新打开的文件中存放的类型是否与创建时相同
+func Layout[T any]() uint64 {
+	h := fnv.New64a()
+	writeLayout(h, reflect.TypeOf((*T)(nil)).Elem())
+	return h.Sum64()
+}
+
+func writeLayout(h io.Writer, t reflect.Type) {
+	fmt.Fprintf(h, "%d:%d:%d", t.Kind(), t.Size(), t.Align())
+	switch t.Kind() {
+	case reflect.Array:
+		fmt.Fprintf(h, "[%d]", t.Len())
+		writeLayout(h, t.Elem())
+	case reflect.Struct:
+		h.Write([]byte{'{'})
+		for i := 0; i < t.NumField(); i++ {
+			fmt.Fprintf(h, "@%d:", t.Field(i).Offset)
+			writeLayout(h, t.Field(i).Type)
+		}
+		h.Write([]byte{'}'})
+	}
+}
+
+func SizeOf[T any]() int {
+	var v T
+	return int(unsafe.Sizeof(v))
+}
+
+// 将b解释为[]T，T必须不含指针，b的起始地址必须满足T的对齐要求
+func Slice[T any](b []byte) []T {
+	var v T
+	size, align := int(unsafe.Sizeof(v)), uintptr(unsafe.Alignof(v))
+	if !PointerFree[T]() {
+		panic(fmt.Sprintf("offheap: %T contains pointers", v))
+	}
+	if size == 0 || len(b) < size {
+		return nil
+	}
+	if uintptr(unsafe.Pointer(&b[0]))%align != 0 {
+		panic(fmt.Sprintf("offheap: %T is not aligned", v))
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&b[0])), len(b)/size)
+}
+
+// 将b的开头解释为*T，要求与Slice相同
+func Pointer[T any](b []byte) *T {
+	s := Slice[T](b)
+	if len(s) == 0 {
+		panic(fmt.Sprintf("offheap: %d bytes is too short for %T", len(b), *new(T)))
+	}
+	return &s[0]
+}
@@ -0,0 +1,138 @@
+package offheap
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type testNode struct {
+	key   uint64
+	value uint32
+	next  int32
+}
+
+type testMeta struct {
+	size uint64
+}
+
+func TestPointerFree(t *testing.T) {
+	if !PointerFree[testNode]() || !PointerFree[[4]uint64]() || !PointerFree[int32]() {
+		t.Error("不含指针的类型判断错误")
+	}
+	if PointerFree[*int]() || PointerFree[string]() || PointerFree[[]byte]() ||
+		PointerFree[interface{}]() || PointerFree[struct{ a [2]*int }]() {
+		t.Error("含指针的类型判断错误")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("含指针的类型应panic")
+		}
+	}()
+	Slice[string](make([]byte, 64))
+}
+
+func TestLayout(t *testing.T) {
+	type sameNode struct {
+		k uint64
+		v uint32
+		n int32
+	}
+	if Layout[testNode]() != Layout[sameNode]() {
+		t.Error("内存布局相同的类型Layout应相同")
+	}
+	if Layout[testNode]() == Layout[[2]uint64]() || Layout[[2]uint64]() == Layout[[4]uint32]() ||
+		Layout[uint64]() == Layout[int64]() {
+		t.Error("内存布局不同的类型Layout应不同")
+	}
+}
+
+func testStorage(t *testing.T, path string) {
+	blockSize := 256 * SizeOf[testNode]()
+	layout := Layout[testNode]()
+	s, err := Open(path, "test", 1024*4, blockSize, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Created() {
+		t.Error("应为新建的存储")
+	}
+	slots := Slice[int32](s.Slots())
+	if len(slots) != 1024 {
+		t.Errorf("哈希桶数预期为%d，实际为%d", 1024, len(slots))
+	}
+	for i := range slots {
+		slots[i] = int32(i)
+	}
+	// 跨越多个chunk
+	blocks := s.chunkBlocks*2 + 1
+	for i := 0; i < blocks; i++ {
+		b, err := s.Block(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := Slice[testNode](b)
+		if len(nodes) != 256 {
+			t.Fatalf("块内节点数预期为%d，实际为%d", 256, len(nodes))
+		}
+		for j := range nodes {
+			nodes[j] = testNode{key: uint64(i*256 + j), value: uint32(i), next: int32(j)}
+		}
+	}
+	Pointer[testMeta](s.Meta()).size = uint64(blocks * 256)
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if path == "" {
+		return
+	}
+
+	// 参数不同时不能打开
+	if _, err := Open(path, "test", 1024*4, blockSize*2, layout); err == nil {
+		t.Error("块大小不同时应返回错误")
+	}
+	if _, err := Open(path, "other", 1024*4, blockSize, layout); err == nil {
+		t.Error("name不同时应返回错误")
+	}
+	if _, err := Open(path, "test", 1024*4, blockSize, Layout[[2]uint64]()); err == nil {
+		t.Error("layout不同时应返回错误")
+	}
+
+	s, err = Open(path, "test", 1024*4, blockSize, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Created() {
+		t.Error("应为重新打开的存储")
+	}
+	if _, err := Open(path, "test", 1024*4, blockSize, layout); err == nil {
+		t.Error("文件已被打开时应返回错误")
+	}
+	if size := Pointer[testMeta](s.Meta()).size; size != uint64(blocks*256) {
+		t.Errorf("元数据预期为%d，实际为%d", blocks*256, size)
+	}
+	for i, slot := range Slice[int32](s.Slots()) {
+		if slot != int32(i) {
+			t.Fatalf("哈希桶%d预期为%d，实际为%d", i, i, slot)
+		}
+	}
+	for i := 0; i < blocks; i++ {
+		b, err := s.Block(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, node := range Slice[testNode](b) {
+			if node.key != uint64(i*256+j) || node.value != uint32(i) || node.next != int32(j) {
+				t.Fatalf("节点%d预期为%v，实际为%v", i*256+j, testNode{uint64(i*256 + j), uint32(i), int32(j)}, node)
+			}
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	testStorage(t, "")
+	testStorage(t, filepath.Join(t.TempDir(), "storage"))
+}
@@ -0,0 +1,198 @@
+package timemap
+
+import (
+	"strconv"
+
+	"github.com/SophonMesh/go-libs/hmap"
+	"github.com/SophonMesh/go-libs/hmap/keyhash"
+)
+
+// TimeMap和Map的时间戳为uint32的Unix秒，TimeMap64的时间戳为int64的Unix纳秒
+type timestamp interface {
+	~uint32 | ~int64
+}
+
+// TimeMap、TimeMap64和Map的公共部分，包括ring、哈希链、时间链和时间窗口的推进
+// D为节点中保存的数据，时间窗口输出时调用flushSlot，由外层决定如何输出节点中的数据
+type baseMap[T timestamp, D any] struct {
+	id int
+
+	entries  int
+	capacity int
+
+	hashSlots    int
+	hashSlotBits int
+
+	timeInterval       T
+	timeSlots          int
+	timeRingStartIndex int
+	timeRingStartTime  T
+
+	r *ring[D]
+
+	hashLists []hashLinkedList[D]
+	timeLists []timeLinkedList[D]
+
+	flushSlot func(index int) // 输出时间链index中的所有节点
+}
+
+func (m *baseMap[T, D]) init(id, capacity, hashSlots int, timeInterval T, timeSlots int, flushSlot func(index int)) {
+	if timeInterval <= 0 {
+		panic("timeInterval must be positive")
+	}
+	m.id = id
+	m.capacity = capacity
+	m.hashSlots, m.hashSlotBits = minPowerOfTwo(hashSlots)
+	m.timeInterval = timeInterval
+	m.timeSlots = timeSlots
+	m.r = newRing[D](capacity)
+	m.hashLists = makeHashLinkedLists[D](m.hashSlots)
+	m.timeLists = makeTimeLinkedLists[D](timeSlots)
+	m.flushSlot = flushSlot
+}
+
+func minPowerOfTwo(v int) (int, int) {
+	for i := 0; i < 30; i++ {
+		if p := 1 << i; v <= p {
+			return p, i
+		}
+	}
+	return 1, 0
+}
+
+// 对齐至时间窗口的开始时间，时间戳不能为负数
+func (m *baseMap[T, D]) alignTime(timestamp T) T {
+	return timestamp - timestamp%m.timeInterval
+}
+
+func (m *baseMap[T, D]) AdvanceTime(timestamp T) {
+	if timestamp < m.timeRingStartTime {
+		return
+	}
+	// 先计算窗口数再与timeSlots比较，避免乘法溢出
+	advanceSlots := int64((timestamp-m.timeRingStartTime)/m.timeInterval) - int64(m.timeSlots) + 1
+	if advanceSlots <= 0 {
+		return
+	}
+	if advanceSlots >= int64(m.timeSlots) {
+		for i := range m.timeLists {
+			// 从当前开始flush
+			index := (i + m.timeRingStartIndex) % m.timeSlots
+			m.flushSlot(index)
+		}
+		m.timeRingStartIndex = 0
+		m.timeRingStartTime = m.alignTime(timestamp) - T(m.timeSlots-1)*m.timeInterval
+		return
+	}
+	for i := 0; i < int(advanceSlots); i++ {
+		index := (i + m.timeRingStartIndex) % m.timeSlots
+		m.flushSlot(index)
+	}
+	m.timeRingStartIndex = (int(advanceSlots) + m.timeRingStartIndex) % m.timeSlots
+	m.timeRingStartTime += T(advanceSlots) * m.timeInterval
+}
+
+// 按时间顺序输出所有时间窗口
+func (m *baseMap[T, D]) flushAll() {
+	for i := 0; i < m.timeSlots; i++ {
+		m.flushSlot((i + m.timeRingStartIndex) % m.timeSlots)
+	}
+}
+
+// 按时间顺序输出所有结束时间不晚于until的时间窗口，并将时间窗口的起点推进至until所在的窗口
+func (m *baseMap[T, D]) drain(until T) {
+	if until < m.timeRingStartTime {
+		return
+	}
+	drainSlots := int64((until - m.timeRingStartTime) / m.timeInterval)
+	if drainSlots <= 0 {
+		return
+	}
+	if drainSlots >= int64(m.timeSlots) {
+		m.flushAll()
+		m.timeRingStartIndex = 0
+	} else {
+		for i := 0; i < int(drainSlots); i++ {
+			m.flushSlot((i + m.timeRingStartIndex) % m.timeSlots)
+		}
+		m.timeRingStartIndex = (int(drainSlots) + m.timeRingStartIndex) % m.timeSlots
+	}
+	m.timeRingStartTime += T(drainSlots) * m.timeInterval
+}
+
+// 返回对齐至时间窗口的timestamp，并推进时间窗口，用于添加节点前
+func (m *baseMap[T, D]) advanceTo(timestamp T) T {
+	timestamp = m.alignTime(timestamp)
+	m.AdvanceTime(timestamp)
+	return timestamp
+}
+
+// 返回timestamp（已对齐）所在时间窗口中hash相等且match返回true的节点，以及hash对应的哈希链
+func (m *baseMap[T, D]) find(timestamp T, hash uint64, match func(entry *D) bool) (*node[D], int) {
+	slot := m.compressHash(keyhash.Jenkins128(uint64(timestamp), hash))
+	return m.hashLists[slot].find(m.r, hash, match), slot
+}
+
+// 在timestamp（已对齐）所在时间窗口中添加节点，slot为find返回的哈希链，调用者需设置节点的entry
+func (m *baseMap[T, D]) insert(timestamp T, hash uint64, slot int) *node[D] {
+	n := m.r.getNext()
+	n.hash = hash
+	n.hashSlot = slot
+	m.hashLists[slot].pushFront(m.r, n)
+	n.timeSlot = (int((timestamp-m.timeRingStartTime)/m.timeInterval) + m.timeRingStartIndex) % m.timeSlots
+	m.timeLists[n.timeSlot].pushFront(m.r, n)
+	m.entries++
+	return n
+}
+
+// 从ring、哈希链和时间链中删除节点，返回节点中的数据和时间链中的下一个节点
+func (m *baseMap[T, D]) removeNode(index int) (D, int) {
+	// 当前节点换到ring中第一个
+	if m.r.swapFront(index) {
+		front, swapped := m.r.getFront(), m.r.get(index)
+		m.hashLists[front.hashSlot].fixLink(m.r, front, swapped.index)
+		m.timeLists[front.timeSlot].fixLink(m.r, front, swapped.index)
+		m.hashLists[swapped.hashSlot].fixLink(m.r, swapped, front.index)
+		m.timeLists[swapped.timeSlot].fixLink(m.r, swapped, front.index)
+	}
+	n := m.r.getFront()
+	entry, next := n.entry, n.timeLink.next
+	m.hashLists[n.hashSlot].remove(m.r, n)
+	m.timeLists[n.timeSlot].remove(m.r, n)
+	m.r.popFront()
+	m.entries--
+	return entry, next
+}
+
+// 按时间链遍历timestamp所在时间窗口中的所有节点，callback返回true时停止遍历
+func (m *baseMap[T, D]) walkSlot(timestamp T, callback func(entry *D) bool) {
+	if timestamp < m.timeRingStartTime {
+		return
+	}
+	slotOffset := int64((timestamp - m.timeRingStartTime) / m.timeInterval)
+	if slotOffset >= int64(m.timeSlots) {
+		return
+	}
+	for index := int(m.timeLists[(int(slotOffset)+m.timeRingStartIndex)%m.timeSlots]); index != _LINK_NIL; {
+		n := m.r.get(index)
+		if exit := callback(&n.entry); exit {
+			return
+		}
+		index = n.timeLink.next
+	}
+}
+
+func (m *baseMap[T, D]) Size() int {
+	return m.entries
+}
+
+// 返回ring和表头占用的内存，哈希链和时间链的表头计入SlotHeads
+func (m *baseMap[T, D]) memoryUsage() hmap.MemoryUsage {
+	usage := hmap.NodeBlocksUsage(m.r.blocks, m.entries)
+	usage.SlotHeads = (len(m.hashLists) + len(m.timeLists)) * strconv.IntSize / 8
+	return usage
+}
+
+func (m *baseMap[T, D]) compressHash(hash int32) int {
+	return int((hash>>m.hashSlotBits)^hash) & (m.hashSlots - 1)
+}
@@ -2,6 +2,10 @@ package timemap
 
 import "fmt"
 
+// Entry的所有权：
+//   - AddOrMerge不会消费传入的Entry，TimeMap中保存的是其Clone()的结果，传入的Entry仍由调用者负责Release
+//   - 时间窗口被输出后（AdvanceTime、Flush、Drain），输出中的Entry所有权转移给调用者，
+//     调用者使用完毕后需调用Release()，ClearOutput不会调用Release()
 type Entry interface {
 	Timestamp() uint32
 	SetTimestamp(timestamp uint32)
@@ -14,3 +18,18 @@ type Entry interface {
 	Release()
 	fmt.Stringer
 }
+
+// Entry64与Entry相同，但Timestamp为int64的Unix纳秒时间戳，用于TimeMap64
+// 时间戳不能为负数，可表示至2262年
+type Entry64 interface {
+	Timestamp() int64
+	SetTimestamp(timestamp int64)
+	// Hash和Eq与timestamp没关系
+	// 换句话说，调用了SetTimestamp或Merge之后，Hash不应该改变
+	Hash() uint64
+	Eq(other Entry64) bool
+	Merge(other Entry64)
+	Clone() Entry64
+	Release()
+	fmt.Stringer
+}
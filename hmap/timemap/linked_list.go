@@ -2,6 +2,7 @@ package timemap
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,10 +14,10 @@ const (
 	_BLOCK_SIZE_MASK = _BLOCK_SIZE - 1
 )
 
-type node struct {
-	hash uint64
-	// 需要保证entry不为nil
-	entry Entry
+// D为节点中保存的数据，TimeMap中为Entry，TimeMap64中为Entry64，Map中为mapEntry
+type node[D any] struct {
+	hash  uint64
+	entry D
 
 	// 在ring buffer中的index
 	index int
@@ -28,24 +29,14 @@ type node struct {
 	timeLink link
 }
 
-func (n *node) Hash() uint64 {
-	if n.hash == 0 {
-		// unlikely to happen after initialize
-		n.hash = n.entry.Hash()
-	}
-	return n.hash
-}
-
-func (n *node) String() string {
-	return fmt.Sprintf("timestamp=%d:entry=%v:id=%d:hashLink=%v:timeLink=%v", n.entry.Timestamp(), n.entry.String(), n.index, n.hashLink, n.timeLink)
+func (n *node[D]) String() string {
+	return fmt.Sprintf("entry=%v:id=%d:hashLink=%v:timeLink=%v", n.entry, n.index, n.hashLink, n.timeLink)
 }
 
-func (n *node) ValueString(key string) string {
+func (n *node[D]) ValueString(key string) string {
 	switch key {
-	case "timestamp":
-		return strconv.FormatUint(uint64(n.entry.Timestamp()), 10)
 	case "entry":
-		return n.entry.String()
+		return fmt.Sprint(n.entry)
 	case "index":
 		return strconv.Itoa(n.index)
 	case "hashLink":
@@ -57,12 +48,22 @@ func (n *node) ValueString(key string) string {
 	}
 }
 
-type nodeBlock []node
+type nodeBlock[D any] []node[D]
 
-var blockPool = sync.Pool{
-	New: func() interface{} {
-		return nodeBlock(make([]node, _BLOCK_SIZE))
-	},
+// 同一节点类型的所有ring共用一个blockPool，按nodeBlock[D]的类型索引
+var blockPools sync.Map // reflect.Type => *sync.Pool
+
+func blockPoolOf[D any]() *sync.Pool {
+	t := reflect.TypeOf(nodeBlock[D](nil))
+	if p, ok := blockPools.Load(t); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := blockPools.LoadOrStore(t, &sync.Pool{
+		New: func() interface{} {
+			return nodeBlock[D](make([]node[D], _BLOCK_SIZE))
+		},
+	})
+	return p.(*sync.Pool)
 }
 
 const (
@@ -76,14 +77,14 @@ type link struct {
 
 type linkedList int
 
-type hashLinkedList linkedList
-type timeLinkedList linkedList
+type hashLinkedList[D any] linkedList
+type timeLinkedList[D any] linkedList
 
-func makeHashLinkedLists(n int) []hashLinkedList {
+func makeHashLinkedLists[D any](n int) []hashLinkedList[D] {
 	if n == 0 {
 		return nil
 	}
-	arr := make([]hashLinkedList, n)
+	arr := make([]hashLinkedList[D], n)
 	arr[0] = _LINK_NIL
 	for seg := 1; seg < n; seg <<= 1 {
 		copy(arr[seg:], arr[:seg])
@@ -91,11 +92,11 @@ func makeHashLinkedLists(n int) []hashLinkedList {
 	return arr
 }
 
-func makeTimeLinkedLists(n int) []timeLinkedList {
+func makeTimeLinkedLists[D any](n int) []timeLinkedList[D] {
 	if n == 0 {
 		return nil
 	}
-	arr := make([]timeLinkedList, n)
+	arr := make([]timeLinkedList[D], n)
 	arr[0] = _LINK_NIL
 	for seg := 1; seg < n; seg <<= 1 {
 		copy(arr[seg:], arr[:seg])
@@ -103,31 +104,31 @@ func makeTimeLinkedLists(n int) []timeLinkedList {
 	return arr
 }
 
-func (l *hashLinkedList) pushFront(r *ring, n *node) {
+func (l *hashLinkedList[D]) pushFront(r *ring[D], n *node[D]) {
 	lnk := &n.hashLink
 	lnk.prev = _LINK_NIL
 	lnk.next = int(*l)
-	*l = hashLinkedList(n.index)
+	*l = hashLinkedList[D](n.index)
 	if lnk.next != _LINK_NIL {
 		r.get(lnk.next).hashLink.prev = n.index
 	}
 }
 
-func (l *timeLinkedList) pushFront(r *ring, n *node) {
+func (l *timeLinkedList[D]) pushFront(r *ring[D], n *node[D]) {
 	lnk := &n.timeLink
 	lnk.prev = _LINK_NIL
 	lnk.next = int(*l)
-	*l = timeLinkedList(n.index)
+	*l = timeLinkedList[D](n.index)
 	if lnk.next != _LINK_NIL {
 		r.get(lnk.next).timeLink.prev = n.index
 	}
 }
 
 // 需要确保node在list中
-func (l *hashLinkedList) remove(r *ring, n *node) {
+func (l *hashLinkedList[D]) remove(r *ring[D], n *node[D]) {
 	lnk := &n.hashLink
 	if lnk.prev == _LINK_NIL {
-		*l = hashLinkedList(lnk.next)
+		*l = hashLinkedList[D](lnk.next)
 	} else {
 		prevNode := r.get(lnk.prev)
 		prevNode.hashLink.next = lnk.next
@@ -139,10 +140,10 @@ func (l *hashLinkedList) remove(r *ring, n *node) {
 }
 
 // 需要确保node在list中
-func (l *timeLinkedList) remove(r *ring, n *node) {
+func (l *timeLinkedList[D]) remove(r *ring[D], n *node[D]) {
 	lnk := &n.timeLink
 	if lnk.prev == _LINK_NIL {
-		*l = timeLinkedList(lnk.next)
+		*l = timeLinkedList[D](lnk.next)
 	} else {
 		prevNode := r.get(lnk.prev)
 		prevNode.timeLink.next = lnk.next
@@ -154,7 +155,7 @@ func (l *timeLinkedList) remove(r *ring, n *node) {
 }
 
 // 调用ring.swapFront返回true后需要调用
-func (l *hashLinkedList) fixLink(r *ring, n *node, swappedIndex int) {
+func (l *hashLinkedList[D]) fixLink(r *ring[D], n *node[D], swappedIndex int) {
 	lnk := &n.hashLink
 	if lnk.prev == n.index {
 		lnk.prev = swappedIndex
@@ -165,7 +166,7 @@ func (l *hashLinkedList) fixLink(r *ring, n *node, swappedIndex int) {
 	if lnk.prev != _LINK_NIL {
 		r.get(lnk.prev).hashLink.next = n.index
 	} else {
-		*l = hashLinkedList(n.index)
+		*l = hashLinkedList[D](n.index)
 	}
 	if lnk.next != _LINK_NIL {
 		r.get(lnk.next).hashLink.prev = n.index
@@ -173,7 +174,7 @@ func (l *hashLinkedList) fixLink(r *ring, n *node, swappedIndex int) {
 }
 
 // 调用ring.swapFront返回true后需要调用
-func (l *timeLinkedList) fixLink(r *ring, n *node, swappedIndex int) {
+func (l *timeLinkedList[D]) fixLink(r *ring[D], n *node[D], swappedIndex int) {
 	lnk := &n.timeLink
 	if lnk.prev == n.index {
 		lnk.prev = swappedIndex
@@ -184,18 +185,19 @@ func (l *timeLinkedList) fixLink(r *ring, n *node, swappedIndex int) {
 	if lnk.prev != _LINK_NIL {
 		r.get(lnk.prev).timeLink.next = n.index
 	} else {
-		*l = timeLinkedList(n.index)
+		*l = timeLinkedList[D](n.index)
 	}
 	if lnk.next != _LINK_NIL {
 		r.get(lnk.next).timeLink.prev = n.index
 	}
 }
 
-func (l *hashLinkedList) find(r *ring, n *node) *node {
+// 返回链中hash相等且match返回true的第一个节点，仅在hash相等时调用match
+func (l *hashLinkedList[D]) find(r *ring[D], hash uint64, match func(entry *D) bool) *node[D] {
 	index := int(*l)
 	for index != _LINK_NIL {
 		queried := r.get(index)
-		if queried.entry.Timestamp() == n.entry.Timestamp() && queried.Hash() == n.Hash() && queried.entry.Eq(n.entry) {
+		if queried.hash == hash && match(&queried.entry) {
 			return queried
 		}
 		index = queried.hashLink.next
@@ -203,11 +205,12 @@ func (l *hashLinkedList) find(r *ring, n *node) *node {
 	return nil
 }
 
-func (l *timeLinkedList) find(r *ring, n *node) *node {
+// 返回链中hash相等且match返回true的第一个节点，仅在hash相等时调用match
+func (l *timeLinkedList[D]) find(r *ring[D], hash uint64, match func(entry *D) bool) *node[D] {
 	index := int(*l)
 	for index != _LINK_NIL {
 		queried := r.get(index)
-		if queried.entry.Timestamp() == n.entry.Timestamp() && queried.Hash() == n.Hash() && queried.entry.Eq(n.entry) {
+		if queried.hash == hash && match(&queried.entry) {
 			return queried
 		}
 		index = queried.timeLink.next
@@ -215,7 +218,7 @@ func (l *timeLinkedList) find(r *ring, n *node) *node {
 	return nil
 }
 
-func (l *hashLinkedList) String(r *ring, key string) string {
+func (l *hashLinkedList[D]) String(r *ring[D], key string) string {
 	var nodes []string
 	nodes = append(nodes, "head")
 	index := int(*l)
@@ -227,7 +230,7 @@ func (l *hashLinkedList) String(r *ring, key string) string {
 	return strings.Join(nodes, " -> ")
 }
 
-func (l *timeLinkedList) String(r *ring, key string) string {
+func (l *timeLinkedList[D]) String(r *ring[D], key string) string {
 	var nodes []string
 	nodes = append(nodes, "head")
 	index := int(*l)
@@ -5,12 +5,12 @@ import (
 )
 
 func TestLinkedListPush(t *testing.T) {
-	r := newRing(_BLOCK_SIZE)
-	hashLinkedList := hashLinkedList(_LINK_NIL)
-	timeLinkedList := timeLinkedList(_LINK_NIL)
+	r := newRing[Entry](_BLOCK_SIZE)
+	hashLinkedList := hashLinkedList[Entry](_LINK_NIL)
+	timeLinkedList := timeLinkedList[Entry](_LINK_NIL)
 	nodes := make([]int, 5)
 	for i := range nodes {
-		n := r.pushBack(newTestEntry(0, i))
+		n := r.pushBack(newTestEntry(0, i), uint64(i))
 		nodes[i] = n.index
 	}
 	hashOrder := []int{1, 3, 4, 2, 0}
@@ -30,12 +30,12 @@ func TestLinkedListPush(t *testing.T) {
 }
 
 func TestLinkedListRemove(t *testing.T) {
-	r := newRing(_BLOCK_SIZE)
-	hashLinkedList := hashLinkedList(_LINK_NIL)
-	timeLinkedList := timeLinkedList(_LINK_NIL)
+	r := newRing[Entry](_BLOCK_SIZE)
+	hashLinkedList := hashLinkedList[Entry](_LINK_NIL)
+	timeLinkedList := timeLinkedList[Entry](_LINK_NIL)
 	nodes := make([]int, 5)
 	for i := range nodes {
-		n := r.pushBack(newTestEntry(0, i))
+		n := r.pushBack(newTestEntry(0, i), uint64(i))
 		nodes[i] = n.index
 	}
 	hashOrder := []int{1, 3, 4, 2, 0}
@@ -72,14 +72,20 @@ func TestLinkedListRemove(t *testing.T) {
 	}
 }
 
+func matchTestEntry(key Entry) func(entry *Entry) bool {
+	return func(entry *Entry) bool {
+		return (*entry).Timestamp() == key.Timestamp() && (*entry).Eq(key)
+	}
+}
+
 func TestLinkedListFind(t *testing.T) {
-	r := newRing(_BLOCK_SIZE)
+	r := newRing[Entry](_BLOCK_SIZE)
 	timestamp := uint32(1234567890)
-	hashLinkedList := hashLinkedList(_LINK_NIL)
-	timeLinkedList := timeLinkedList(_LINK_NIL)
+	hashLinkedList := hashLinkedList[Entry](_LINK_NIL)
+	timeLinkedList := timeLinkedList[Entry](_LINK_NIL)
 	nodes := make([]int, 5)
 	for i := range nodes {
-		n := r.pushBack(newTestEntry(timestamp, i))
+		n := r.pushBack(newTestEntry(timestamp, i), uint64(i))
 		nodes[i] = n.index
 	}
 	hashOrder := []int{1, 3, 4, 2, 0}
@@ -91,16 +97,16 @@ func TestLinkedListFind(t *testing.T) {
 		timeLinkedList.pushFront(r, r.get(i))
 	}
 
-	n1 := hashLinkedList.find(r, &node{entry: newTestEntry(timestamp, 2)})
-	n2 := timeLinkedList.find(r, &node{entry: newTestEntry(timestamp, 2)})
+	n1 := hashLinkedList.find(r, 2, matchTestEntry(newTestEntry(timestamp, 2)))
+	n2 := timeLinkedList.find(r, 2, matchTestEntry(newTestEntry(timestamp, 2)))
 	if n1 == nil || n2 == nil || n1 != n2 {
 		t.Error(n1, n2)
 		t.Error("find()实现不正确")
 	}
-	if n := hashLinkedList.find(r, &node{entry: newTestEntry(timestamp, 1024)}); n != nil {
+	if n := hashLinkedList.find(r, 1024, matchTestEntry(newTestEntry(timestamp, 1024))); n != nil {
 		t.Error("find()实现不正确")
 	}
-	if n := timeLinkedList.find(r, &node{entry: newTestEntry(timestamp+1, 2)}); n != nil {
+	if n := timeLinkedList.find(r, 2, matchTestEntry(newTestEntry(timestamp+1, 2))); n != nil {
 		t.Error("find()实现不正确")
 	}
 }
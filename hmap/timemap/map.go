@@ -0,0 +1,150 @@
+package timemap
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/SophonMesh/go-libs/hmap"
+)
+
+// Map的节点中保存的数据
+type mapEntry[K comparable, V any] struct {
+	timestamp uint32
+	key       K
+	value     V
+}
+
+func (e mapEntry[K, V]) String() string {
+	return fmt.Sprintf("ts=%d:key=%v:value=%v", e.timestamp, e.key, e.value)
+}
+
+// MapItem为Map的输出
+type MapItem[K comparable, V any] struct {
+	Timestamp uint32 // 时间窗口的开始时间
+	Key       K
+	Value     V
+}
+
+// Map 与TimeMap相同，但key和value直接保存在ring的节点中，
+// 不需要实现Entry接口，避免了接口的动态调用以及Clone时的内存分配
+// 注意：不是线程安全的
+type Map[K comparable, V any] struct {
+	baseMap[uint32, mapEntry[K, V]]
+
+	hash  func(key K) uint64
+	merge func(dst *V, src V) // 将src合并至dst
+
+	output []MapItem[K, V]
+}
+
+// hash为key的哈希函数，merge将src合并至dst
+func NewMap[K comparable, V any](id, capacity, hashSlots int, timeInterval uint32, timeSlots int, hash func(key K) uint64, merge func(dst *V, src V)) *Map[K, V] {
+	m := &Map[K, V]{
+		hash:   hash,
+		merge:  merge,
+		output: make([]MapItem[K, V], 0, INIT_OUTPUT_LEN),
+	}
+	m.init(id, capacity, hashSlots, timeInterval, timeSlots, m.flushTimeList)
+	return m
+}
+
+func (m *Map[K, V]) flushTimeList(index int) {
+	nIndex := int(m.timeLists[index])
+	for nIndex != _LINK_NIL {
+		var entry mapEntry[K, V]
+		entry, nIndex = m.removeNode(nIndex)
+		m.output = append(m.output, MapItem[K, V]{Timestamp: entry.timestamp, Key: entry.key, Value: entry.value})
+	}
+	m.timeLists[index] = _LINK_NIL
+}
+
+// Flush 按时间顺序输出所有时间窗口，用于退出前避免丢失尚未输出的数据，返回值与GetOutput相同
+func (m *Map[K, V]) Flush() []MapItem[K, V] {
+	m.flushAll()
+	return m.output
+}
+
+// Drain 按时间顺序输出所有结束时间不晚于until的时间窗口，并将时间窗口的起点推进至until所在的窗口，返回值与GetOutput相同
+func (m *Map[K, V]) Drain(until uint32) []MapItem[K, V] {
+	m.drain(until)
+	return m.output
+}
+
+// 返回key所在的节点，以及key的哈希值和哈希链，timestamp需要已对齐至时间窗口
+func (m *Map[K, V]) lookup(timestamp uint32, key K) (*node[mapEntry[K, V]], uint64, int) {
+	hash := m.hash(key)
+	n, slot := m.find(timestamp, hash, func(e *mapEntry[K, V]) bool {
+		return e.timestamp == timestamp && e.key == key
+	})
+	return n, hash, slot
+}
+
+func (m *Map[K, V]) AddOrMerge(timestamp uint32, key K, value V) error {
+	if timestamp < m.timeRingStartTime {
+		return fmt.Errorf("entry too old, %d < %d", timestamp, m.timeRingStartTime)
+	}
+	timestamp = m.advanceTo(timestamp)
+
+	oldNode, hash, slot := m.lookup(timestamp, key)
+	if oldNode != nil {
+		m.merge(&oldNode.entry.value, value)
+		return nil
+	}
+	if m.entries >= m.capacity {
+		return errors.New("too many entries")
+	}
+	m.insert(timestamp, hash, slot).entry = mapEntry[K, V]{timestamp: timestamp, key: key, value: value}
+	return nil
+}
+
+// 查找timestamp所在时间窗口中key对应的value
+func (m *Map[K, V]) Get(timestamp uint32, key K) (V, bool) {
+	if timestamp >= m.timeRingStartTime {
+		if n, _, _ := m.lookup(m.alignTime(timestamp), key); n != nil {
+			return n.entry.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// 按时间链遍历timestamp所在时间窗口中的所有key和value，callback返回true时停止遍历，callback中不能修改Map
+func (m *Map[K, V]) WalkSlot(timestamp uint32, callback func(key K, value V) bool) {
+	m.walkSlot(timestamp, func(e *mapEntry[K, V]) bool {
+		return callback(e.key, e.value)
+	})
+}
+
+// 删除timestamp所在时间窗口中的key，返回是否删除
+func (m *Map[K, V]) Remove(timestamp uint32, key K) bool {
+	if timestamp < m.timeRingStartTime {
+		return false
+	}
+	n, _, _ := m.lookup(m.alignTime(timestamp), key)
+	if n == nil {
+		return false
+	}
+	m.removeNode(n.index)
+	return true
+}
+
+// 返回占用的内存，哈希链和时间链的表头计入SlotHeads，输出缓冲区计入Others
+func (m *Map[K, V]) MemoryUsage() hmap.MemoryUsage {
+	usage := m.memoryUsage()
+	usage.Others += cap(m.output) * int(unsafe.Sizeof(MapItem[K, V]{}))
+	return usage
+}
+
+func (m *Map[K, V]) GetOutput() []MapItem[K, V] {
+	return m.output
+}
+
+func (m *Map[K, V]) ClearOutput() {
+	var zero MapItem[K, V]
+	for i := range m.output {
+		// 释放value中可能的引用
+		m.output[i] = zero
+	}
+	m.output = m.output[:0]
+}
@@ -0,0 +1,177 @@
+package timemap
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func mergeUint64(dst *uint64, src uint64) {
+	*dst += src
+}
+
+func newTestMap(timeSlots int) *Map[string, uint64] {
+	return NewMap(0, 65536, 1024, 60, timeSlots, hashString, mergeUint64)
+}
+
+func sortMapItems(items []MapItem[string, uint64]) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Timestamp == items[j].Timestamp {
+			return items[i].Key < items[j].Key
+		}
+		return items[i].Timestamp < items[j].Timestamp
+	})
+}
+
+func checkMapItems(a, b []MapItem[string, uint64]) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMapTwoSlots(t *testing.T) {
+	m := newTestMap(2)
+	m.AddOrMerge(65, "alice", 3)
+	m.AddOrMerge(65, "bob", 4)
+	m.AddOrMerge(110, "alice", 7)
+	m.AddOrMerge(121, "alice", 7)
+	if result := m.GetOutput(); len(result) != 0 {
+		t.Fatalf("不应有输出，实际为%v", result)
+	}
+
+	m.AddOrMerge(200, "catherine", 7)
+	expected := []MapItem[string, uint64]{{60, "alice", 10}, {60, "bob", 4}}
+	result := m.GetOutput()
+	sortMapItems(result)
+	if !checkMapItems(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+
+	expected = []MapItem[string, uint64]{{120, "alice", 7}, {180, "catherine", 7}}
+	if result := m.Flush(); !checkMapItems(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestMapQuery(t *testing.T) {
+	m := newTestMap(4)
+	m.AddOrMerge(65, "alice", 3)
+	m.AddOrMerge(70, "alice", 4)
+	m.AddOrMerge(70, "bob", 5)
+	m.AddOrMerge(130, "alice", 6)
+
+	if v, ok := m.Get(100, "alice"); !ok || v != 7 {
+		t.Errorf("Get结果不正确，为%d", v)
+	}
+	if v, ok := m.Get(100, "catherine"); ok {
+		t.Errorf("Get不应查到不存在的key，实际为%d", v)
+	}
+	walked := map[string]uint64{}
+	m.WalkSlot(60, func(key string, value uint64) bool {
+		walked[key] = value
+		return false
+	})
+	if len(walked) != 2 || walked["alice"] != 7 || walked["bob"] != 5 {
+		t.Errorf("WalkSlot结果不正确，为%v", walked)
+	}
+	count := 0
+	m.WalkSlot(60, func(key string, value uint64) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Errorf("WalkSlot应在callback返回true时停止，实际遍历%d个", count)
+	}
+	if !m.Remove(61, "alice") || m.Remove(61, "alice") || m.Size() != 2 {
+		t.Error("Remove结果不正确")
+	}
+	expected := []MapItem[string, uint64]{{60, "bob", 5}}
+	if result := m.Drain(120); !checkMapItems(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestMapRandom(t *testing.T) {
+	for _, seed := range []int64{42, 233, 1024} {
+		rand.Seed(seed)
+		timeSlots := rand.Intn(10) + 1
+		m := NewMap(0, 65536, 16, 60, timeSlots, hashString, mergeUint64)
+		expected := make(map[string]uint64)
+		intervalStart := uint32(120)
+		for i := 0; i < (rand.Intn(3)+2)*timeSlots; i++ {
+			for j := rand.Intn(128); j > 0; j-- {
+				timestamp := intervalStart + uint32(rand.Intn(60*timeSlots))
+				key := fmt.Sprintf("key-%d", rand.Intn(26))
+				kim := fmt.Sprintf("%d-%s", timestamp/60*60, key)
+				if rand.Intn(4) == 0 {
+					if _, in := expected[kim]; m.Remove(timestamp, key) != in {
+						t.Fatalf("测试%d: Remove %s结果不正确", seed, kim)
+					}
+					delete(expected, kim)
+					continue
+				}
+				value := uint64(rand.Intn(128))
+				m.AddOrMerge(timestamp, key, value)
+				expected[kim] += value
+			}
+			intervalStart += 60
+		}
+		result := m.Flush()
+		if len(result) != len(expected) {
+			t.Fatalf("测试%d: 结果数量预期为%d，实际为%d", seed, len(expected), len(result))
+		}
+		for _, item := range result {
+			if v, in := expected[fmt.Sprintf("%d-%s", item.Timestamp, item.Key)]; !in || v != item.Value {
+				t.Errorf("测试%d: 结果%v不正确", seed, item)
+			}
+		}
+	}
+}
+
+const _BENCH_KEYS = 1 << 16
+
+func BenchmarkTimeMapAddOrMerge(b *testing.B) {
+	m := New(0, _BENCH_KEYS*4, _BENCH_KEYS, 1, 2)
+	entry := &TestEntry{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry.timestamp = uint32(i / _BENCH_KEYS)
+		entry.k = uint64(i % _BENCH_KEYS)
+		entry.v = uint64(i)
+		m.AddOrMerge(entry)
+		if len(m.GetOutput()) > INIT_OUTPUT_LEN {
+			m.ClearOutput()
+		}
+	}
+}
+
+func BenchmarkMapAddOrMerge(b *testing.B) {
+	m := NewMap(0, _BENCH_KEYS*4, _BENCH_KEYS, 1, 2,
+		func(key uint64) uint64 { return key },
+		func(dst *uint64, src uint64) { *dst += src },
+	)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.AddOrMerge(uint32(i/_BENCH_KEYS), uint64(i%_BENCH_KEYS), uint64(i))
+		if len(m.GetOutput()) > INIT_OUTPUT_LEN {
+			m.ClearOutput()
+		}
+	}
+}
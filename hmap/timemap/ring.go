@@ -1,78 +1,83 @@
 package timemap
 
-import "strings"
-
-import "fmt"
-
-type ring struct {
-	blocks     []nodeBlock
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type ring[D any] struct {
+	blocks     []nodeBlock[D]
 	startIndex int
 	endIndex   int
 
 	maxIndex int
 	capacity int
+
+	blockPool *sync.Pool
 }
 
-func newRing(capacity int) *ring {
+func newRing[D any](capacity int) *ring[D] {
 	if capacity <= 0 {
 		panic("invalid capacity")
 	}
 	nBlocks := (capacity + _BLOCK_SIZE - 1) / _BLOCK_SIZE
-	return &ring{
-		blocks:   make([]nodeBlock, nBlocks),
-		maxIndex: nBlocks << _BLOCK_SIZE_BITS,
-		capacity: capacity,
+	return &ring[D]{
+		blocks:    make([]nodeBlock[D], nBlocks),
+		maxIndex:  nBlocks << _BLOCK_SIZE_BITS,
+		capacity:  capacity,
+		blockPool: blockPoolOf[D](),
 	}
 }
 
-func (r *ring) incIndex(index int) int {
+func (r *ring[D]) incIndex(index int) int {
 	return (index + 1) % r.maxIndex
 }
 
-func (r *ring) decIndex(index int) int {
+func (r *ring[D]) decIndex(index int) int {
 	return (index + r.maxIndex - 1) % r.maxIndex
 }
 
-func (r *ring) get(index int) *node {
+func (r *ring[D]) get(index int) *node[D] {
 	return &r.blocks[index>>_BLOCK_SIZE_BITS][index&_BLOCK_SIZE_MASK]
 }
 
-func (r *ring) getFront() *node {
+func (r *ring[D]) getFront() *node[D] {
 	return r.get(r.startIndex)
 }
 
-func (r *ring) getNext() *node {
+func (r *ring[D]) getNext() *node[D] {
 	index := r.endIndex
 	r.endIndex = r.incIndex(r.endIndex)
 	row := index >> _BLOCK_SIZE_BITS
 	if r.blocks[row] == nil {
-		r.blocks[row] = blockPool.Get().(nodeBlock)
+		r.blocks[row] = r.blockPool.Get().(nodeBlock[D])
 	}
 	entry := &r.blocks[row][index&_BLOCK_SIZE_MASK]
 	entry.index = index
 	return entry
 }
 
-func (r *ring) pushBack(entry Entry) *node {
+func (r *ring[D]) pushBack(entry D, hash uint64) *node[D] {
 	n := r.getNext()
-	n.hash = entry.Hash()
+	n.hash = hash
 	n.entry = entry
 	return n
 }
 
-func (r *ring) popFront() {
-	*r.get(r.startIndex) = node{}
+func (r *ring[D]) popFront() {
+	*r.get(r.startIndex) = node[D]{}
 	if r.startIndex&_BLOCK_SIZE_MASK == _BLOCK_SIZE_MASK {
 		// put back empty block
 		id := r.startIndex >> _BLOCK_SIZE_BITS
-		blockPool.Put(r.blocks[id])
+		r.blockPool.Put(r.blocks[id])
 		r.blocks[id] = nil
 	}
 	r.startIndex = r.incIndex(r.startIndex)
 }
 
 // returns true if swapped
-func (r *ring) swapFront(index int) bool {
+func (r *ring[D]) swapFront(index int) bool {
 	if index == r.startIndex {
 		return false
 	}
@@ -87,13 +92,13 @@ func (r *ring) swapFront(index int) bool {
 }
 
 // returns true if swapped
-func (r *ring) swapRemove(index int) bool {
+func (r *ring[D]) swapRemove(index int) bool {
 	swapped := r.swapFront(index)
 	r.popFront()
 	return swapped
 }
 
-func (r *ring) String() string {
+func (r *ring[D]) String() string {
 	sb := strings.Builder{}
 	sb.WriteString(fmt.Sprintf("[%d:%d]ring{", r.startIndex, r.endIndex))
 	for i := r.startIndex; i != r.endIndex; i = r.incIndex(i) {
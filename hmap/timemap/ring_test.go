@@ -3,7 +3,7 @@ package timemap
 import "testing"
 
 func TestRingIncDecIndex(t *testing.T) {
-	r := newRing(1)
+	r := newRing[Entry](1)
 
 	initInc, initDec := 5, 123
 	inc, dec := initInc, initDec
@@ -26,13 +26,13 @@ func TestRingIncDecIndex(t *testing.T) {
 }
 
 func TestRingGetRemove(t *testing.T) {
-	r := newRing(_BLOCK_SIZE)
+	r := newRing[Entry](_BLOCK_SIZE)
 
 	lo := 0
 	hi := 0
 	nodes := make([]int, 10)
 	for i := range nodes {
-		n := r.pushBack(newTestEntry(0, i+1))
+		n := r.pushBack(newTestEntry(0, i+1), uint64(i+1))
 		hi = n.index
 		nodes[i] = n.index
 	}
@@ -59,7 +59,7 @@ func TestRingGetRemove(t *testing.T) {
 	}
 
 	// test recycling blocks
-	r = newRing(_BLOCK_SIZE * 2)
+	r = newRing[Entry](_BLOCK_SIZE * 2)
 	for i := 0; i < _BLOCK_SIZE+1; i++ {
 		_ = r.getNext()
 	}
@@ -70,3 +70,13 @@ func TestRingGetRemove(t *testing.T) {
 		t.Error("block没有回收")
 	}
 }
+
+func TestRingSharedBlockPool(t *testing.T) {
+	r1, r2 := newRing[Entry](1), newRing[Entry](_BLOCK_SIZE)
+	if r1.blockPool != r2.blockPool {
+		t.Error("相同节点类型的ring应共用blockPool")
+	}
+	if r3 := newRing[int](1); r3.blockPool == r1.blockPool {
+		t.Error("不同节点类型的ring不应共用blockPool")
+	}
+}
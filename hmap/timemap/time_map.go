@@ -4,152 +4,185 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/SophonMesh/go-libs/hmap/keyhash"
+	"github.com/SophonMesh/go-libs/hmap"
 )
 
 const (
 	INIT_OUTPUT_LEN = 1024
 )
 
-type TimeMap struct {
-	id int
-
-	entries  int
-	capacity int
-
-	hashSlots    int
-	hashSlotBits int
-
-	timeInterval       uint32
-	timeSlots          int
-	timeRingStartIndex int
-	timeRingStartTime  uint32
+// Entry和Entry64的公共方法，E为Entry或Entry64本身
+type timedEntry[T timestamp, E any] interface {
+	Timestamp() T
+	SetTimestamp(timestamp T)
+	Hash() uint64
+	Eq(other E) bool
+	Merge(other E)
+	Clone() E
+	Release()
+	fmt.Stringer
+}
 
-	r *ring
+// TimeMap和TimeMap64的实现，二者仅时间戳的类型不同
+type entryMap[T timestamp, E timedEntry[T, E]] struct {
+	baseMap[T, E]
 
-	hashLists []hashLinkedList
-	timeLists []timeLinkedList
+	output []E
 
-	output []Entry
+	topK *topKSelector[T, E]
 }
 
-func minPowerOfTwo(v int) (int, int) {
-	for i := 0; i < 30; i++ {
-		if p := 1 << i; v <= p {
-			return p, i
-		}
-	}
-	return 1, 0
+func (m *entryMap[T, E]) init(id, capacity, hashSlots int, timeInterval T, timeSlots int) {
+	m.baseMap.init(id, capacity, hashSlots, timeInterval, timeSlots, m.flushTimeList)
+	m.output = make([]E, 0, INIT_OUTPUT_LEN)
 }
 
-func New(id, capacity, hashSlots int, timeInterval uint32, timeSlots int) *TimeMap {
-	hashSlots, hashSlotBits := minPowerOfTwo(hashSlots)
-	if timeInterval == 0 {
-		panic("timeInterval cannot be 0")
+func (m *entryMap[T, E]) flushTimeList(index int) {
+	nIndex := int(m.timeLists[index])
+	for nIndex != _LINK_NIL {
+		var entry E
+		entry, nIndex = m.removeNode(nIndex)
+		if m.topK != nil {
+			m.topK.add(entry)
+		} else {
+			m.output = append(m.output, entry)
+		}
 	}
-	return &TimeMap{
-		id:       id,
-		capacity: capacity,
-
-		hashSlots:    hashSlots,
-		hashSlotBits: hashSlotBits,
-
-		timeInterval: timeInterval,
-		timeSlots:    timeSlots,
-
-		r: newRing(capacity),
-
-		hashLists: makeHashLinkedLists(hashSlots),
-		timeLists: makeTimeLinkedLists(timeSlots),
-
-		output: make([]Entry, 0, INIT_OUTPUT_LEN),
+	if m.topK != nil {
+		m.output = m.topK.flush(m.output)
 	}
+	m.timeLists[index] = _LINK_NIL
 }
 
-func (m *TimeMap) AdvanceTime(timestamp uint32) {
-	if timestamp < m.timeRingStartTime {
+// SetTopK 设置每个时间窗口输出时仅保留最大的k个Entry（按less比较，less(a, b)表示a小于b），从大到小输出，
+// 其余Entry通过Merge合并到others返回的Entry中，在最大的k个Entry之后输出，之后被Release；
+// others的参数为时间窗口的开始时间，others为nil时其余Entry直接Release丢弃
+// k<=0时取消设置，输出所有Entry；k>0时less不能为nil
+func (m *entryMap[T, E]) SetTopK(k int, less func(a, b E) bool, others func(timestamp T) E) {
+	if k <= 0 {
+		m.topK = nil
 		return
 	}
-	advanceSlots := int((timestamp-m.timeRingStartTime)/m.timeInterval) - m.timeSlots + 1
-	if advanceSlots <= 0 {
-		return
+	if less == nil {
+		panic("less must not be nil")
 	}
-	if advanceSlots >= m.timeSlots {
-		for i := range m.timeLists {
-			// 从当前开始flush
-			index := (i + m.timeRingStartIndex) % m.timeSlots
-			m.flushTimeList(index)
-		}
-		m.timeRingStartIndex = 0
-		m.timeRingStartTime = timestamp - uint32(m.timeSlots-1)*m.timeInterval
-		return
-	}
-	for i := 0; i < advanceSlots; i++ {
-		index := (i + m.timeRingStartIndex) % m.timeSlots
-		m.flushTimeList(index)
+	m.topK = &topKSelector[T, E]{
+		k:       k,
+		less:    less,
+		others:  others,
+		entries: make([]E, 0, k),
 	}
-	m.timeRingStartIndex = (advanceSlots + m.timeRingStartIndex) % m.timeSlots
-	m.timeRingStartTime += uint32(advanceSlots) * m.timeInterval
 }
 
-func (m *TimeMap) flushTimeList(index int) {
-	nIndex := int(m.timeLists[index])
-	for nIndex != _LINK_NIL {
-		// 当前节点换到ring中第一个
-		if m.r.swapFront(nIndex) {
-			nodes := []*node{m.r.getFront(), m.r.get(nIndex)}
-			for i, n := range nodes {
-				m.hashLists[n.hashSlot].fixLink(m.r, n, nodes[1-i].index)
-				m.timeLists[n.timeSlot].fixLink(m.r, n, nodes[1-i].index)
-			}
-		}
-		n := m.r.getFront()
-		m.output = append(m.output, n.entry)
-		nIndex = n.timeLink.next
-		m.hashLists[n.hashSlot].remove(m.r, n)
-		m.timeLists[n.timeSlot].remove(m.r, n)
-		m.r.popFront()
-	}
-	m.timeLists[index] = _LINK_NIL
+// Flush 按时间顺序输出所有时间窗口中的Entry，用于退出前避免丢失尚未输出的数据
+// 返回值与GetOutput相同，输出的Entry的Timestamp为其所在时间窗口的开始时间
+func (m *entryMap[T, E]) Flush() []E {
+	m.flushAll()
+	return m.output
+}
+
+// Drain 按时间顺序输出所有结束时间不晚于until的时间窗口，并将时间窗口的起点推进至until所在的窗口，
+// 此后早于该窗口的Entry无法再添加
+// 返回值与GetOutput相同，输出的Entry的Timestamp为其所在时间窗口的开始时间
+func (m *entryMap[T, E]) Drain(until T) []E {
+	m.drain(until)
+	return m.output
 }
 
 // AddOrMerge does not consume entry
-func (m *TimeMap) AddOrMerge(entry Entry) error {
+func (m *entryMap[T, E]) AddOrMerge(entry E) error {
 	timestamp := entry.Timestamp()
 	if timestamp < m.timeRingStartTime {
 		return fmt.Errorf("entry too old, %d < %d", timestamp, m.timeRingStartTime)
 	}
-	timestamp = timestamp / m.timeInterval * m.timeInterval
-	m.AdvanceTime(timestamp)
+	timestamp = m.advanceTo(timestamp)
 
 	entry.SetTimestamp(timestamp)
 	entryHash := entry.Hash()
-	slot := m.compressHash(keyhash.Jenkins128(uint64(timestamp), entryHash))
-	if oldNode := m.hashLists[slot].find(m.r, &node{hash: entryHash, entry: entry}); oldNode != nil {
+	oldNode, slot := m.find(timestamp, entryHash, func(e *E) bool {
+		return (*e).Timestamp() == timestamp && (*e).Eq(entry)
+	})
+	if oldNode != nil {
 		oldNode.entry.Merge(entry)
 		return nil
 	}
 	if m.entries >= m.capacity {
 		return errors.New("too many entries")
 	}
-	newEntry := entry.Clone()
-	node := m.r.pushBack(newEntry)
-	node.hashSlot = slot
-	m.hashLists[slot].pushFront(m.r, node)
-	timeSlot := (int((timestamp-m.timeRingStartTime)/m.timeInterval) + m.timeRingStartIndex) % m.timeSlots
-	node.timeSlot = timeSlot
-	m.timeLists[timeSlot].pushFront(m.r, node)
+	m.insert(timestamp, entryHash, slot).entry = entry.Clone()
 	return nil
 }
 
-func (m *TimeMap) GetOutput() []Entry {
+// 查找timestamp所在时间窗口中与key相等的Entry，key的Timestamp会被修改为时间窗口的开始时间
+// 返回的Entry仍属于TimeMap，调用者不能修改或Release，且在下一次修改TimeMap后失效
+func (m *entryMap[T, E]) Get(timestamp T, key E) (E, bool) {
+	if n := m.lookup(timestamp, key); n != nil {
+		return n.entry, true
+	}
+	var zero E
+	return zero, false
+}
+
+// 按时间链遍历timestamp所在时间窗口中的所有Entry，callback返回true时停止遍历
+// 用于在时间窗口输出前查看部分聚合的结果，callback中不能修改TimeMap
+func (m *entryMap[T, E]) WalkSlot(timestamp T, callback func(entry E) bool) {
+	m.walkSlot(timestamp, func(e *E) bool {
+		return callback(*e)
+	})
+}
+
+// 删除与entry时间窗口相同且相等的Entry，被删除的Entry会被Release，返回是否删除
+// 与AddOrMerge相同，entry的Timestamp会被修改为时间窗口的开始时间
+func (m *entryMap[T, E]) Remove(entry E) bool {
+	n := m.lookup(entry.Timestamp(), entry)
+	if n == nil {
+		return false
+	}
+	removed, _ := m.removeNode(n.index)
+	removed.Release()
+	return true
+}
+
+func (m *entryMap[T, E]) lookup(timestamp T, key E) *node[E] {
+	if timestamp < m.timeRingStartTime {
+		return nil
+	}
+	timestamp = m.alignTime(timestamp)
+	key.SetTimestamp(timestamp)
+	n, _ := m.find(timestamp, key.Hash(), func(e *E) bool {
+		return (*e).Timestamp() == timestamp && (*e).Eq(key)
+	})
+	return n
+}
+
+// 返回占用的内存，哈希链和时间链的表头计入SlotHeads，不包括Entry指向的内存
+func (m *entryMap[T, E]) MemoryUsage() hmap.MemoryUsage {
+	usage := m.memoryUsage()
+	usage.Others += cap(m.output) * hmap.INTERFACE_SIZE
+	if m.topK != nil {
+		usage.Others += cap(m.topK.entries) * hmap.INTERFACE_SIZE
+	}
+	return usage
+}
+
+// 输出中的Entry所有权属于调用者，使用完毕后需调用Release
+func (m *entryMap[T, E]) GetOutput() []E {
 	return m.output
 }
 
-func (m *TimeMap) ClearOutput() {
+// 仅清空输出，不会调用Entry的Release
+func (m *entryMap[T, E]) ClearOutput() {
 	m.output = m.output[:0]
 }
 
-func (m *TimeMap) compressHash(hash int32) int {
-	return int((hash>>m.hashSlotBits)^hash) & (m.hashSlots - 1)
+// TimeMap 按时间窗口聚合Entry，时间戳为uint32的Unix秒
+// 注意：不是线程安全的
+type TimeMap struct {
+	entryMap[uint32, Entry]
+}
+
+func New(id, capacity, hashSlots int, timeInterval uint32, timeSlots int) *TimeMap {
+	m := &TimeMap{}
+	m.init(id, capacity, hashSlots, timeInterval, timeSlots)
+	return m
 }
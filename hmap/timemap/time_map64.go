@@ -0,0 +1,14 @@
+package timemap
+
+import "time"
+
+// TimeMap64与TimeMap相同，但使用int64的Unix纳秒时间戳，支持小于1秒的时间窗口，且不会在2106年溢出
+type TimeMap64 struct {
+	entryMap[int64, Entry64]
+}
+
+func New64(id, capacity, hashSlots int, timeInterval time.Duration, timeSlots int) *TimeMap64 {
+	m := &TimeMap64{}
+	m.init(id, capacity, hashSlots, int64(timeInterval), timeSlots)
+	return m
+}
@@ -0,0 +1,254 @@
+package timemap
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTimeMap64SubSecond(t *testing.T) {
+	interval := 100 * time.Millisecond
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	m := New64(0, 65536, 1024, interval, 2)
+	m.AddOrMerge(newTestDocument64(base+10*int64(time.Millisecond), "alice", 3))
+	m.AddOrMerge(newTestDocument64(base+99*int64(time.Millisecond), "alice", 4))
+	// 恰好在窗口边界，属于下一个窗口
+	m.AddOrMerge(newTestDocument64(base+100*int64(time.Millisecond), "alice", 5))
+	m.AddOrMerge(newTestDocument64(base+150*int64(time.Millisecond), "bob", 6))
+	if len(m.GetOutput()) != 0 {
+		t.Fatalf("不应有输出，实际为%v", m.GetOutput())
+	}
+
+	m.AddOrMerge(newTestDocument64(base+250*int64(time.Millisecond), "catherine", 7))
+	expected := []Entry64{newTestDocument64(base, "alice", 7)}
+	if result := m.GetOutput(); !checkEq64(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+
+	expected = []Entry64{
+		newTestDocument64(base+100*int64(time.Millisecond), "alice", 5),
+		newTestDocument64(base+100*int64(time.Millisecond), "bob", 6),
+		newTestDocument64(base+200*int64(time.Millisecond), "catherine", 7),
+	}
+	result := m.Flush()
+	sortEntries64(result)
+	if !checkEq64(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestTimeMap64Beyond2106(t *testing.T) {
+	// uint32秒在2106年溢出
+	base := time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	if base/int64(time.Second) <= 1<<32 {
+		t.Fatal("测试时间应超过uint32秒的范围")
+	}
+	m := New64(0, 65536, 1024, time.Minute, 1)
+	m.AddOrMerge(newTestDocument64(base+int64(5*time.Second), "alice", 3))
+	m.AddOrMerge(newTestDocument64(base+int64(50*time.Second), "alice", 4))
+	m.AddOrMerge(newTestDocument64(base+int64(61*time.Second), "alice", 5))
+	expected := []Entry64{newTestDocument64(base, "alice", 7)}
+	if result := m.GetOutput(); !checkEq64(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+	expected = []Entry64{newTestDocument64(base+int64(time.Minute), "alice", 5)}
+	if result := m.Flush(); !checkEq64(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestTimeMap64AdvanceTime(t *testing.T) {
+	interval := 10 * time.Millisecond
+	m := New64(0, 65536, 1024, interval, 3)
+	m.AddOrMerge(newTestDocument64(int64(15*time.Millisecond), "alice", 1))
+	m.AddOrMerge(newTestDocument64(int64(25*time.Millisecond), "bob", 2))
+
+	// 时间窗口为[0, 30ms)，推进到未对齐的49ms输出[0, 20ms)
+	m.AdvanceTime(int64(49 * time.Millisecond))
+	expected := []Entry64{newTestDocument64(int64(10*time.Millisecond), "alice", 1)}
+	if result := m.GetOutput(); !checkEq64(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+
+	// 跳过多于timeSlots个窗口，起点应对齐
+	m.AdvanceTime(int64(time.Hour + 5*time.Millisecond))
+	expected = []Entry64{newTestDocument64(int64(20*time.Millisecond), "bob", 2)}
+	if result := m.GetOutput(); !checkEq64(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+	if err := m.AddOrMerge(newTestDocument64(int64(time.Hour-20*time.Millisecond-1), "alice", 1)); err == nil {
+		t.Error("不应能添加早于时间窗口的Entry64")
+	}
+	if err := m.AddOrMerge(newTestDocument64(int64(time.Hour-20*time.Millisecond), "alice", 1)); err != nil {
+		t.Errorf("应能添加时间窗口起点的Entry64: %s", err)
+	}
+	if err := m.AddOrMerge(newTestDocument64(-1, "alice", 1)); err == nil {
+		t.Error("不应能添加负数时间戳的Entry64")
+	}
+}
+
+func TestTimeMap64Query(t *testing.T) {
+	interval := 100 * time.Millisecond
+	m := New64(0, 65536, 1024, interval, 4)
+	m.AddOrMerge(newTestDocument64(int64(110*time.Millisecond), "alice", 3))
+	m.AddOrMerge(newTestDocument64(int64(190*time.Millisecond), "alice", 4))
+	m.AddOrMerge(newTestDocument64(int64(210*time.Millisecond), "bob", 5))
+
+	if e, ok := m.Get(int64(150*time.Millisecond), newTestDocument64(0, "alice", 0)); !ok || !checkEq64([]Entry64{e}, []Entry64{newTestDocument64(int64(100*time.Millisecond), "alice", 7)}) {
+		t.Errorf("Get结果不正确，为%v", e)
+	}
+	count := 0
+	m.WalkSlot(int64(200*time.Millisecond), func(e Entry64) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("WalkSlot应遍历1个Entry64，实际为%d", count)
+	}
+	if !m.Remove(newTestDocument64(int64(250*time.Millisecond), "bob", 0)) || m.Size() != 1 {
+		t.Error("Remove结果不正确")
+	}
+	expected := []Entry64{newTestDocument64(int64(100*time.Millisecond), "alice", 7)}
+	if result := m.Drain(int64(300 * time.Millisecond)); !checkEq64(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestTimeMap64Random(t *testing.T) {
+	seeds := []int64{42, 233, 1024}
+	for _, s := range seeds {
+		if err := randomTimeMap64Tester(s); err != nil {
+			t.Errorf("测试%d: %s", s, err)
+		}
+	}
+}
+
+func randomTimeMap64Tester(seed int64) error {
+	rand.Seed(seed)
+	interval := int64(250 * time.Millisecond)
+	timeSlots := rand.Intn(10) + 1
+	testIntervals := (rand.Intn(3) + 2) * timeSlots
+	keys := []string{
+		"alice", "bob", "catherine", "david", "eleven", "fox", "george",
+		"hilton", "ivy", "jade", "kevin", "lyn", "may", "ninja", "oliver",
+	}
+	expected := []Entry64{}
+	expectedIndex := make(map[string]int)
+	intervalStart := time.Date(2150, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	m := New64(0, 65536, 16, time.Duration(interval), timeSlots)
+	for i := 0; i < testIntervals; i++ {
+		nEntries := rand.Intn(128)
+		for j := 0; j < nEntries; j++ {
+			timestamp := intervalStart + rand.Int63n(interval*int64(timeSlots))
+			key := keys[rand.Intn(len(keys))]
+			value := uint64(rand.Intn(128))
+			m.AddOrMerge(newTestDocument64(timestamp, key, value))
+			timestamp = timestamp / interval * interval
+			expectedEntry := newTestDocument64(timestamp, key, value)
+			kim := fmt.Sprintf("%d-%s", timestamp, key)
+			if id, in := expectedIndex[kim]; in {
+				expected[id].Merge(expectedEntry)
+			} else {
+				expectedIndex[kim] = len(expected)
+				expected = append(expected, expectedEntry)
+			}
+		}
+		intervalStart += interval
+	}
+	m.AdvanceTime(intervalStart + int64(timeSlots)*2*interval)
+	result := m.GetOutput()
+	sortEntries64(expected)
+	sortEntries64(result)
+	if !checkEq64(expected, result) {
+		return errors.New("结果不匹配")
+	}
+	return nil
+}
+
+func sortEntries64(es []Entry64) {
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].Timestamp() == es[j].Timestamp() {
+			return es[i].(*TestDocument64).key < es[j].(*TestDocument64).key
+		}
+		return es[i].Timestamp() < es[j].Timestamp()
+	})
+}
+
+func checkEq64(a, b []Entry64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		da := a[i].(*TestDocument64)
+		db := b[i].(*TestDocument64)
+		if da.timestamp != db.timestamp || da.key != db.key || da.value != db.value {
+			return false
+		}
+	}
+	return true
+}
+
+type TestDocument64 struct {
+	timestamp int64
+	hash      uint64
+	key       string
+	value     uint64
+}
+
+func newTestDocument64(timestamp int64, key string, value uint64) Entry64 {
+	return &TestDocument64{
+		timestamp: timestamp,
+		key:       key,
+		value:     value,
+	}
+}
+
+func (d *TestDocument64) Timestamp() int64 {
+	return d.timestamp
+}
+
+func (d *TestDocument64) SetTimestamp(timestamp int64) {
+	d.timestamp = timestamp
+}
+
+func (d *TestDocument64) Hash() uint64 {
+	if d.hash == 0 {
+		h := fnv.New64a()
+		h.Write([]byte(d.key))
+		d.hash = h.Sum64()
+	}
+	return d.hash
+}
+
+func (d *TestDocument64) Eq(other Entry64) bool {
+	if o, ok := other.(*TestDocument64); ok {
+		return d.key == o.key
+	}
+	return false
+}
+
+func (d *TestDocument64) Merge(other Entry64) {
+	if o, ok := other.(*TestDocument64); ok {
+		d.value += o.value
+	}
+}
+
+func (d *TestDocument64) Clone() Entry64 {
+	newEntry := *d
+	return &newEntry
+}
+
+func (d *TestDocument64) Release() {
+}
+
+func (d *TestDocument64) String() string {
+	return fmt.Sprintf("ts=%d:hash=%x:key=%s:value=%d", d.timestamp, d.Hash(), d.key, d.value)
+}
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"sort"
 	"testing"
+	"unsafe"
 )
 
 func TestTimeMapSingleSlot(t *testing.T) {
@@ -59,6 +60,342 @@ func TestTimeMapTwoSlots(t *testing.T) {
 	}
 }
 
+func TestTimeMapFlush(t *testing.T) {
+	m := New(0, 65536, 1024, 60, 3)
+	m.AddOrMerge(newTestDocument(65, "alice", 3))
+	m.AddOrMerge(newTestDocument(130, "bob", 4))
+	m.AddOrMerge(newTestDocument(190, "alice", 5))
+	m.AddOrMerge(newTestDocument(70, "alice", 7))
+	if len(m.GetOutput()) != 0 {
+		t.Fatalf("Flush前不应有输出，实际为%v", m.GetOutput())
+	}
+
+	// 按时间顺序输出
+	expected := []Entry{newTestDocument(60, "alice", 10), newTestDocument(120, "bob", 4), newTestDocument(180, "alice", 5)}
+	result := m.Flush()
+	if !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	if m.Size() != 0 {
+		t.Errorf("Flush后size应为0，实际为%d", m.Size())
+	}
+	m.ClearOutput()
+
+	// Flush后可以继续添加
+	m.AddOrMerge(newTestDocument(200, "catherine", 1))
+	expected = []Entry{newTestDocument(180, "catherine", 1)}
+	if result := m.Flush(); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestTimeMapDrain(t *testing.T) {
+	m := New(0, 65536, 1024, 60, 4)
+	m.AddOrMerge(newTestDocument(65, "alice", 3))
+	m.AddOrMerge(newTestDocument(130, "bob", 4))
+	m.AddOrMerge(newTestDocument(190, "catherine", 5))
+	m.AddOrMerge(newTestDocument(250, "david", 6))
+
+	// [120, 180)未结束，不输出
+	expected := []Entry{newTestDocument(60, "alice", 3)}
+	if result := m.Drain(179); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+	if err := m.AddOrMerge(newTestDocument(119, "alice", 1)); err == nil {
+		t.Error("Drain后不应能添加已输出时间窗口的Entry")
+	}
+	m.AddOrMerge(newTestDocument(179, "bob", 1))
+
+	expected = []Entry{newTestDocument(120, "bob", 5), newTestDocument(180, "catherine", 5)}
+	if result := m.Drain(240); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+
+	// until超过所有时间窗口
+	m.AddOrMerge(newTestDocument(300, "eleven", 7))
+	expected = []Entry{newTestDocument(240, "david", 6), newTestDocument(300, "eleven", 7)}
+	if result := m.Drain(10000); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+	if m.Size() != 0 {
+		t.Errorf("Drain后size应为0，实际为%d", m.Size())
+	}
+	m.AddOrMerge(newTestDocument(10030, "fox", 8))
+	expected = []Entry{newTestDocument(10020, "fox", 8)}
+	if result := m.Flush(); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestTimeMapDrainRelease(t *testing.T) {
+	released := 0
+	m := New(0, 65536, 1024, 60, 2)
+	inputs := []Entry{
+		newTestDocumentWithRelease(65, "alice", 3, &released),
+		newTestDocumentWithRelease(70, "alice", 4, &released),
+		newTestDocumentWithRelease(130, "bob", 5, &released),
+	}
+	for _, e := range inputs {
+		m.AddOrMerge(e)
+	}
+	if released != 0 {
+		t.Fatalf("AddOrMerge不应Release传入的Entry，实际Release %d次", released)
+	}
+
+	// 修改传入的Entry不影响TimeMap中的Entry
+	inputs[0].(*TestDocument).value = 100
+	result := m.Drain(200)
+	expected := []Entry{newTestDocument(60, "alice", 7), newTestDocument(120, "bob", 5)}
+	if !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	for _, e := range inputs {
+		for _, o := range result {
+			if e == o {
+				t.Fatal("输出的Entry不应为传入的Entry")
+			}
+		}
+	}
+	m.ClearOutput()
+	if released != 0 {
+		t.Fatalf("Drain和ClearOutput不应Release输出的Entry，实际Release %d次", released)
+	}
+
+	// 输出的Entry由调用者Release
+	for _, e := range result {
+		e.Release()
+	}
+	if released != len(expected) {
+		t.Errorf("Release次数预期为%d，实际为%d", len(expected), released)
+	}
+}
+
+func TestTimeMapGet(t *testing.T) {
+	m := New(0, 65536, 1024, 60, 2)
+	m.AddOrMerge(newTestDocument(65, "alice", 3))
+	m.AddOrMerge(newTestDocument(70, "alice", 4))
+	m.AddOrMerge(newTestDocument(130, "alice", 5))
+
+	if e, ok := m.Get(100, newTestDocument(0, "alice", 0)); !ok || !checkEq([]Entry{e}, []Entry{newTestDocument(60, "alice", 7)}) {
+		t.Errorf("Get结果不正确，为%v", e)
+	}
+	if e, ok := m.Get(179, newTestDocument(0, "alice", 0)); !ok || !checkEq([]Entry{e}, []Entry{newTestDocument(120, "alice", 5)}) {
+		t.Errorf("Get结果不正确，为%v", e)
+	}
+	if e, ok := m.Get(100, newTestDocument(0, "bob", 0)); ok {
+		t.Errorf("Get不应查到不存在的key，实际为%v", e)
+	}
+	if e, ok := m.Get(180, newTestDocument(0, "alice", 0)); ok {
+		t.Errorf("Get不应查到不存在的时间窗口，实际为%v", e)
+	}
+	if e, ok := m.Get(59, newTestDocument(0, "alice", 0)); ok {
+		t.Errorf("Get不应查到已输出的时间窗口，实际为%v", e)
+	}
+}
+
+func TestTimeMapWalkSlot(t *testing.T) {
+	m := New(0, 65536, 1024, 60, 2)
+	m.AddOrMerge(newTestDocument(65, "alice", 3))
+	m.AddOrMerge(newTestDocument(70, "bob", 4))
+	m.AddOrMerge(newTestDocument(80, "alice", 4))
+	m.AddOrMerge(newTestDocument(130, "catherine", 5))
+
+	result := []Entry{}
+	m.WalkSlot(100, func(e Entry) bool {
+		result = append(result, e)
+		return false
+	})
+	expected := []Entry{newTestDocument(60, "alice", 7), newTestDocument(60, "bob", 4)}
+	sortEntries(result)
+	if !checkEq(result, expected) {
+		t.Errorf("结果预期为%v，实际为%v", expected, result)
+	}
+
+	count := 0
+	m.WalkSlot(60, func(e Entry) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Errorf("callback返回true后应停止遍历，实际遍历%d次", count)
+	}
+
+	for _, ts := range []uint32{0, 180, 1000} {
+		m.WalkSlot(ts, func(e Entry) bool {
+			t.Errorf("时间%d不应遍历到%v", ts, e)
+			return false
+		})
+	}
+}
+
+func TestTimeMapRemove(t *testing.T) {
+	released := 0
+	m := New(0, 65536, 1024, 60, 2)
+	m.AddOrMerge(newTestDocumentWithRelease(65, "alice", 3, &released))
+	m.AddOrMerge(newTestDocumentWithRelease(70, "bob", 4, &released))
+	m.AddOrMerge(newTestDocumentWithRelease(130, "alice", 5, &released))
+
+	if !m.Remove(newTestDocument(100, "alice", 0)) {
+		t.Error("Remove应删除存在的Entry")
+	}
+	if released != 1 {
+		t.Errorf("Remove应Release被删除的Entry，实际Release %d次", released)
+	}
+	if m.Remove(newTestDocument(100, "alice", 0)) {
+		t.Error("Remove不应删除不存在的Entry")
+	}
+	if m.Size() != 2 {
+		t.Errorf("size预期为2，实际为%d", m.Size())
+	}
+	expected := []Entry{newTestDocument(60, "bob", 4), newTestDocument(120, "alice", 5)}
+	if result := m.Flush(); !checkEq(result, expected) {
+		t.Errorf("结果预期为%v，实际为%v", expected, result)
+	}
+}
+
+func TestTimeMapRemoveRandom(t *testing.T) {
+	rand.Seed(42)
+	keys := []string{"alice", "bob", "catherine", "david", "eleven", "fox", "george", "hilton"}
+	m := New(0, 65536, 4, 60, 4)
+	expected := make(map[string]uint64)
+	for i := 0; i < 1024; i++ {
+		timestamp := uint32(120 + rand.Intn(240))
+		key := keys[rand.Intn(len(keys))]
+		window := timestamp / 60 * 60
+		kim := fmt.Sprintf("%d-%s", window, key)
+		if rand.Intn(3) == 0 {
+			if _, in := expected[kim]; m.Remove(newTestDocument(timestamp, key, 0)) != in {
+				t.Fatalf("Remove %s结果不正确", kim)
+			}
+			delete(expected, kim)
+			continue
+		}
+		value := uint64(rand.Intn(128))
+		m.AddOrMerge(newTestDocument(timestamp, key, value))
+		expected[kim] += value
+	}
+	if m.Size() != len(expected) {
+		t.Fatalf("size预期为%d，实际为%d", len(expected), m.Size())
+	}
+	result := m.Flush()
+	if len(result) != len(expected) {
+		t.Fatalf("结果数量预期为%d，实际为%d", len(expected), len(result))
+	}
+	for _, e := range result {
+		d := e.(*TestDocument)
+		if v, in := expected[fmt.Sprintf("%d-%s", d.timestamp, d.key)]; !in || v != d.value {
+			t.Errorf("结果%v不正确", d)
+		}
+	}
+}
+
+func lessTestDocument(a, b Entry) bool {
+	return a.(*TestDocument).value < b.(*TestDocument).value
+}
+
+func TestTimeMapTopK(t *testing.T) {
+	released := 0
+	m := New(0, 65536, 1024, 60, 1)
+	m.SetTopK(2, lessTestDocument, func(timestamp uint32) Entry {
+		return newTestDocument(timestamp, "others", 0)
+	})
+	for i, key := range []string{"alice", "bob", "catherine", "david", "eleven"} {
+		m.AddOrMerge(newTestDocumentWithRelease(65, key, uint64((i*3)%5+1), &released))
+	}
+	m.AddOrMerge(newTestDocumentWithRelease(70, "bob", 10, &released))
+	m.AddOrMerge(newTestDocumentWithRelease(125, "alice", 1, &released))
+
+	// bob=14, catherine=2, alice=1, david=5, eleven=3
+	expected := []Entry{newTestDocument(60, "bob", 14), newTestDocument(60, "david", 5), newTestDocument(60, "others", 6)}
+	if result := m.GetOutput(); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	if released != 3 {
+		t.Errorf("合并到others的Entry应被Release，实际Release %d次", released)
+	}
+	m.ClearOutput()
+
+	// 不足k个时没有others
+	expected = []Entry{newTestDocument(120, "alice", 1)}
+	if result := m.Flush(); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	m.ClearOutput()
+
+	// 没有others时直接丢弃
+	released = 0
+	m.SetTopK(1, lessTestDocument, nil)
+	m.AddOrMerge(newTestDocumentWithRelease(200, "alice", 1, &released))
+	m.AddOrMerge(newTestDocumentWithRelease(200, "bob", 2, &released))
+	expected = []Entry{newTestDocument(180, "bob", 2)}
+	if result := m.Flush(); !checkEq(result, expected) {
+		t.Fatalf("结果预期为%v，实际为%v", expected, result)
+	}
+	if released != 1 {
+		t.Errorf("丢弃的Entry应被Release，实际Release %d次", released)
+	}
+	m.ClearOutput()
+
+	// 取消设置
+	m.SetTopK(0, nil, nil)
+	m.AddOrMerge(newTestDocument(260, "alice", 1))
+	m.AddOrMerge(newTestDocument(260, "bob", 2))
+	if result := m.Flush(); len(result) != 2 {
+		t.Fatalf("取消TopK后应输出所有Entry，实际为%v", result)
+	}
+
+	// k>0时less为nil应立即panic
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("SetTopK的less为nil时应panic")
+			}
+		}()
+		m.SetTopK(1, nil, nil)
+	}()
+}
+
+func TestTimeMapTopKRandom(t *testing.T) {
+	rand.Seed(233)
+	k := 5
+	m := New(0, 65536, 1024, 60, 4)
+	m.SetTopK(k, lessTestDocument, func(timestamp uint32) Entry {
+		return newTestDocument(timestamp, "others", 0)
+	})
+	sums := make(map[uint32]uint64)
+	for i := 0; i < 4096; i++ {
+		timestamp := uint32(rand.Intn(240))
+		value := uint64(rand.Intn(1000))
+		m.AddOrMerge(newTestDocument(timestamp, fmt.Sprintf("key-%d", rand.Intn(64)), value))
+		sums[timestamp/60*60] += value
+	}
+	result := m.Flush()
+	windowSums := make(map[uint32]uint64)
+	windowCounts := make(map[uint32]int)
+	for i, e := range result {
+		d := e.(*TestDocument)
+		windowSums[d.timestamp] += d.value
+		if d.key == "others" {
+			continue
+		}
+		windowCounts[d.timestamp]++
+		if next := i + 1; next < len(result) && result[next].Timestamp() == d.timestamp && result[next].(*TestDocument).key != "others" && lessTestDocument(e, result[next]) {
+			t.Errorf("结果应从大到小输出: %v < %v", e, result[next])
+		}
+	}
+	for ts, sum := range sums {
+		if windowSums[ts] != sum {
+			t.Errorf("时间窗口%d的总和预期为%d，实际为%d", ts, sum, windowSums[ts])
+		}
+		if windowCounts[ts] != k {
+			t.Errorf("时间窗口%d应输出%d个Entry，实际为%d", ts, k, windowCounts[ts])
+		}
+	}
+}
+
 func TestTimeMapRandom(t *testing.T) {
 	seeds := []int64{42, 233, 1024}
 	for _, s := range seeds {
@@ -141,6 +478,8 @@ type TestDocument struct {
 	hash      uint64
 	key       string
 	value     uint64
+
+	released *int
 }
 
 func newTestDocument(timestamp uint32, key string, value uint64) Entry {
@@ -151,6 +490,15 @@ func newTestDocument(timestamp uint32, key string, value uint64) Entry {
 	}
 }
 
+func newTestDocumentWithRelease(timestamp uint32, key string, value uint64, released *int) Entry {
+	return &TestDocument{
+		timestamp: timestamp,
+		key:       key,
+		value:     value,
+		released:  released,
+	}
+}
+
 func (d *TestDocument) Timestamp() uint32 {
 	return d.timestamp
 }
@@ -187,8 +535,36 @@ func (e *TestDocument) Clone() Entry {
 }
 
 func (d *TestDocument) Release() {
+	if d.released != nil {
+		*d.released++
+	}
 }
 
 func (d *TestDocument) String() string {
 	return fmt.Sprintf("ts=%d:hash=%x:key=%s:value=%d", d.timestamp, d.Hash(), d.key, d.value)
 }
+
+func TestTimeMapMemoryUsage(t *testing.T) {
+	m := New(0, 1024, 1024, 60, 2)
+	nodeSize := int(unsafe.Sizeof(node[Entry]{}))
+
+	for i := 0; i < 300; i++ {
+		m.AddOrMerge(newTestDocument(65, fmt.Sprint(i), 1))
+	}
+	usage := m.MemoryUsage()
+	if usage.BlocksInUse != 300*nodeSize || usage.BlocksReserved != (2*_BLOCK_SIZE-300)*nodeSize {
+		t.Errorf("节点块结果预期为%d/%d，实际为%d/%d",
+			300*nodeSize, (2*_BLOCK_SIZE-300)*nodeSize, usage.BlocksInUse, usage.BlocksReserved)
+	}
+	expected := (len(m.hashLists) + len(m.timeLists)) * int(unsafe.Sizeof(hashLinkedList[Entry](0)))
+	if usage.SlotHeads != expected {
+		t.Errorf("链表头结果预期为%d，实际为%d", expected, usage.SlotHeads)
+	}
+
+	m.Flush()
+	m.ClearOutput()
+	usage = m.MemoryUsage()
+	if usage.BlocksInUse != 0 {
+		t.Errorf("Flush后有效节点预期为0，实际为%v", usage)
+	}
+}
@@ -0,0 +1,66 @@
+package timemap
+
+import "container/heap"
+
+// 时间窗口输出时选出最大的k个Entry，其余Entry合并到others中
+type topKSelector[T timestamp, E timedEntry[T, E]] struct {
+	k      int
+	less   func(a, b E) bool
+	others func(timestamp T) E
+
+	entries    []E // 小顶堆，堆顶为当前第k大的Entry
+	otherEntry E
+	hasOther   bool // otherEntry是否有效
+}
+
+func (s *topKSelector[T, E]) Len() int           { return len(s.entries) }
+func (s *topKSelector[T, E]) Less(i, j int) bool { return s.less(s.entries[i], s.entries[j]) }
+func (s *topKSelector[T, E]) Swap(i, j int)      { s.entries[i], s.entries[j] = s.entries[j], s.entries[i] }
+func (s *topKSelector[T, E]) Push(x interface{}) { s.entries = append(s.entries, x.(E)) }
+func (s *topKSelector[T, E]) Pop() interface{} {
+	n := len(s.entries)
+	x := s.entries[n-1]
+	var zero E
+	s.entries[n-1] = zero
+	s.entries = s.entries[:n-1]
+	return x
+}
+
+func (s *topKSelector[T, E]) add(entry E) {
+	if len(s.entries) < s.k {
+		heap.Push(s, entry)
+		return
+	}
+	if s.less(s.entries[0], entry) {
+		entry, s.entries[0] = s.entries[0], entry
+		heap.Fix(s, 0)
+	}
+	s.drop(entry)
+}
+
+func (s *topKSelector[T, E]) drop(entry E) {
+	if s.others != nil {
+		if !s.hasOther {
+			s.otherEntry, s.hasOther = s.others(entry.Timestamp()), true
+		}
+		s.otherEntry.Merge(entry)
+	}
+	entry.Release()
+}
+
+// 将选出的Entry按从大到小的顺序追加到output中，others在最后
+func (s *topKSelector[T, E]) flush(output []E) []E {
+	start := len(output)
+	for s.Len() > 0 {
+		output = append(output, heap.Pop(s).(E))
+	}
+	for i, j := start, len(output)-1; i < j; i, j = i+1, j-1 {
+		output[i], output[j] = output[j], output[i]
+	}
+	if s.hasOther {
+		var zero E
+		output = append(output, s.otherEntry)
+		s.otherEntry, s.hasOther = zero, false
+	}
+	return output
+}
@@ -0,0 +1,262 @@
+package timer
+
+import (
+	"time"
+
+	"github.com/SophonMesh/go-libs/hmap/heap"
+)
+
+const (
+	_WHEEL_BITS   = 8
+	_WHEEL_SLOTS  = 1 << _WHEEL_BITS
+	_WHEEL_MASK   = _WHEEL_SLOTS - 1
+	_WHEEL_LEVELS = 4 // 最大时间范围为 tick * 2^32
+
+	_BLOCK_SIZE_BITS = 8
+	_BLOCK_SIZE      = 1 << _BLOCK_SIZE_BITS
+	_BLOCK_SIZE_MASK = _BLOCK_SIZE - 1
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var SystemClock Clock = systemClock{}
+
+type timerNode struct {
+	value    interface{}
+	fn       func()
+	deadline int64 // 到期的tick
+
+	level  int         // 所在的时间轮层级，-1 表示不在时间轮中
+	handle heap.Handle // 在所在层级BucketHeap中的句柄
+
+	index      int32
+	next       int32  // 空闲链表的下一个节点
+	generation uint32 // 节点每次被释放时加一，用于判断Handle是否失效
+}
+
+// Schedule返回的句柄，用于Cancel和Reschedule，定时器到期或被取消后失效
+type Handle struct {
+	index      int32
+	generation uint32
+}
+
+// 注意：不是线程安全的
+// 分层时间轮，每层有256个槽，第L层的每个槽对应 256^L 个tick，
+// 到期时间较远的定时器位于高层，随着时间推进逐层下放到低层，直到在第0层到期。
+// 每层的槽使用BucketHeap的桶存储，通过BucketHeap的Handle实现O(1)的取消和重新调度。
+type TimingWheel struct {
+	clock Clock
+	start time.Time     // 第0个tick对应的时间
+	tick  time.Duration // 时间轮的精度
+	now   int64         // 已处理到的tick
+
+	levels [_WHEEL_LEVELS]*heap.TypedBucketHeap[*timerNode]
+
+	nodes        [][]timerNode // 以块的方式存储定时器，保证节点指针在扩容时不变
+	nodeCount    int32
+	freeListHead int32
+	size         int
+
+	expired []*timerNode // Advance时复用的缓冲区
+}
+
+// tick：时间轮的精度，定时器最多延迟一个tick到期
+// clock：时间来源，为nil时使用SystemClock
+func NewTimingWheel(tick time.Duration, clock Clock) *TimingWheel {
+	if tick <= 0 {
+		panic("tick必须为正数")
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	w := &TimingWheel{
+		clock:        clock,
+		start:        clock.Now(),
+		tick:         tick,
+		freeListHead: -1,
+	}
+	for i := range w.levels {
+		w.levels[i] = heap.NewTypedBucketHeap[*timerNode](_WHEEL_SLOTS, 0, heap.OptionAutoGrow(true), heap.OptionMaxBucketCount(_WHEEL_SLOTS))
+	}
+	return w
+}
+
+func (w *TimingWheel) getNode(index int32) *timerNode {
+	return &w.nodes[index>>_BLOCK_SIZE_BITS][index&_BLOCK_SIZE_MASK]
+}
+
+func (w *TimingWheel) allocNode() *timerNode {
+	if w.freeListHead != -1 {
+		node := w.getNode(w.freeListHead)
+		w.freeListHead = node.next
+		return node
+	}
+	if int(w.nodeCount) >= len(w.nodes)<<_BLOCK_SIZE_BITS {
+		w.nodes = append(w.nodes, make([]timerNode, _BLOCK_SIZE))
+	}
+	node := w.getNode(w.nodeCount)
+	node.index = w.nodeCount
+	// generation从1开始，使零值Handle总是无效的
+	node.generation = 1
+	w.nodeCount++
+	return node
+}
+
+func (w *TimingWheel) freeNode(node *timerNode) {
+	node.value = nil
+	node.fn = nil
+	node.level = -1
+	node.generation++
+	node.next = w.freeListHead
+	w.freeListHead = node.index
+}
+
+func (w *TimingWheel) lookup(h Handle) *timerNode {
+	if h.index < 0 || h.index >= w.nodeCount {
+		return nil
+	}
+	node := w.getNode(h.index)
+	if node.generation != h.generation || node.level == -1 {
+		return nil
+	}
+	return node
+}
+
+// 将时间转换为tick，向上取整以保证定时器不会提前到期，且至少为下一个tick
+func (w *TimingWheel) toTick(deadline time.Time) int64 {
+	d := deadline.Sub(w.start)
+	t := int64(d / w.tick)
+	if d%w.tick > 0 {
+		t++
+	}
+	if t <= w.now {
+		t = w.now + 1
+	}
+	return t
+}
+
+// 根据到期时间与当前时间的差值选择层级和槽
+func (w *TimingWheel) place(node *timerNode) {
+	delta := node.deadline - w.now
+	level := 0
+	for level < _WHEEL_LEVELS-1 && delta >= 1<<(_WHEEL_BITS*(level+1)) {
+		level++
+	}
+	var slot int64
+	if delta >= 1<<(_WHEEL_BITS*_WHEEL_LEVELS) {
+		// 超出时间轮范围，放在最高层最晚处理的槽，处理时重新计算位置
+		slot = ((w.now >> (_WHEEL_BITS * level)) - 1) & _WHEEL_MASK
+	} else {
+		slot = (node.deadline >> (_WHEEL_BITS * level)) & _WHEEL_MASK
+	}
+	node.level = level
+	// 桶数量与OptionMaxBucketCount一致且自动扩容，Push不会失败
+	node.handle, _ = w.levels[level].Push(int(slot), node)
+}
+
+func (w *TimingWheel) schedule(deadline time.Time, value interface{}, fn func()) Handle {
+	node := w.allocNode()
+	node.value = value
+	node.fn = fn
+	node.deadline = w.toTick(deadline)
+	w.place(node)
+	w.size++
+	return Handle{index: node.index, generation: node.generation}
+}
+
+// 在deadline到期时通过Advance的expire回调返回value
+func (w *TimingWheel) Schedule(deadline time.Time, value interface{}) Handle {
+	return w.schedule(deadline, value, nil)
+}
+
+// 在deadline到期时由Advance调用fn
+func (w *TimingWheel) ScheduleFunc(deadline time.Time, fn func()) Handle {
+	return w.schedule(deadline, nil, fn)
+}
+
+// 取消定时器，Handle已失效（已到期或已取消）时返回false
+func (w *TimingWheel) Cancel(h Handle) bool {
+	node := w.lookup(h)
+	if node == nil {
+		return false
+	}
+	w.levels[node.level].Remove(node.handle)
+	w.freeNode(node)
+	w.size--
+	return true
+}
+
+// 修改定时器的到期时间，Handle保持有效，Handle已失效时返回false
+func (w *TimingWheel) Reschedule(h Handle, deadline time.Time) bool {
+	node := w.lookup(h)
+	if node == nil {
+		return false
+	}
+	w.levels[node.level].Remove(node.handle)
+	node.deadline = w.toTick(deadline)
+	w.place(node)
+	return true
+}
+
+// 未到期的定时器数量
+func (w *TimingWheel) Len() int {
+	return w.size
+}
+
+// 推进到clock的当前时间，依次处理所有到期的定时器：
+// 通过ScheduleFunc添加的定时器调用其fn，通过Schedule添加的定时器调用expire（可以为nil）。
+// 回调中可以调用Schedule、Cancel和Reschedule，但不能调用Advance
+func (w *TimingWheel) Advance(expire func(value interface{})) {
+	target := int64(w.clock.Now().Sub(w.start) / w.tick)
+	for w.now < target {
+		if w.size == 0 {
+			// 没有定时器，直接跳到目标时间
+			w.now = target
+			return
+		}
+		w.now++
+
+		// 从高到低将到达的槽下放，下放的定时器可能恰好落在更低层本次处理的槽中
+		level := 0
+		for level < _WHEEL_LEVELS-1 && w.now&(1<<(_WHEEL_BITS*(level+1))-1) == 0 {
+			level++
+		}
+		for ; level > 0; level-- {
+			w.cascade(level)
+		}
+
+		w.expired = w.levels[0].PopBucket(int(w.now&_WHEEL_MASK), w.expired[:0])
+		// 先将本次到期的定时器都标记为不在时间轮中，回调中对它们的Cancel和Reschedule会返回false
+		for _, node := range w.expired {
+			node.level = -1
+		}
+		for i, node := range w.expired {
+			w.expired[i] = nil
+			value, fn := node.value, node.fn
+			w.freeNode(node)
+			w.size--
+			if fn != nil {
+				fn()
+			} else if expire != nil {
+				expire(value)
+			}
+		}
+	}
+}
+
+func (w *TimingWheel) cascade(level int) {
+	slot := (w.now >> (_WHEEL_BITS * level)) & _WHEEL_MASK
+	w.expired = w.levels[level].PopBucket(int(slot), w.expired[:0])
+	for i, node := range w.expired {
+		w.expired[i] = nil
+		w.place(node)
+	}
+}
@@ -0,0 +1,291 @@
+package timer
+
+import (
+	"container/heap"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestWheel() (*TimingWheel, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return NewTimingWheel(time.Millisecond, clock), clock
+}
+
+func TestTimingWheelExpire(t *testing.T) {
+	w, clock := newTestWheel()
+	start := clock.Now()
+	w.Schedule(start.Add(3*time.Millisecond), 3)
+	w.Schedule(start.Add(1*time.Millisecond), 1)
+	// 不足一个tick的部分向上取整
+	w.Schedule(start.Add(1500*time.Microsecond), 2)
+	fired := false
+	w.ScheduleFunc(start.Add(2*time.Millisecond), func() { fired = true })
+
+	var expired []interface{}
+	expire := func(value interface{}) {
+		expired = append(expired, value)
+	}
+
+	clock.Add(time.Millisecond)
+	w.Advance(expire)
+	if len(expired) != 1 || expired[0] != 1 || fired {
+		t.Errorf("1ms时应只有1到期，实际为%v", expired)
+	}
+	clock.Add(2 * time.Millisecond)
+	w.Advance(expire)
+	if len(expired) != 3 || expired[1] != 2 || expired[2] != 3 || !fired {
+		t.Errorf("3ms时应依次到期1 2 3，实际为%v", expired)
+	}
+	if w.Len() != 0 {
+		t.Errorf("Len应为0，实际为%d", w.Len())
+	}
+}
+
+func TestTimingWheelCancelAndReschedule(t *testing.T) {
+	w, clock := newTestWheel()
+	start := clock.Now()
+	h0 := w.Schedule(start.Add(time.Second), 0)
+	h1 := w.Schedule(start.Add(time.Second), 1)
+	h2 := w.Schedule(start.Add(time.Hour), 2)
+
+	if !w.Cancel(h0) || w.Cancel(h0) {
+		t.Error("Cancel结果不正确")
+	}
+	if w.Cancel(Handle{}) {
+		t.Error("零值Handle应无效")
+	}
+	// 从高层移动到第0层
+	if !w.Reschedule(h2, start.Add(10*time.Millisecond)) {
+		t.Error("Reschedule应能找到定时器")
+	}
+	if !w.Reschedule(h1, start.Add(time.Minute)) {
+		t.Error("Reschedule应能找到定时器")
+	}
+
+	var expired []interface{}
+	expire := func(value interface{}) {
+		expired = append(expired, value)
+	}
+	clock.Add(time.Second)
+	w.Advance(expire)
+	if len(expired) != 1 || expired[0] != 2 {
+		t.Errorf("1s时应只有2到期，实际为%v", expired)
+	}
+	if w.Reschedule(h2, start.Add(time.Hour)) {
+		t.Error("到期后Handle应失效")
+	}
+	clock.Add(time.Minute)
+	w.Advance(expire)
+	if len(expired) != 2 || expired[1] != 1 {
+		t.Errorf("1min时1应到期，实际为%v", expired)
+	}
+}
+
+func TestTimingWheelCallback(t *testing.T) {
+	w, clock := newTestWheel()
+	start := clock.Now()
+	var h1 Handle
+	count := 0
+	w.ScheduleFunc(start.Add(time.Millisecond), func() {
+		count++
+		// 同一tick到期的定时器已不可取消
+		if w.Cancel(h1) {
+			t.Error("同一tick到期的定时器不应能被取消")
+		}
+		// 回调中添加新的定时器
+		w.ScheduleFunc(start.Add(2*time.Millisecond), func() { count++ })
+	})
+	h1 = w.ScheduleFunc(start.Add(time.Millisecond), func() { count++ })
+
+	clock.Add(5 * time.Millisecond)
+	w.Advance(nil)
+	if count != 3 || w.Len() != 0 {
+		t.Errorf("应执行3个回调，实际为%d", count)
+	}
+}
+
+func TestTimingWheelBeyondRange(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	w := NewTimingWheel(time.Second, clock)
+	// 超过 2^32 个tick
+	h := w.Schedule(clock.Now().Add(time.Second*(1<<32+5)), 0)
+	if w.Len() != 1 || !w.Reschedule(h, clock.Now().Add(time.Second*(1<<33))) || !w.Cancel(h) {
+		t.Error("超出范围的定时器处理不正确")
+	}
+}
+
+func TestTimingWheelRandom(t *testing.T) {
+	for _, seed := range []int64{42, 233, 1024} {
+		rand.Seed(seed)
+		w, clock := newTestWheel()
+		start := clock.Now()
+		handles := make(map[int64]Handle)
+		deadlines := make(map[int64]int64)
+		nextID := int64(0)
+		expire := func(value interface{}) {
+			id := value.(int64)
+			if deadlines[id] != w.now {
+				t.Fatalf("测试%d: 定时器%d应在%d到期，实际为%d", seed, id, deadlines[id], w.now)
+			}
+			delete(deadlines, id)
+			delete(handles, id)
+		}
+
+		for i := 0; i < 2000; i++ {
+			switch rand.Intn(4) {
+			case 0:
+				// 跨越多层的到期时间
+				tick := w.now + 1 + rand.Int63n(1<<(rand.Intn(20)+1))
+				handles[nextID] = w.Schedule(start.Add(time.Duration(tick)*time.Millisecond), nextID)
+				deadlines[nextID] = tick
+				nextID++
+			case 1:
+				for id, h := range handles {
+					if !w.Cancel(h) {
+						t.Fatalf("测试%d: Cancel定时器%d失败", seed, id)
+					}
+					delete(handles, id)
+					delete(deadlines, id)
+					break
+				}
+			case 2:
+				for id, h := range handles {
+					tick := w.now + 1 + rand.Int63n(1<<16)
+					if !w.Reschedule(h, start.Add(time.Duration(tick)*time.Millisecond)) {
+						t.Fatalf("测试%d: Reschedule定时器%d失败", seed, id)
+					}
+					deadlines[id] = tick
+					break
+				}
+			default:
+				clock.Add(time.Duration(rand.Intn(1<<12)) * time.Millisecond)
+				w.Advance(expire)
+			}
+		}
+		clock.Add(time.Duration(1<<21) * time.Millisecond)
+		w.Advance(expire)
+		if len(deadlines) != 0 || w.Len() != 0 {
+			t.Fatalf("测试%d: 仍有%d个定时器未到期", seed, len(deadlines))
+		}
+	}
+}
+
+// 基于container/heap的定时器，用于性能对比
+type heapTimer struct {
+	deadline int64
+	value    interface{}
+	index    int
+}
+
+type timerHeap []*heapTimer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].deadline < h[j].deadline }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*heapTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+const _BENCH_RANGE = 600000 // 10分钟，精度1ms
+
+func benchmarkDeadlines(n int) []int64 {
+	deadlines := make([]int64, n)
+	for i := range deadlines {
+		deadlines[i] = 1 + rand.Int63n(_BENCH_RANGE)
+	}
+	return deadlines
+}
+
+func BenchmarkTimingWheel(b *testing.B) {
+	deadlines := benchmarkDeadlines(b.N)
+	w, clock := newTestWheel()
+	start := clock.Now()
+	count := 0
+
+	b.ResetTimer()
+	for i, d := range deadlines {
+		w.Schedule(start.Add(time.Duration(d)*time.Millisecond), i)
+	}
+	clock.Add(_BENCH_RANGE * time.Millisecond)
+	w.Advance(func(value interface{}) { count++ })
+	if count != b.N {
+		b.Errorf("应到期%d个定时器，实际为%d", b.N, count)
+	}
+}
+
+func BenchmarkNativeTimerHeap(b *testing.B) {
+	deadlines := benchmarkDeadlines(b.N)
+	h := make(timerHeap, 0)
+	count := 0
+
+	b.ResetTimer()
+	for i, d := range deadlines {
+		heap.Push(&h, &heapTimer{deadline: d, value: i})
+	}
+	for now := int64(1); now <= _BENCH_RANGE; now++ {
+		for h.Len() > 0 && h[0].deadline <= now {
+			heap.Pop(&h)
+			count++
+		}
+	}
+	if count != b.N {
+		b.Errorf("应到期%d个定时器，实际为%d", b.N, count)
+	}
+}
+
+func BenchmarkTimingWheelCancel(b *testing.B) {
+	deadlines := benchmarkDeadlines(b.N)
+	w, clock := newTestWheel()
+	start := clock.Now()
+	handles := make([]Handle, b.N)
+
+	b.ResetTimer()
+	for i, d := range deadlines {
+		handles[i] = w.Schedule(start.Add(time.Duration(d)*time.Millisecond), nil)
+	}
+	for _, h := range handles {
+		w.Cancel(h)
+	}
+}
+
+func BenchmarkNativeTimerHeapCancel(b *testing.B) {
+	deadlines := benchmarkDeadlines(b.N)
+	h := make(timerHeap, 0)
+	timers := make([]*heapTimer, b.N)
+
+	b.ResetTimer()
+	for i, d := range deadlines {
+		timers[i] = &heapTimer{deadline: d}
+		heap.Push(&h, timers[i])
+	}
+	for _, t := range timers {
+		heap.Remove(&h, t.index)
+	}
+}
@@ -0,0 +1,166 @@
+package pool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// pool中最多保留的元素数量，超过时Put的元素交给OptionDiscard处理后丢弃，不大于0表示不限制
+// The maximum number of elements retained in the pool. Elements put beyond the limit
+// are handed to OptionDiscard and dropped. Non-positive values mean unlimited.
+type OptionMaxRetained int
+
+// 每个P最多保留的元素数量，元素计入Put时所在的P，直到被Get或随slice被GC回收，
+// 与OptionMaxRetained同时指定时两者都需要满足
+// The maximum number of elements retained per P. An element is charged to the P it is put on
+// until it is got or collected by GC along with its slice. Both limits apply if OptionMaxRetained
+// is also specified.
+type OptionMaxRetainedPerCPU int
+
+// 超过保留上限时丢弃元素的回调，例如归还大块内存，需要与Pool的元素类型一致
+// Callback for elements dropped beyond the retention limit, e.g. to release large buffers.
+// T must be the element type of the Pool.
+type OptionDiscard[T any] func(T)
+
+type boundShard struct {
+	retained int64
+	_        [56]byte // 避免false sharing / avoid false sharing
+}
+
+type poolBound[T any] struct {
+	maxRetained int64 // 不大于0表示不限制总数 / non-positive values mean the total is unlimited
+	retained    int64 // pool中的元素数量，被GC回收的slice中的元素通过finalizer减去 / elements in the pool, those in slices collected by GC are subtracted by finalizers
+	discard     func(T)
+
+	// 限制每个P的保留数量时，每个P一个shard。sync.Pool不提供当前P的信息，
+	// 通过hints（每个P本地缓存一个shard下标）近似当前P
+	// One shard per P if retention per P is limited. As sync.Pool does not expose the current P,
+	// it is approximated by hints, a sync.Pool caching a shard index locally on each P.
+	maxPerShard int64
+	shards      []boundShard
+	hints       sync.Pool
+	nextHint    uint32
+
+	prefill  int // pool为空时预填充的元素数量 / number of elements pre-filled when the pool runs out
+	sliceCap int
+}
+
+func newPoolBound[T any](config *poolConfig, discard func(T)) *poolBound[T] {
+	if config.maxRetained <= 0 && config.maxRetainedPerCPU <= 0 {
+		return nil
+	}
+	b := &poolBound[T]{discard: discard}
+	if config.maxRetained > 0 {
+		b.maxRetained = int64(config.maxRetained)
+	}
+	if config.maxRetainedPerCPU > 0 {
+		b.maxPerShard = int64(config.maxRetainedPerCPU)
+		b.shards = make([]boundShard, runtime.GOMAXPROCS(0))
+		b.hints.New = func() interface{} {
+			hint := int32((atomic.AddUint32(&b.nextHint, 1) - 1) % uint32(len(b.shards)))
+			return &hint
+		}
+	}
+	return b
+}
+
+// 当前P对应的shard，未限制每个P的保留数量时返回0
+// The shard of the current P, 0 if retention per P is unlimited.
+func (b *poolBound[T]) shard() int32 {
+	if b.shards == nil {
+		return 0
+	}
+	hint := b.hints.Get().(*int32)
+	b.hints.Put(hint)
+	return *hint
+}
+
+// 将counter增加至多n且不超过max，返回实际增加的数量
+// Increases counter by at most n without exceeding max, returns the actual increment.
+func reserveUpTo(counter *int64, max, n int64) int64 {
+	for {
+		retained := atomic.LoadInt64(counter)
+		if retained+n > max {
+			n = max - retained
+			if n <= 0 {
+				return 0
+			}
+		}
+		if atomic.CompareAndSwapInt64(counter, retained, retained+n) {
+			return n
+		}
+	}
+}
+
+// 在shard中预留至多n个元素的位置，返回实际预留的数量
+// Reserves room for at most n elements in the shard, returns the number actually reserved.
+func (b *poolBound[T]) reserve(shard int32, n int64) int64 {
+	if b.shards != nil {
+		if n = reserveUpTo(&b.shards[shard].retained, b.maxPerShard, n); n == 0 {
+			return 0
+		}
+	}
+	if b.maxRetained <= 0 {
+		atomic.AddInt64(&b.retained, n)
+		return n
+	}
+	reserved := reserveUpTo(&b.retained, b.maxRetained, n)
+	if b.shards != nil && reserved < n {
+		atomic.AddInt64(&b.shards[shard].retained, reserved-n)
+	}
+	return reserved
+}
+
+func (b *poolBound[T]) release(shard int32, n int64) {
+	atomic.AddInt64(&b.retained, -n)
+	if b.shards != nil {
+		atomic.AddInt64(&b.shards[shard].retained, -n)
+	}
+}
+
+func (b *poolBound[T]) getRetained() int64 {
+	return atomic.LoadInt64(&b.retained)
+}
+
+// slice被GC回收时，其中的元素不再被保留
+// Elements in a slice are no longer retained once the slice is collected by GC.
+func (b *poolBound[T]) track(p *poolSlice[T]) {
+	runtime.SetFinalizer(p, func(p *poolSlice[T]) {
+		if p.shards == nil {
+			b.release(0, int64(len(p.elems)))
+			return
+		}
+		for _, shard := range p.shards {
+			b.release(shard, 1)
+		}
+	})
+}
+
+// pool为空时直接alloc一个元素返回，并在当前P的保留上限内预填充fullPool
+// When the pool runs out, a new element is allocated and returned directly,
+// and fullPool is pre-filled within the retention limit of the current P.
+func (p *Pool[T]) getNew() T {
+	e := p.alloc()
+	shard := p.bound.shard()
+	n := int(p.bound.reserve(shard, int64(p.bound.prefill)))
+	if n > 0 {
+		elemPool := &poolSlice[T]{elems: make([]T, n, p.bound.sliceCap)}
+		for i := range elemPool.elems {
+			elemPool.elems[i] = p.alloc()
+		}
+		if p.bound.shards != nil {
+			elemPool.shards = make([]int32, n, p.bound.sliceCap)
+			for i := range elemPool.shards {
+				elemPool.shards[i] = shard
+			}
+		}
+		p.bound.track(elemPool)
+		p.fullPool.Put(elemPool)
+	}
+	if p.stats != nil {
+		p.stats.onRefill(n + 1)
+		p.stats.onGet(e)
+	}
+	return e
+}
@@ -0,0 +1,195 @@
+package pool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolMaxRetained(t *testing.T) {
+	discarded := 0
+	pool := NewPool(func() *pooledBuffer {
+		return &pooledBuffer{data: make([]byte, 0, 1024)}
+	}, nil, OptionInitFullPoolSize(4), OptionMaxRetained(3), OptionStats(true),
+		OptionDiscard[*pooledBuffer](func(b *pooledBuffer) { discarded++ }))
+
+	var got []*pooledBuffer
+	for i := 0; i < 8; i++ {
+		got = append(got, pool.Get())
+	}
+	for _, b := range got {
+		pool.Put(b)
+	}
+
+	stats := pool.Stats()
+	if stats.Retained > 3 || stats.Retained < 0 {
+		t.Errorf("Retained不应超过3，实际为%d", stats.Retained)
+	}
+	// 预填充也受上限约束，race模式下sync.Pool会随机丢弃slice，只能检查下限
+	if stats.Discards < 5 || int(stats.Discards) != discarded {
+		t.Errorf("Discards预期至少为5且与回调次数%d一致，实际为%d", discarded, stats.Discards)
+	}
+	if stats.Allocs > 8+3 {
+		t.Errorf("预填充不应超过保留上限，实际alloc了%d个", stats.Allocs)
+	}
+
+	// sync.Pool中的slice被GC回收后，不再计入保留数量
+	got = nil
+	for i := 0; i < 100 && pool.Stats().Retained != 0; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if retained := pool.Stats().Retained; retained != 0 {
+		t.Errorf("GC后Retained应为0，实际为%d", retained)
+	}
+	pool.Put(&pooledBuffer{})
+	if retained := pool.Stats().Retained; retained != 1 {
+		t.Errorf("GC后应能继续Put，Retained预期为1，实际为%d", retained)
+	}
+}
+
+func TestPoolDiscardedNotPinned(t *testing.T) {
+	discarded := 0
+	pool := NewPool(func() *pooledBuffer {
+		return &pooledBuffer{}
+	}, nil, OptionPoolSizePerCPU(2), OptionInitFullPoolSize(1), OptionMaxRetained(2),
+		OptionDiscard[*pooledBuffer](func(*pooledBuffer) { discarded++ }))
+
+	var finalized int32
+	b := &pooledBuffer{data: make([]byte, 0, 1<<20)}
+	runtime.SetFinalizer(b, func(*pooledBuffer) { atomic.StoreInt32(&finalized, 1) })
+	// slice被填满后放入fullPool，Get从中弹出b
+	pool.Put(&pooledBuffer{})
+	pool.Put(b)
+	if got := pool.Get(); got != b {
+		t.Skip("sync.Pool丢弃了slice")
+	}
+	// 新的元素放入另一个slice，保留数量达到上限，b被丢弃
+	pool.Put(&pooledBuffer{})
+	pool.Put(b)
+	if discarded != 1 {
+		t.Fatalf("b应被丢弃，丢弃次数为%d", discarded)
+	}
+	b = nil
+
+	// slice在sync.Pool中至少保留一次GC，被丢弃的元素应在第一次GC后被回收
+	runtime.GC()
+	for i := 0; i < 100 && atomic.LoadInt32(&finalized) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&finalized) == 0 {
+		t.Error("被丢弃的元素不应被pool中的slice引用")
+	}
+}
+
+func TestPoolMaxRetainedPerCPU(t *testing.T) {
+	// slice容量为1，每次Put都放入fullPool，可以被Get取出
+	pool := NewPool(func() int { return 0 }, nil, OptionPoolSizePerCPU(1), OptionInitFullPoolSize(1),
+		OptionMaxRetainedPerCPU(2), OptionStats(true))
+	if len(pool.bound.shards) != runtime.GOMAXPROCS(0) || pool.bound.maxPerShard != 2 || pool.bound.maxRetained != 0 {
+		t.Errorf("每个P的保留上限预期为%d个2，实际为%d个%d，总数上限为%d",
+			runtime.GOMAXPROCS(0), len(pool.bound.shards), pool.bound.maxPerShard, pool.bound.maxRetained)
+	}
+
+	// 同一个goroutine连续Put，计入的shard不会超过上限
+	for i := 0; i < 100; i++ {
+		pool.Put(i)
+	}
+	for i := range pool.bound.shards {
+		if retained := pool.bound.shards[i].retained; retained < 0 || retained > 2 {
+			t.Errorf("shard %d的保留数量应在[0, 2]之间，实际为%d", i, retained)
+		}
+	}
+	stats := pool.Stats()
+	if stats.Retained > int64(2*len(pool.bound.shards)) || stats.Discards < uint64(100-2*len(pool.bound.shards)) {
+		t.Errorf("统计结果不正确：%+v", stats)
+	}
+
+	// Get释放元素计入的shard，race模式下sync.Pool会随机丢弃slice，只检查各shard之和
+	for i := int64(0); i < stats.Retained; i++ {
+		pool.Get()
+	}
+	var sum int64
+	for i := range pool.bound.shards {
+		sum += pool.bound.shards[i].retained
+	}
+	if retained := pool.bound.getRetained(); retained != sum || retained > stats.Retained {
+		t.Errorf("Get之后保留数量应不增加且与各shard之和%d一致，实际为%d", sum, retained)
+	}
+
+	pool = NewPool(func() int { return 0 }, nil, OptionMaxRetainedPerCPU(1<<20), OptionMaxRetained(5))
+	for i := 0; i < 100; i++ {
+		pool.Put(i)
+	}
+	if retained := pool.bound.getRetained(); retained != 5 {
+		t.Errorf("保留数量预期为5，实际为%d", retained)
+	}
+	if pool = NewPool(func() int { return 0 }, nil); pool.bound != nil {
+		t.Error("未指定上限时不应限制保留数量")
+	}
+}
+
+// 生产者和消费者在不同的P上时，元素被Get后释放Put时计入的shard
+func TestPoolMaxRetainedPerCPUProducerConsumer(t *testing.T) {
+	pool := NewPool(func() int { return -1 }, nil, OptionPoolSizePerCPU(4), OptionInitFullPoolSize(4),
+		OptionMaxRetainedPerCPU(8), OptionStats(true))
+	ch := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			pool.Put(i)
+			ch <- struct{}{}
+		}
+		close(ch)
+	}()
+	go func() {
+		defer wg.Done()
+		for range ch {
+			pool.Get()
+		}
+	}()
+	wg.Wait()
+	var sum int64
+	for i := range pool.bound.shards {
+		if retained := pool.bound.shards[i].retained; retained < 0 || retained > 8 {
+			t.Errorf("shard %d的保留数量应在[0, 8]之间，实际为%d", i, retained)
+		}
+		sum += pool.bound.shards[i].retained
+	}
+	if stats := pool.Stats(); stats.Retained != sum {
+		t.Errorf("各shard之和预期为%d，实际为%d", stats.Retained, sum)
+	}
+}
+
+func TestPoolMaxRetainedConcurrent(t *testing.T) {
+	const max = 16
+	pool := NewPool(func() []byte {
+		return make([]byte, 0, 64)
+	}, nil, OptionPoolSizePerCPU(8), OptionInitFullPoolSize(8), OptionMaxRetained(max), OptionStats(true))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				bufs := [][]byte{pool.Get(), pool.Get(), pool.Get()}
+				for _, b := range bufs {
+					pool.Put(b)
+				}
+				if retained := pool.Stats().Retained; retained > max {
+					t.Errorf("Retained不应超过%d，实际为%d", max, retained)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := pool.Stats(); stats.Outstanding != 0 || stats.Retained > max {
+		t.Errorf("统计结果不正确：%+v", stats)
+	}
+}
@@ -0,0 +1,178 @@
+package pool
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+const (
+	DEFAULT_MIN_BYTES_SIZE = 64
+	DEFAULT_MAX_BYTES_SIZE = 1 << 20
+
+	// 每个size class首次预填充的最大字节数，避免大buffer一次预分配过多内存
+	// The maximum bytes pre-filled for each size class, so that large buffers are not over-allocated
+	_BYTES_PREFILL_SIZE = 1 << 16
+)
+
+// 按2的幂划分size class的[]byte池，每个size class使用一个Pool
+// 注意Get返回的buffer内容未清零
+// A []byte pool with power-of-two size classes, each backed by a Pool.
+// Note that buffers returned by Get() are not zeroed.
+type BytesPool struct {
+	minBits int // 最小size class为 1<<minBits / the smallest size class is 1<<minBits
+	maxBits int // 最大size class为 1<<maxBits / the largest size class is 1<<maxBits
+
+	classes []Pool[[]byte]
+
+	allocated [_BYTES_ALLOCATED_SHARDS]allocatedShard
+}
+
+const _BYTES_ALLOCATED_SHARDS = 64
+
+// 由size class申请的buffer的起始地址到容量的映射，buffer被GC回收时通过finalizer删除
+// Maps the base address of each buffer allocated by the size classes to its capacity,
+// entries are deleted by finalizers once the buffers are collected by GC.
+type allocatedShard struct {
+	sync.RWMutex
+	buffers map[uintptr]int
+	_       [32]byte // 避免false sharing / avoid false sharing
+}
+
+func (p *BytesPool) allocatedShard(base uintptr) *allocatedShard {
+	// 最小的buffer为64字节，忽略低6位
+	// The smallest buffer has 64 bytes, the lowest 6 bits are ignored
+	return &p.allocated[(base>>6)%_BYTES_ALLOCATED_SHARDS]
+}
+
+// minSize、maxSize：最小、最大的size class，向上取整为2的幂，超过maxSize的buffer不会被缓存
+// options：传给每个size class的Pool，未指定OptionInitFullPoolSize时按size class减小，且不超过OptionPoolSizePerCPU
+// minSize, maxSize: the smallest and largest size class, rounded up to powers of two.
+// Buffers larger than maxSize are not pooled.
+// options: passed to the Pool of each size class. If OptionInitFullPoolSize is not specified,
+// it decreases with the size class and does not exceed OptionPoolSizePerCPU.
+func NewBytesPool(minSize, maxSize int, options ...Option) *BytesPool {
+	if minSize <= 0 {
+		minSize = DEFAULT_MIN_BYTES_SIZE
+	}
+	if maxSize < minSize {
+		maxSize = minSize
+	}
+	p := &BytesPool{
+		minBits: bits.Len(uint(minSize - 1)),
+		maxBits: bits.Len(uint(maxSize - 1)),
+	}
+	for i := range p.allocated {
+		p.allocated[i].buffers = make(map[uintptr]int)
+	}
+	poolSizePerCPU, initFullPoolSizeSet := int(POOL_SIZE_PER_CPU), false
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case OptionPoolSizePerCPU:
+			poolSizePerCPU = int(o)
+		case OptionInitFullPoolSize:
+			initFullPoolSizeSet = true
+		}
+	}
+	p.classes = make([]Pool[[]byte], p.maxBits-p.minBits+1)
+	for i := range p.classes {
+		size := 1 << (p.minBits + i)
+		classOptions := options
+		if !initFullPoolSizeSet {
+			initFullPoolSize := _BYTES_PREFILL_SIZE / size
+			if initFullPoolSize > int(INIT_FULL_POOL_SIZE) {
+				initFullPoolSize = int(INIT_FULL_POOL_SIZE)
+			}
+			if initFullPoolSize > poolSizePerCPU {
+				initFullPoolSize = poolSizePerCPU
+			}
+			if initFullPoolSize < 1 {
+				initFullPoolSize = 1
+			}
+			classOptions = append([]Option{OptionInitFullPoolSize(initFullPoolSize)}, options...)
+		}
+		p.classes[i] = NewPool(func() []byte {
+			return p.alloc(size)
+		}, nil, classOptions...)
+	}
+	return p
+}
+
+func (p *BytesPool) alloc(size int) []byte {
+	b := make([]byte, size)
+	base := uintptr(unsafe.Pointer(&b[0]))
+	shard := p.allocatedShard(base)
+	shard.Lock()
+	shard.buffers[base] = size
+	shard.Unlock()
+	// finalizer不能引用b，否则b永远不会被回收
+	// The finalizer must not reference b, otherwise b is never collected.
+	runtime.SetFinalizer(&b[0], func(*byte) {
+		shard.Lock()
+		delete(shard.buffers, base)
+		shard.Unlock()
+	})
+	return b
+}
+
+// 返回长度为n的buffer，容量为不小于n的size class，n超过最大size class时直接申请
+// Returns a buffer of length n whose capacity is the smallest size class not less than n.
+// If n exceeds the largest size class, the buffer is allocated directly.
+func (p *BytesPool) Get(n int) []byte {
+	class := 0
+	if n > 1<<p.minBits {
+		class = bits.Len(uint(n-1)) - p.minBits
+	}
+	if class >= len(p.classes) {
+		return make([]byte, n)
+	}
+	return p.classes[class].Get()[:n]
+}
+
+// 按容量放回对应的size class，只接受由size class申请且未截取过起始位置和容量的buffer，
+// 其他buffer（例如直接申请的、b[64:]、b[:0:64]）会被拒绝，返回false
+// Puts b back to the size class of its capacity. Only buffers allocated by the size classes
+// whose start and capacity are not resliced are accepted. Other buffers, e.g. allocated elsewhere,
+// b[64:] or b[:0:64], are rejected and false is returned.
+func (p *BytesPool) Put(b []byte) bool {
+	c := cap(b)
+	if c == 0 || c&(c-1) != 0 {
+		return false
+	}
+	class := bits.Len(uint(c)) - 1 - p.minBits
+	if class < 0 || class >= len(p.classes) {
+		return false
+	}
+	b = b[:c]
+	base := uintptr(unsafe.Pointer(&b[0]))
+	shard := p.allocatedShard(base)
+	shard.RLock()
+	size := shard.buffers[base]
+	shard.RUnlock()
+	if size != c {
+		return false
+	}
+	p.classes[class].Put(b)
+	return true
+}
+
+// 每个size class的容量
+// Capacity of each size class
+func (p *BytesPool) SizeClasses() []int {
+	sizes := make([]int, len(p.classes))
+	for i := range sizes {
+		sizes[i] = 1 << (p.minBits + i)
+	}
+	return sizes
+}
+
+// 每个size class的统计，顺序与SizeClasses()一致
+// Statistics of each size class, in the same order as SizeClasses()
+func (p *BytesPool) Stats() []Stats {
+	stats := make([]Stats, len(p.classes))
+	for i := range p.classes {
+		stats[i] = p.classes[i].Stats()
+	}
+	return stats
+}
@@ -0,0 +1,170 @@
+package pool
+
+import (
+	"math/bits"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestBytesPool(t *testing.T) {
+	p := NewBytesPool(60, 1000, OptionStats(true))
+	if sizes := p.SizeClasses(); !equalSizes(sizes, []int{64, 128, 256, 512, 1024}) {
+		t.Errorf("SizeClasses结果预期为[64 128 256 512 1024]，实际为%v", sizes)
+	}
+
+	for _, c := range []struct{ n, cap int }{{0, 64}, {1, 64}, {64, 64}, {65, 128}, {1000, 1024}, {1024, 1024}, {1025, 1025}} {
+		b := p.Get(c.n)
+		if len(b) != c.n || cap(b) != c.cap {
+			t.Errorf("Get(%d)的长度和容量预期为%d %d，实际为%d %d", c.n, c.n, c.cap, len(b), cap(b))
+		}
+		if accepted := p.Put(b); accepted != (c.n <= 1024) {
+			t.Errorf("Put容量为%d的buffer结果预期为%v", cap(b), c.n <= 1024)
+		}
+	}
+
+	for _, b := range [][]byte{nil, make([]byte, 100), make([]byte, 32), make([]byte, 2048), p.Get(256)[1:]} {
+		if p.Put(b) {
+			t.Errorf("容量为%d的buffer应被拒绝", cap(b))
+		}
+	}
+
+	b := p.Get(100)
+	b = append(b[:0], "hello"...)
+	if !p.Put(b) {
+		t.Error("append未扩容的buffer应能放回")
+	}
+	stats := p.Stats()
+	if stats[1].Gets != 2 || stats[1].Puts != 2 || stats[2].Outstanding != 1 {
+		t.Errorf("size class统计不正确：%+v", stats)
+	}
+	// 最大的size class只预填充 _BYTES_PREFILL_SIZE/1024 个buffer
+	if stats[4].Allocs > _BYTES_PREFILL_SIZE/1024*2 {
+		t.Errorf("1024的size class预填充过多，alloc了%d个", stats[4].Allocs)
+	}
+}
+
+// 截取过的buffer与原buffer共享内存，不能放回
+func TestBytesPoolRejectResliced(t *testing.T) {
+	p := NewBytesPool(64, 1024)
+	b := p.Get(128)
+	if p.Put(b[64:]) {
+		t.Error("b[64:]的容量为64，但不是由size class申请的，应被拒绝")
+	}
+	if p.Put(b[:0:64]) {
+		t.Error("b[:0:64]的容量为64，但与b共享内存，应被拒绝")
+	}
+	if !p.Put(b[:0]) {
+		t.Error("只截取长度的buffer应能放回")
+	}
+	if p.Put(make([]byte, 128)) {
+		t.Error("不是由BytesPool申请的buffer应被拒绝")
+	}
+	for i := 0; i < 100; i++ {
+		if x := p.Get(64); &x[:1][0] == &b[64] {
+			t.Fatal("64的size class返回了与128的buffer共享内存的buffer")
+		}
+	}
+}
+
+// 指定较小的OptionPoolSizePerCPU时，计算出的OptionInitFullPoolSize不能超过它
+func TestBytesPoolOptions(t *testing.T) {
+	p := NewBytesPool(64, 1024, OptionPoolSizePerCPU(4), OptionStats(true))
+	p.Put(p.Get(64))
+	if allocs := p.Stats()[0].Allocs; allocs != 4 {
+		t.Errorf("64的size class预填充的数量预期为4，实际为%d", allocs)
+	}
+	p = NewBytesPool(64, 1024, OptionInitFullPoolSize(2), OptionStats(true))
+	p.Put(p.Get(1024))
+	if allocs := p.Stats()[4].Allocs; allocs != 2 {
+		t.Errorf("指定OptionInitFullPoolSize时预填充的数量预期为2，实际为%d", allocs)
+	}
+}
+
+func equalSizes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBytesPoolConcurrent(t *testing.T) {
+	p := NewBytesPool(DEFAULT_MIN_BYTES_SIZE, DEFAULT_MAX_BYTES_SIZE)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < 1000; i++ {
+				n := r.Intn(1 << 14)
+				b := p.Get(n)
+				if len(b) != n {
+					t.Errorf("Get(%d)返回的长度为%d", n, len(b))
+					return
+				}
+				for j := range b {
+					b[j] = byte(g)
+				}
+				p.Put(b)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+const _BENCH_BYTES_MAX_SIZE = 1 << 14
+
+func benchmarkBytesSizes() []int {
+	sizes := make([]int, 1024)
+	for i := range sizes {
+		sizes[i] = rand.Intn(_BENCH_BYTES_MAX_SIZE)
+	}
+	return sizes
+}
+
+func BenchmarkBytesPool(b *testing.B) {
+	p := NewBytesPool(DEFAULT_MIN_BYTES_SIZE, _BENCH_BYTES_MAX_SIZE)
+	sizes := benchmarkBytesSizes()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			buf := p.Get(sizes[i&1023])
+			p.Put(buf)
+		}
+	})
+}
+
+// 每个size class一个sync.Pool，存放*[]byte以避免Put时申请内存
+func BenchmarkNativeBytesPool(b *testing.B) {
+	minBits, maxBits := bits.Len(DEFAULT_MIN_BYTES_SIZE-1), bits.Len(_BENCH_BYTES_MAX_SIZE-1)
+	pools := make([]sync.Pool, maxBits-minBits+1)
+	for i := range pools {
+		size := 1 << (minBits + i)
+		pools[i].New = func() interface{} {
+			b := make([]byte, size)
+			return &b
+		}
+	}
+	sizes := benchmarkBytesSizes()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			n := sizes[i&1023]
+			class := 0
+			if n > DEFAULT_MIN_BYTES_SIZE {
+				class = bits.Len(uint(n-1)) - minBits
+			}
+			buf := pools[class].Get().(*[]byte)
+			*buf = (*buf)[:n]
+			pools[class].Put(buf)
+		}
+	})
+}
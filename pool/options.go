@@ -0,0 +1,174 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidOption = errors.New("invalid pool option")
+
+type poolConfig struct {
+	poolSizePerCPU    int
+	initFullPoolSize  int
+	stats             bool
+	leakThreshold     time.Duration
+	maxRetained       int
+	maxRetainedPerCPU int
+
+	// 分别为func() T、func(T)、func(T)，在New中检查类型
+	// func() T, func(T) and func(T) respectively, types are checked in New
+	alloc   interface{}
+	reset   interface{}
+	discard interface{}
+}
+
+func defaultPoolConfig() poolConfig {
+	return poolConfig{
+		poolSizePerCPU:   int(POOL_SIZE_PER_CPU),
+		initFullPoolSize: int(INIT_FULL_POOL_SIZE),
+	}
+}
+
+// New的参数，不合法时New返回错误而不是使用默认值
+// Options of New. Invalid options make New return an error instead of falling back to defaults.
+type PoolOption func(*poolConfig) error
+
+func invalidOption(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidOption, fmt.Sprintf(format, args...))
+}
+
+// 每个slice的容量，默认为POOL_SIZE_PER_CPU
+// Capacity of each slice, POOL_SIZE_PER_CPU by default
+func WithPoolSizePerCPU(n int) PoolOption {
+	return func(c *poolConfig) error {
+		if n <= 0 {
+			return invalidOption("pool size per CPU %d must be positive", n)
+		}
+		c.poolSizePerCPU = n
+		return nil
+	}
+}
+
+// pool为空时预填充的元素数量，默认为INIT_FULL_POOL_SIZE，不能大于WithPoolSizePerCPU
+// Number of elements pre-filled when the pool runs out, INIT_FULL_POOL_SIZE by default.
+// It cannot be larger than WithPoolSizePerCPU.
+func WithInitFullPoolSize(n int) PoolOption {
+	return func(c *poolConfig) error {
+		if n <= 0 {
+			return invalidOption("init full pool size %d must be positive", n)
+		}
+		c.initFullPoolSize = n
+		return nil
+	}
+}
+
+// 创建新元素的函数，必须指定，T必须与New的元素类型一致
+// Function creating new elements, required. T must be the element type of New.
+func WithAlloc[T any](alloc func() T) PoolOption {
+	return func(c *poolConfig) error {
+		if alloc == nil {
+			return invalidOption("alloc is nil")
+		}
+		c.alloc = alloc
+		return nil
+	}
+}
+
+// Put时调用的函数，T必须与New的元素类型一致
+// Function called on Put(). T must be the element type of New.
+func WithReset[T any](reset func(T)) PoolOption {
+	return func(c *poolConfig) error {
+		c.reset = reset
+		return nil
+	}
+}
+
+// 同OptionStats
+// Same as OptionStats
+func WithStats() PoolOption {
+	return func(c *poolConfig) error {
+		c.stats = true
+		return nil
+	}
+}
+
+// 同OptionLeakThreshold
+// Same as OptionLeakThreshold
+func WithLeakThreshold(threshold time.Duration) PoolOption {
+	return func(c *poolConfig) error {
+		if threshold <= 0 {
+			return invalidOption("leak threshold %v must be positive", threshold)
+		}
+		c.leakThreshold = threshold
+		return nil
+	}
+}
+
+// 同OptionMaxRetained
+// Same as OptionMaxRetained
+func WithMaxRetained(n int) PoolOption {
+	return func(c *poolConfig) error {
+		if n <= 0 {
+			return invalidOption("max retained %d must be positive", n)
+		}
+		c.maxRetained = n
+		return nil
+	}
+}
+
+// 同OptionMaxRetainedPerCPU
+// Same as OptionMaxRetainedPerCPU
+func WithMaxRetainedPerCPU(n int) PoolOption {
+	return func(c *poolConfig) error {
+		if n <= 0 {
+			return invalidOption("max retained per CPU %d must be positive", n)
+		}
+		c.maxRetainedPerCPU = n
+		return nil
+	}
+}
+
+// 同OptionDiscard，T必须与New的元素类型一致
+// Same as OptionDiscard. T must be the element type of New.
+func WithDiscard[T any](discard func(T)) PoolOption {
+	return func(c *poolConfig) error {
+		c.discard = discard
+		return nil
+	}
+}
+
+// 创建元素类型为T的Pool，必须指定WithAlloc，参数不合法时返回ErrInvalidOption
+// Creates a Pool with elements of type T. WithAlloc is required.
+// ErrInvalidOption is returned if any option is invalid.
+func New[T any](options ...PoolOption) (Pool[T], error) {
+	config := defaultPoolConfig()
+	for _, opt := range options {
+		if err := opt(&config); err != nil {
+			return Pool[T]{}, err
+		}
+	}
+	if config.initFullPoolSize > config.poolSizePerCPU {
+		return Pool[T]{}, invalidOption("init full pool size %d is larger than pool size per CPU %d", config.initFullPoolSize, config.poolSizePerCPU)
+	}
+
+	alloc, ok := config.alloc.(func() T)
+	if !ok {
+		if config.alloc == nil {
+			return Pool[T]{}, invalidOption("alloc is required")
+		}
+		return Pool[T]{}, invalidOption("alloc type %T mismatches element type", config.alloc)
+	}
+	var reset, discard func(T)
+	if config.reset != nil {
+		if reset, ok = config.reset.(func(T)); !ok {
+			return Pool[T]{}, invalidOption("reset type %T mismatches element type", config.reset)
+		}
+	}
+	if config.discard != nil {
+		if discard, ok = config.discard.(func(T)); !ok {
+			return Pool[T]{}, invalidOption("discard type %T mismatches element type", config.discard)
+		}
+	}
+	return newPool(alloc, reset, discard, &config), nil
+}
@@ -0,0 +1,72 @@
+package pool
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	discarded := 0
+	pool, err := New[*pooledBuffer](
+		WithAlloc(func() *pooledBuffer { return &pooledBuffer{} }),
+		WithReset(func(b *pooledBuffer) { b.data = b.data[:0] }),
+		WithPoolSizePerCPU(4),
+		WithInitFullPoolSize(1), // 不预填充，避免race模式下sync.Pool随机丢弃预填充的slice
+		WithStats(),
+		WithMaxRetained(1),
+		WithDiscard(func(b *pooledBuffer) { discarded++ }),
+	)
+	if err != nil {
+		t.Fatalf("New返回错误 %v", err)
+	}
+
+	b0, b1 := pool.Get(), pool.Get()
+	b0.data = append(b0.data, 1)
+	pool.Put(b0)
+	pool.Put(b1)
+	if len(b0.data) != 0 || discarded != 1 {
+		t.Errorf("reset和discard应各调用1次，实际data为%v，discard %d次", b0.data, discarded)
+	}
+	if stats := pool.Stats(); stats.Gets != 2 || stats.Discards != 1 {
+		t.Errorf("统计结果不正确：%+v", stats)
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	alloc := WithAlloc(func() int { return 0 })
+	for _, c := range []struct {
+		name    string
+		options []PoolOption
+	}{
+		{"无alloc", nil},
+		{"alloc为nil", []PoolOption{WithAlloc[int](nil)}},
+		{"alloc类型不匹配", []PoolOption{WithAlloc(func() string { return "" })}},
+		{"reset类型不匹配", []PoolOption{alloc, WithReset(func(string) {})}},
+		{"discard类型不匹配", []PoolOption{alloc, WithDiscard(func(string) {})}},
+		{"pool size为0", []PoolOption{alloc, WithPoolSizePerCPU(0)}},
+		{"init size为负数", []PoolOption{alloc, WithInitFullPoolSize(-1)}},
+		{"init size大于pool size", []PoolOption{alloc, WithPoolSizePerCPU(8), WithInitFullPoolSize(16)}},
+		{"init size大于默认pool size", []PoolOption{alloc, WithInitFullPoolSize(int(POOL_SIZE_PER_CPU) + 1)}},
+		{"leak threshold为0", []PoolOption{alloc, WithLeakThreshold(0)}},
+		{"max retained为0", []PoolOption{alloc, WithMaxRetained(0)}},
+		{"max retained per CPU为负数", []PoolOption{alloc, WithMaxRetainedPerCPU(-1)}},
+	} {
+		if _, err := New[int](c.options...); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: 应返回ErrInvalidOption，实际返回 %v", c.name, err)
+		}
+	}
+
+	if _, err := New[int](alloc, WithPoolSizePerCPU(8), WithInitFullPoolSize(8), WithLeakThreshold(time.Second)); err != nil {
+		t.Errorf("合法的参数不应返回错误，实际返回 %v", err)
+	}
+}
+
+func TestNewLockFreePoolFallback(t *testing.T) {
+	// 兼容旧的行为：参数不合法时使用默认值
+	pool := NewLockFreePool(func() interface{} { return 0 }, OptionPoolSizePerCPU(8), OptionInitFullPoolSize(16), OptionStats(true))
+	pool.Get()
+	if stats := pool.Stats(); stats.Allocs != uint64(INIT_FULL_POOL_SIZE) {
+		t.Errorf("应使用默认的INIT_FULL_POOL_SIZE，实际alloc了%d个", stats.Allocs)
+	}
+}
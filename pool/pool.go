@@ -2,10 +2,12 @@ package pool
 
 import (
 	"sync"
+	"time"
 )
 
 type Option = interface{}
 type OptionPoolSizePerCPU int
+
 // 太大会导致Get操作卡顿，太小会导致创建过多的slice
 // A size too large will slow down Get(), while a size too small leads to frequent slice allocation
 type OptionInitFullPoolSize int
@@ -23,64 +25,140 @@ const INIT_FULL_POOL_SIZE = OptionInitFullPoolSize(256)
 // A slice will be put in this lockless element in order to avoid the use of mutex.
 // When Get() or Put() is called on LockFreePool, the slice is fetched, elements
 // pushed into or poped from the slice, then put back to sync.Pool.
-type LockFreePool struct {
+type Pool[T any] struct {
 	emptyPool *sync.Pool
 	fullPool  *sync.Pool
 
-	alloc func() interface{}
+	alloc func() T
+	reset func(T)
+	stats *poolStats    // 未开启统计时为nil / nil if statistics are disabled
+	bound *poolBound[T] // 未限制保留数量时为nil / nil if retention is unlimited
+}
+
+// emptyPool、fullPool中存放的slice，存放指针以避免convT2Eslice
+// Slices stored in emptyPool and fullPool, pointers are stored to avoid convT2Eslice
+type poolSlice[T any] struct {
+	elems  []T
+	shards []int32 // 限制每个P的保留数量时，elems[i]计入的shard / the shard elems[i] is charged to if retention per P is limited
 }
 
-func (p *LockFreePool) Get() interface{} {
-	elemPool := p.fullPool.Get().(*[]interface{}) // avoid convT2Eslice
-	pool := *elemPool
+// 弹出最后一个元素计入的shard，需要在elems弹出之后调用
+// Pops the shard of the last element, called after elems is popped.
+func (s *poolSlice[T]) popShard() int32 {
+	if len(s.shards) == 0 {
+		return 0
+	}
+	shard := s.shards[len(s.shards)-1]
+	s.shards = s.shards[:len(s.shards)-1]
+	return shard
+}
+
+func (p *Pool[T]) Get() T {
+	x := p.fullPool.Get()
+	if x == nil {
+		// 只有限制保留数量时fullPool没有New
+		// fullPool has no New only if retention is limited
+		return p.getNew()
+	}
+	elemPool := x.(*poolSlice[T])
+	pool := elemPool.elems
 	e := pool[len(pool)-1]
-	*elemPool = pool[:len(pool)-1]
+	// 清空弹出的位置，否则被丢弃的元素仍会被slice引用
+	// Clear the popped slot, otherwise a discarded element is still referenced by the slice
+	var zero T
+	pool[len(pool)-1] = zero
+	elemPool.elems = pool[:len(pool)-1]
+	if p.bound != nil {
+		p.bound.release(elemPool.popShard(), 1)
+	}
 	if len(pool) > 1 {
 		p.fullPool.Put(elemPool)
 	} else {
 		p.emptyPool.Put(elemPool) // Empty, Put for other CPUs
 	}
+	if p.stats != nil {
+		p.stats.onGet(e)
+	}
 	return e
 }
 
-func (p *LockFreePool) Put(x interface{}) {
-	pool := p.emptyPool.Get().(*[]interface{}) // avoid convT2Eslice
-	*pool = append(*pool, x)
-	if len(*pool) < cap(*pool) {
+// 若指定了reset，先调用reset(x)再放回，超过保留上限时x交给OptionDiscard处理后丢弃
+// If reset is specified, reset(x) is called before x is put back.
+// Beyond the retention limit, x is handed to OptionDiscard and dropped.
+func (p *Pool[T]) Put(x T) {
+	if p.stats != nil {
+		p.stats.onPut(x)
+	}
+	var shard int32
+	if p.bound != nil {
+		if shard = p.bound.shard(); p.bound.reserve(shard, 1) == 0 {
+			if p.stats != nil {
+				p.stats.onDiscard()
+			}
+			if p.bound.discard != nil {
+				p.bound.discard(x)
+			}
+			return
+		}
+	}
+	if p.reset != nil {
+		p.reset(x)
+	}
+	pool := p.emptyPool.Get().(*poolSlice[T])
+	pool.elems = append(pool.elems, x)
+	if pool.shards != nil {
+		pool.shards = append(pool.shards, shard)
+	}
+	if len(pool.elems) < cap(pool.elems) {
 		p.emptyPool.Put(pool)
 	} else {
 		p.fullPool.Put(pool) // Full, Put for other CPUs
 	}
 }
 
-// 注意OptionInitFullPoolSize不能大于OptionPoolSizePerCPU，且不能小于等于0
-// Note that OptionInitFullPoolSize cannot be larger than OptionPoolSizePerCPU and must be positive.
-func NewLockFreePool(alloc func() interface{}, options ...Option) LockFreePool {
-	poolSizePerCPU := POOL_SIZE_PER_CPU
-	initFullPoolSize := INIT_FULL_POOL_SIZE
-	for _, opt := range options {
-		if size, ok := opt.(OptionPoolSizePerCPU); ok {
-			poolSizePerCPU = size
-		} else if size, ok := opt.(OptionInitFullPoolSize); ok {
-			initFullPoolSize = size
-		}
+// 未开启OptionStats或OptionLeakThreshold时返回零值
+// Returns the zero value if neither OptionStats nor OptionLeakThreshold is specified.
+func (p *Pool[T]) Stats() Stats {
+	if p.stats == nil {
+		return Stats{}
 	}
-	if poolSizePerCPU < OptionPoolSizePerCPU(initFullPoolSize) || initFullPoolSize <= 0 {
-		poolSizePerCPU = POOL_SIZE_PER_CPU
-		initFullPoolSize = INIT_FULL_POOL_SIZE
+	stats := p.stats.get()
+	if p.bound != nil {
+		stats.Retained = p.bound.getRetained()
 	}
+	return stats
+}
+
+// 元素类型为interface{}的Pool
+// Pool with elements of type interface{}
+type LockFreePool = Pool[interface{}]
+
+func newPool[T any](alloc func() T, reset func(T), discard func(T), config *poolConfig) Pool[T] {
+	poolSizePerCPU := config.poolSizePerCPU
+	initFullPoolSize := config.initFullPoolSize
+	stats := newPoolStats(config)
+	bound := newPoolBound(config, discard)
 	newEmptySlice := func() interface{} {
-		p := make([]interface{}, 0, poolSizePerCPU)
-		return &p
+		p := &poolSlice[T]{elems: make([]T, 0, poolSizePerCPU)}
+		if bound != nil {
+			if bound.shards != nil {
+				p.shards = make([]int32, 0, poolSizePerCPU)
+			}
+			bound.track(p)
+		}
+		return p
 	}
 	newFullSlice := func() interface{} {
-		p := make([]interface{}, initFullPoolSize, poolSizePerCPU)
-		for i := OptionInitFullPoolSize(0); i < initFullPoolSize; i++ {
-			p[i] = alloc()
+		p := &poolSlice[T]{elems: make([]T, initFullPoolSize, poolSizePerCPU)}
+		for i := 0; i < initFullPoolSize; i++ {
+			p.elems[i] = alloc()
 		}
-		return &p
+		if stats != nil {
+			stats.onRefill(initFullPoolSize)
+		}
+		return p
 	}
-	return LockFreePool{
+	pool := Pool[T]{
 		emptyPool: &sync.Pool{
 			New: newEmptySlice,
 		},
@@ -88,5 +166,62 @@ func NewLockFreePool(alloc func() interface{}, options ...Option) LockFreePool {
 			New: newFullSlice,
 		},
 		alloc: alloc,
+		reset: reset,
+		stats: stats,
+		bound: bound,
+	}
+	if bound != nil {
+		// 由getNew在保留上限内预填充
+		// Pre-filled by getNew() within the retention limit
+		pool.fullPool.New = nil
+		bound.prefill = initFullPoolSize - 1
+		bound.sliceCap = poolSizePerCPU
+	}
+	return pool
+}
+
+// alloc用于创建新元素，reset可以为nil
+// options：OptionPoolSizePerCPU、OptionInitFullPoolSize、OptionStats、OptionLeakThreshold、
+// OptionMaxRetained、OptionMaxRetainedPerCPU、OptionDiscard[T]
+// 注意OptionInitFullPoolSize不能大于OptionPoolSizePerCPU，且不能小于等于0，否则两者都使用默认值，
+// 需要检查参数时使用New
+// alloc creates new elements, reset can be nil.
+// options: OptionPoolSizePerCPU, OptionInitFullPoolSize, OptionStats, OptionLeakThreshold,
+// OptionMaxRetained, OptionMaxRetainedPerCPU, OptionDiscard[T]
+// Note that OptionInitFullPoolSize cannot be larger than OptionPoolSizePerCPU and must be positive,
+// otherwise both fall back to defaults. Use New if the options need to be validated.
+func NewPool[T any](alloc func() T, reset func(T), options ...Option) Pool[T] {
+	config := defaultPoolConfig()
+	var discard func(T)
+	for _, opt := range options {
+		switch o := opt.(type) {
+		case OptionPoolSizePerCPU:
+			config.poolSizePerCPU = int(o)
+		case OptionInitFullPoolSize:
+			config.initFullPoolSize = int(o)
+		case OptionStats:
+			config.stats = config.stats || bool(o)
+		case OptionLeakThreshold:
+			if o > 0 {
+				config.leakThreshold = time.Duration(o)
+			}
+		case OptionMaxRetained:
+			config.maxRetained = int(o)
+		case OptionMaxRetainedPerCPU:
+			config.maxRetainedPerCPU = int(o)
+		case OptionDiscard[T]:
+			discard = o
+		}
 	}
+	if config.poolSizePerCPU < config.initFullPoolSize || config.initFullPoolSize <= 0 {
+		config.poolSizePerCPU = int(POOL_SIZE_PER_CPU)
+		config.initFullPoolSize = int(INIT_FULL_POOL_SIZE)
+	}
+	return newPool(alloc, reset, discard, &config)
+}
+
+// 注意OptionInitFullPoolSize不能大于OptionPoolSizePerCPU，且不能小于等于0
+// Note that OptionInitFullPoolSize cannot be larger than OptionPoolSizePerCPU and must be positive.
+func NewLockFreePool(alloc func() interface{}, options ...Option) LockFreePool {
+	return NewPool(alloc, nil, options...)
 }
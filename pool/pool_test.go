@@ -127,3 +127,147 @@ func BenchmarkLockFreePoolOverPut(b *testing.B) {
 		pool.Put(0)
 	}
 }
+
+type pooledBuffer struct {
+	data []byte
+}
+
+func TestPoolReset(t *testing.T) {
+	allocated := 0
+	pool := NewPool(func() *pooledBuffer {
+		allocated++
+		return &pooledBuffer{data: make([]byte, 0, 64)}
+	}, func(b *pooledBuffer) {
+		b.data = b.data[:0]
+	}, OptionPoolSizePerCPU(4), OptionInitFullPoolSize(2))
+
+	b := pool.Get()
+	if allocated != 2 || b == nil || len(b.data) != 0 {
+		t.Errorf("Get应从预分配的2个元素中返回，实际分配了%d个", allocated)
+	}
+	b.data = append(b.data, "hello"...)
+	pool.Put(b)
+	if len(b.data) != 0 {
+		t.Errorf("Put应调用reset，实际data为%q", b.data)
+	}
+
+	noReset := NewPool(func() int { return 1 }, nil)
+	if x := noReset.Get(); x != 1 {
+		t.Errorf("结果预期为%v，实际为%v", 1, x)
+	}
+	noReset.Put(2)
+}
+
+func TestPoolConcurrent(t *testing.T) {
+	pool := NewPool(func() *pooledBuffer {
+		return &pooledBuffer{}
+	}, func(b *pooledBuffer) {
+		b.data = b.data[:0]
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				b := pool.Get()
+				if len(b.data) != 0 {
+					t.Errorf("Get返回的元素未被reset，data为%q", b.data)
+					return
+				}
+				b.data = append(b.data, byte(g))
+				pool.Put(b)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkPoolGetPut1Thread(b *testing.B) {
+	pools := make([]*Pool[int], b.N/1024)
+	for p, _ := range pools {
+		pool := NewPool(func() int { return 0 }, nil)
+		for i := 0; i < 1024; i++ {
+			pool.Put(0)
+		}
+		pools[p] = &pool
+	}
+
+	b.ResetTimer()
+
+	for _, p := range pools {
+		for i := 0; i < 1024; i++ {
+			p.Get()
+		}
+	}
+}
+
+func BenchmarkPoolGetPut2Thread(b *testing.B) {
+	pools := make([]*Pool[int], 16)
+	for i := range pools {
+		pool := NewPool(func() int { return 0 }, nil)
+		pools[i] = &pool
+	}
+
+	put := func(pool []*Pool[int]) {
+		for i := 0; i < b.N; i++ {
+			for _, p := range pools {
+				p.Put(0)
+			}
+		}
+	}
+
+	b.ResetTimer()
+	go put(pools)
+	for i := 0; i < b.N; i++ {
+		for _, p := range pools {
+			p.Get()
+		}
+	}
+}
+
+func BenchmarkPoolHungryGet(b *testing.B) {
+	pool := NewPool(func() int { return 0 }, nil)
+	for i := 0; i < b.N; i++ {
+		pool.Get()
+	}
+}
+
+func BenchmarkPoolOverPut(b *testing.B) {
+	pool := NewPool(func() int { return 0 }, nil)
+	for i := 0; i < 1024; i++ {
+		pool.Put(0)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Put(0)
+	}
+}
+
+func BenchmarkPoolGetPutReset(b *testing.B) {
+	pool := NewPool(func() *pooledBuffer {
+		return &pooledBuffer{data: make([]byte, 0, 64)}
+	}, func(b *pooledBuffer) {
+		b.data = b.data[:0]
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x := pool.Get()
+		x.data = append(x.data, 0)
+		pool.Put(x)
+	}
+}
+
+func BenchmarkNativePoolGetPutReset(b *testing.B) {
+	pool := &sync.Pool{New: func() interface{} {
+		return &pooledBuffer{data: make([]byte, 0, 64)}
+	}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x := pool.Get().(*pooledBuffer)
+		x.data = append(x.data, 0)
+		x.data = x.data[:0]
+		pool.Put(x)
+	}
+}
@@ -0,0 +1,130 @@
+package pool
+
+import (
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 开启统计，可通过Stats()获取alloc、Get、Put的次数等，计数使用原子操作，会略微降低性能
+// Enable statistics exported via Stats(). Counters are updated atomically, which slightly slows down Get() and Put().
+type OptionStats bool
+
+// 开启泄漏检测（同时开启统计），记录Get之后超过该时长仍未Put的元素被Get时的调用栈，
+// 需要在每次Get时获取调用栈，仅用于调试。元素类型不可比较（如slice）时无法跟踪
+// Enable leak detection (implies OptionStats). The stack of every Get() not followed by a Put()
+// within the threshold is recorded. Capturing stacks is expensive, use it for debugging only.
+// Elements of incomparable types (e.g. slices) cannot be tracked.
+type OptionLeakThreshold time.Duration
+
+type Stats struct {
+	Allocs      uint64 // alloc的调用次数 / number of alloc() calls
+	Gets        uint64 // Get的调用次数 / number of Get() calls
+	Puts        uint64 // Put的调用次数 / number of Put() calls
+	Outstanding int64  // 已Get未Put的元素数量 / number of elements not yet put back
+	Refills     uint64 // 因没有可用元素而新建slice的次数 / number of slices allocated because the pool ran out
+	Discards    uint64 // 超过保留上限而丢弃的元素数量 / number of elements dropped beyond the retention limit
+	Retained    int64  // 限制保留数量时pool中的元素数量 / number of elements in the pool if retention is limited
+
+	Leaks []Leak // 超过OptionLeakThreshold未Put的元素，按Get的时间排序 / elements not put back within OptionLeakThreshold, ordered by Get time
+}
+
+type Leak struct {
+	GetTime time.Time
+	Stack   string // Get时的调用栈 / stack of the Get() call
+}
+
+type poolStats struct {
+	allocs   uint64
+	gets     uint64
+	puts     uint64
+	refills  uint64
+	discards uint64
+
+	leakThreshold time.Duration
+	m             sync.Mutex
+	outstanding   map[interface{}][]*Leak // 可能有多个相等的元素被Get，如值类型 / equal elements of value types may be got more than once
+}
+
+func newPoolStats(config *poolConfig) *poolStats {
+	if !config.stats && config.leakThreshold <= 0 {
+		return nil
+	}
+	s := &poolStats{}
+	if config.leakThreshold > 0 {
+		s.leakThreshold = config.leakThreshold
+		s.outstanding = make(map[interface{}][]*Leak)
+	}
+	return s
+}
+
+func trackable(x interface{}) bool {
+	t := reflect.TypeOf(x)
+	return t != nil && t.Comparable()
+}
+
+func (s *poolStats) onRefill(allocs int) {
+	atomic.AddUint64(&s.refills, 1)
+	atomic.AddUint64(&s.allocs, uint64(allocs))
+}
+
+func (s *poolStats) onDiscard() {
+	atomic.AddUint64(&s.discards, 1)
+}
+
+func (s *poolStats) onGet(x interface{}) {
+	atomic.AddUint64(&s.gets, 1)
+	if s.outstanding == nil || !trackable(x) {
+		return
+	}
+	leak := &Leak{GetTime: time.Now(), Stack: string(debug.Stack())}
+	s.m.Lock()
+	s.outstanding[x] = append(s.outstanding[x], leak)
+	s.m.Unlock()
+}
+
+func (s *poolStats) onPut(x interface{}) {
+	atomic.AddUint64(&s.puts, 1)
+	if s.outstanding == nil || !trackable(x) {
+		return
+	}
+	s.m.Lock()
+	if leaks := s.outstanding[x]; len(leaks) > 1 {
+		s.outstanding[x] = leaks[:len(leaks)-1]
+	} else if len(leaks) == 1 {
+		delete(s.outstanding, x)
+	}
+	s.m.Unlock()
+}
+
+func (s *poolStats) get() Stats {
+	stats := Stats{
+		Allocs:   atomic.LoadUint64(&s.allocs),
+		Gets:     atomic.LoadUint64(&s.gets),
+		Puts:     atomic.LoadUint64(&s.puts),
+		Refills:  atomic.LoadUint64(&s.refills),
+		Discards: atomic.LoadUint64(&s.discards),
+	}
+	stats.Outstanding = int64(stats.Gets - stats.Puts)
+	if s.outstanding == nil {
+		return stats
+	}
+
+	now := time.Now()
+	s.m.Lock()
+	for _, leaks := range s.outstanding {
+		for _, leak := range leaks {
+			if now.Sub(leak.GetTime) >= s.leakThreshold {
+				stats.Leaks = append(stats.Leaks, *leak)
+			}
+		}
+	}
+	s.m.Unlock()
+	sort.Slice(stats.Leaks, func(i, j int) bool {
+		return stats.Leaks[i].GetTime.Before(stats.Leaks[j].GetTime)
+	})
+	return stats
+}
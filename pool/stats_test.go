@@ -0,0 +1,93 @@
+package pool
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoolStats(t *testing.T) {
+	pool := NewPool(func() *pooledBuffer {
+		return &pooledBuffer{}
+	}, nil, OptionPoolSizePerCPU(4), OptionInitFullPoolSize(2), OptionStats(true))
+
+	var got []*pooledBuffer
+	for i := 0; i < 3; i++ {
+		got = append(got, pool.Get())
+	}
+	pool.Put(got[0])
+
+	stats := pool.Stats()
+	// 单线程下第3次Get时pool已空，需要再新建一个full slice
+	if stats.Gets != 3 || stats.Puts != 1 || stats.Outstanding != 2 {
+		t.Errorf("Gets/Puts/Outstanding预期为3/1/2，实际为%d/%d/%d", stats.Gets, stats.Puts, stats.Outstanding)
+	}
+	if stats.Refills < 2 || stats.Allocs != stats.Refills*2 {
+		t.Errorf("Refills预期至少为2，Allocs预期为Refills的2倍，实际为%d/%d", stats.Refills, stats.Allocs)
+	}
+	if stats.Leaks != nil {
+		t.Errorf("未开启泄漏检测时Leaks应为nil，实际为%v", stats.Leaks)
+	}
+
+	noStats := NewPool(func() int { return 0 }, nil)
+	noStats.Get()
+	if stats := noStats.Stats(); stats.Gets != 0 {
+		t.Errorf("未开启统计时应返回零值，实际为%+v", stats)
+	}
+}
+
+func getForLeak(pool *Pool[*pooledBuffer]) *pooledBuffer {
+	return pool.Get()
+}
+
+func TestPoolLeakDetection(t *testing.T) {
+	pool := NewPool(func() *pooledBuffer {
+		return &pooledBuffer{}
+	}, nil, OptionLeakThreshold(10*time.Millisecond))
+
+	leaked := getForLeak(&pool)
+	returned := pool.Get()
+	if stats := pool.Stats(); len(stats.Leaks) != 0 {
+		t.Errorf("未超过阈值时不应有泄漏，实际为%d个", len(stats.Leaks))
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	pool.Put(returned)
+	recent := pool.Get()
+	stats := pool.Stats()
+	if stats.Gets != 3 || stats.Puts != 1 || len(stats.Leaks) != 1 {
+		t.Fatalf("应只有1个泄漏，实际为%d个，统计为%+v", len(stats.Leaks), stats)
+	}
+	if !strings.Contains(stats.Leaks[0].Stack, "getForLeak") {
+		t.Errorf("泄漏的调用栈应包含getForLeak，实际为%s", stats.Leaks[0].Stack)
+	}
+
+	pool.Put(leaked)
+	pool.Put(recent)
+	if stats := pool.Stats(); len(stats.Leaks) != 0 || stats.Outstanding != 0 {
+		t.Errorf("全部Put后不应有泄漏，实际为%d个", len(stats.Leaks))
+	}
+
+	// 值类型的相等元素分别跟踪，不可比较的元素不跟踪
+	ints := NewPool(func() int { return 0 }, nil, OptionLeakThreshold(time.Nanosecond))
+	ints.Get()
+	ints.Get()
+	ints.Put(0)
+	slices := NewPool(func() []byte { return nil }, nil, OptionLeakThreshold(time.Nanosecond))
+	slices.Get()
+	time.Sleep(time.Millisecond)
+	if leaks := ints.Stats().Leaks; len(leaks) != 1 {
+		t.Errorf("值类型泄漏个数预期为1，实际为%d", len(leaks))
+	}
+	if stats := slices.Stats(); len(stats.Leaks) != 0 || stats.Outstanding != 1 {
+		t.Errorf("不可比较的元素不应被跟踪，实际泄漏%d个", len(stats.Leaks))
+	}
+}
+
+func BenchmarkPoolGetPutStats(b *testing.B) {
+	pool := NewPool(func() int { return 0 }, nil, OptionStats(true))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Put(pool.Get())
+	}
+}